@@ -0,0 +1,147 @@
+package cli
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/salads-source/go_http_server/models"
+)
+
+const usage = `usage: go_http_server <command> [flags]
+
+Without a command the HTTP server is started.
+
+commands:
+  import-events          import events from a CSV or NDJSON file
+  export-events          export events as CSV, NDJSON or Parquet
+  export-registrations   export registrations as CSV, NDJSON or Parquet
+  set-role               give a user the admin, organizer or attendee role`
+
+// Run executes a command-line subcommand. args excludes the program name.
+func Run(args []string, stdout io.Writer) error {
+	if len(args) == 0 {
+		return errors.New(usage)
+	}
+
+	switch args[0] {
+	case "import-events":
+		return importEvents(args[1:], stdout)
+	case "export-events":
+		return exportEvents(args[1:], stdout)
+	case "export-registrations":
+		return exportRegistrations(args[1:], stdout)
+	case "set-role":
+		return setRole(args[1:], stdout)
+	case "help", "-h", "--help":
+		fmt.Fprintln(stdout, usage)
+		return nil
+	}
+
+	return fmt.Errorf("unknown command %q\n\n%s", args[0], usage)
+}
+
+func importEvents(args []string, stdout io.Writer) error {
+	flags := flag.NewFlagSet("import-events", flag.ContinueOnError)
+	file := flags.String("file", "-", "file to import, - reads standard input")
+	formatValue := flags.String("format", "", "csv or ndjson, defaults to the file extension")
+	modeValue := flags.String("mode", string(models.ImportModeAtomic), "atomic or best_effort")
+	dryRun := flags.Bool("dry-run", false, "validate the rows without importing them")
+	userId := flags.Int64("user", 0, "ID of the user who will own the imported events")
+
+	err := flags.Parse(args)
+
+	if err != nil {
+		return err
+	}
+
+	if *userId <= 0 {
+		return errors.New("import-events: -user is required")
+	}
+
+	if *formatValue == "" {
+		*formatValue = strings.TrimPrefix(filepath.Ext(*file), ".")
+	}
+
+	format, err := models.ParseImportFormat(*formatValue)
+
+	if err != nil {
+		return fmt.Errorf("import-events: %w", err)
+	}
+
+	mode, err := models.ParseImportMode(*modeValue)
+
+	if err != nil {
+		return fmt.Errorf("import-events: %w", err)
+	}
+
+	input := io.Reader(os.Stdin)
+
+	if *file != "-" {
+		f, err := os.Open(*file)
+
+		if err != nil {
+			return fmt.Errorf("import-events: %w", err)
+		}
+
+		defer f.Close()
+		input = f
+	}
+
+	result, err := models.ImportEvents(input, format, mode, *dryRun, *userId)
+
+	if err != nil {
+		return fmt.Errorf("import-events: %w", err)
+	}
+
+	encoder := json.NewEncoder(stdout)
+	encoder.SetIndent("", "  ")
+	err = encoder.Encode(result)
+
+	if err != nil {
+		return err
+	}
+
+	if !*dryRun && mode == models.ImportModeAtomic && result.Imported == 0 && len(result.Errors) > 0 {
+		return errors.New("import-events: import failed, no events were imported")
+	}
+
+	return nil
+}
+
+// setRole is how the first admin is created; after that admins can change
+// roles over the API.
+func setRole(args []string, stdout io.Writer) error {
+	flags := flag.NewFlagSet("set-role", flag.ContinueOnError)
+	userId := flags.Int64("user", 0, "ID of the user")
+	role := flags.String("role", "", "admin, organizer or attendee")
+
+	err := flags.Parse(args)
+
+	if err != nil {
+		return err
+	}
+
+	if *userId <= 0 {
+		return errors.New("set-role: -user is required")
+	}
+
+	err = models.SetUserRole(*userId, *role)
+
+	if errors.Is(err, sql.ErrNoRows) {
+		return fmt.Errorf("set-role: no user with ID %d", *userId)
+	}
+
+	if err != nil {
+		return fmt.Errorf("set-role: %w", err)
+	}
+
+	fmt.Fprintf(stdout, "User %d is now %s.\n", *userId, *role)
+	return nil
+}
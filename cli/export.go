@@ -0,0 +1,126 @@
+package cli
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"github.com/salads-source/go_http_server/models"
+	"github.com/salads-source/go_http_server/utils"
+)
+
+// The CLI runs with direct database access, so exports include every email.
+var operatorAccess = models.EmailAccess{All: true}
+
+func exportEvents(args []string, stdout io.Writer) error {
+	flags := flag.NewFlagSet("export-events", flag.ContinueOnError)
+	formatValue := flags.String("format", "csv", "csv, ndjson or parquet")
+	out := flags.String("out", "-", "output file, - writes to standard output")
+	from := flags.String("from", "", "only events at or after this RFC 3339 time")
+	to := flags.String("to", "", "only events at or before this RFC 3339 time")
+	userId := flags.Int64("user", 0, "only events organized by this user ID")
+	location := flags.String("location", "", "only events whose location contains this text")
+
+	err := flags.Parse(args)
+
+	if err != nil {
+		return err
+	}
+
+	filter := models.EventFilter{UserID: *userId, Location: *location, AllVisibilities: true}
+
+	filter.From, err = parseTimeFlag("from", *from)
+
+	if err != nil {
+		return err
+	}
+
+	filter.To, err = parseTimeFlag("to", *to)
+
+	if err != nil {
+		return err
+	}
+
+	return writeExport(*formatValue, *out, stdout, func(write func(models.EventExportRow) error) error {
+		return models.StreamEvents(filter, operatorAccess, write)
+	})
+}
+
+func exportRegistrations(args []string, stdout io.Writer) error {
+	flags := flag.NewFlagSet("export-registrations", flag.ContinueOnError)
+	formatValue := flags.String("format", "csv", "csv, ndjson or parquet")
+	out := flags.String("out", "-", "output file, - writes to standard output")
+	eventId := flags.Int64("event", 0, "only registrations for this event ID")
+	userId := flags.Int64("user", 0, "only registrations of this user ID")
+
+	err := flags.Parse(args)
+
+	if err != nil {
+		return err
+	}
+
+	filter := models.RegistrationFilter{EventID: *eventId, UserID: *userId}
+
+	return writeExport(*formatValue, *out, stdout, func(write func(models.RegistrationExportRow) error) error {
+		return models.StreamRegistrations(filter, operatorAccess, write)
+	})
+}
+
+func writeExport[T utils.CSVRecord](formatValue, out string, stdout io.Writer, stream func(func(T) error) error) error {
+	format, err := utils.ParseExportFormat(formatValue)
+
+	if err != nil {
+		return err
+	}
+
+	output := stdout
+
+	if out != "-" {
+		f, err := os.Create(out)
+
+		if err != nil {
+			return err
+		}
+
+		defer f.Close()
+		output = f
+	}
+
+	buffered := bufio.NewWriter(output)
+	writer, err := utils.NewRecordWriter[T](buffered, format)
+
+	if err != nil {
+		return err
+	}
+
+	err = stream(writer.Write)
+
+	if err != nil {
+		return err
+	}
+
+	err = writer.Close()
+
+	if err != nil {
+		return err
+	}
+
+	return buffered.Flush()
+}
+
+func parseTimeFlag(name, value string) (time.Time, error) {
+	if value == "" {
+		return time.Time{}, nil
+	}
+
+	parsed, err := time.Parse(time.RFC3339, value)
+
+	if err != nil {
+		return time.Time{}, fmt.Errorf("-%s must be an RFC 3339 timestamp", name)
+	}
+
+	return parsed, nil
+}
@@ -2,6 +2,8 @@ package db
 
 import (
 	"database/sql"
+	"fmt"
+
 	_ "github.com/mattn/go-sqlite3"
 )
 
@@ -69,4 +71,403 @@ func createTables() {
 	if err != nil {
 		panic("Could nto create registrations table.")
 	}
+
+	createAttachmentsTable := `
+	CREATE TABLE IF NOT EXISTS attachments (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		event_id INTEGER NOT NULL,
+		kind TEXT NOT NULL,
+		filename TEXT NOT NULL,
+		content_type TEXT NOT NULL,
+		size INTEGER NOT NULL,
+		storage_key TEXT NOT NULL,
+		thumbnail_key TEXT NOT NULL DEFAULT '',
+		thumbnail_content_type TEXT NOT NULL DEFAULT '',
+		user_id INTEGER,
+		created_at DATETIME NOT NULL,
+		FOREIGN KEY(event_id) REFERENCES events(id),
+		FOREIGN KEY(user_id) REFERENCES users(id)
+	);
+	`
+
+	_, err = DB.Exec(createAttachmentsTable)
+
+	if err != nil {
+		panic("Could not create attachments table.")
+	}
+
+	createCommentsTable := `
+	CREATE TABLE IF NOT EXISTS comments (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		event_id INTEGER NOT NULL,
+		user_id INTEGER NOT NULL,
+		parent_id INTEGER,
+		root_id INTEGER NOT NULL DEFAULT 0,
+		body TEXT NOT NULL,
+		status TEXT NOT NULL DEFAULT 'visible',
+		created_at DATETIME NOT NULL,
+		updated_at DATETIME,
+		FOREIGN KEY(event_id) REFERENCES events(id),
+		FOREIGN KEY(user_id) REFERENCES users(id),
+		FOREIGN KEY(parent_id) REFERENCES comments(id)
+	);
+	CREATE INDEX IF NOT EXISTS comments_event_root ON comments(event_id, root_id);
+	`
+
+	_, err = DB.Exec(createCommentsTable)
+
+	if err != nil {
+		panic("Could not create comments table.")
+	}
+
+	createFeedbackTable := `
+	CREATE TABLE IF NOT EXISTS feedback (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		event_id INTEGER NOT NULL,
+		user_id INTEGER NOT NULL,
+		rating INTEGER NOT NULL CHECK (rating BETWEEN 1 AND 5),
+		review TEXT NOT NULL DEFAULT '',
+		created_at DATETIME NOT NULL,
+		UNIQUE(event_id, user_id),
+		FOREIGN KEY(event_id) REFERENCES events(id),
+		FOREIGN KEY(user_id) REFERENCES users(id)
+	);
+	`
+
+	_, err = DB.Exec(createFeedbackTable)
+
+	if err != nil {
+		panic("Could not create feedback table.")
+	}
+
+	createInvitationTables := `
+	CREATE TABLE IF NOT EXISTS event_invitations (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		event_id INTEGER NOT NULL,
+		email TEXT NOT NULL,
+		created_at DATETIME NOT NULL,
+		UNIQUE(event_id, email),
+		FOREIGN KEY(event_id) REFERENCES events(id)
+	);
+	CREATE TABLE IF NOT EXISTS invite_codes (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		event_id INTEGER NOT NULL,
+		code_hash TEXT NOT NULL UNIQUE,
+		expires_at DATETIME,
+		revoked_at DATETIME,
+		created_at DATETIME NOT NULL,
+		FOREIGN KEY(event_id) REFERENCES events(id)
+	);
+	`
+
+	_, err = DB.Exec(createInvitationTables)
+
+	if err != nil {
+		panic("Could not create invitation tables.")
+	}
+
+	// Versions outlive the event they describe, so there is no foreign key.
+	createEventVersionsTable := `
+	CREATE TABLE IF NOT EXISTS event_versions (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		event_id INTEGER NOT NULL,
+		version INTEGER NOT NULL,
+		action TEXT NOT NULL,
+		actor_id INTEGER NOT NULL,
+		snapshot TEXT NOT NULL,
+		created_at DATETIME NOT NULL,
+		UNIQUE(event_id, version)
+	)
+	`
+
+	_, err = DB.Exec(createEventVersionsTable)
+
+	if err != nil {
+		panic("Could not create event versions table.")
+	}
+
+	createVenueTables := `
+	CREATE TABLE IF NOT EXISTS venues (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		name TEXT NOT NULL,
+		address TEXT NOT NULL,
+		capacity INTEGER NOT NULL DEFAULT 0,
+		user_id INTEGER NOT NULL,
+		created_at DATETIME NOT NULL,
+		FOREIGN KEY(user_id) REFERENCES users(id)
+	);
+	CREATE TABLE IF NOT EXISTS venue_rooms (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		venue_id INTEGER NOT NULL,
+		name TEXT NOT NULL,
+		capacity INTEGER NOT NULL DEFAULT 0,
+		FOREIGN KEY(venue_id) REFERENCES venues(id)
+	);
+	`
+
+	_, err = DB.Exec(createVenueTables)
+
+	if err != nil {
+		panic("Could not create venue tables.")
+	}
+
+	createQuestionsTable := `
+	CREATE TABLE IF NOT EXISTS registration_questions (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		event_id INTEGER NOT NULL,
+		position INTEGER NOT NULL,
+		key TEXT NOT NULL,
+		label TEXT NOT NULL,
+		type TEXT NOT NULL,
+		required INTEGER NOT NULL DEFAULT 0,
+		options TEXT NOT NULL DEFAULT '[]',
+		min REAL,
+		max REAL,
+		show_if TEXT,
+		UNIQUE(event_id, key),
+		FOREIGN KEY(event_id) REFERENCES events(id)
+	)
+	`
+
+	_, err = DB.Exec(createQuestionsTable)
+
+	if err != nil {
+		panic("Could not create registration questions table.")
+	}
+
+	createGuestsTable := `
+	CREATE TABLE IF NOT EXISTS registration_guests (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		registration_id INTEGER NOT NULL,
+		name TEXT NOT NULL,
+		email TEXT NOT NULL DEFAULT '',
+		created_at DATETIME NOT NULL,
+		FOREIGN KEY(registration_id) REFERENCES registrations(id)
+	)
+	`
+
+	_, err = DB.Exec(createGuestsTable)
+
+	if err != nil {
+		panic("Could not create registration guests table.")
+	}
+
+	// Transfers are kept once they are answered, as the audit trail of who
+	// held a place, until the event itself is deleted.
+	createTransfersTable := `
+	CREATE TABLE IF NOT EXISTS registration_transfers (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		registration_id INTEGER NOT NULL,
+		event_id INTEGER NOT NULL,
+		from_user_id INTEGER NOT NULL,
+		to_email TEXT NOT NULL,
+		to_user_id INTEGER,
+		status TEXT NOT NULL,
+		created_at DATETIME NOT NULL,
+		responded_at DATETIME
+	)
+	`
+
+	_, err = DB.Exec(createTransfersTable)
+
+	if err != nil {
+		panic("Could not create registration transfers table.")
+	}
+
+	// Each refresh token can be exchanged once; the used ones are kept so a
+	// replayed token can be recognised and its session revoked.
+	createSessionTables := `
+	CREATE TABLE IF NOT EXISTS sessions (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		user_id INTEGER NOT NULL,
+		user_agent TEXT NOT NULL DEFAULT '',
+		ip_address TEXT NOT NULL DEFAULT '',
+		created_at DATETIME NOT NULL,
+		last_used_at DATETIME NOT NULL,
+		revoked_at DATETIME,
+		FOREIGN KEY(user_id) REFERENCES users(id)
+	);
+
+	CREATE TABLE IF NOT EXISTS refresh_tokens (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		session_id INTEGER NOT NULL,
+		token_hash TEXT NOT NULL UNIQUE,
+		created_at DATETIME NOT NULL,
+		expires_at DATETIME NOT NULL,
+		used_at DATETIME,
+		FOREIGN KEY(session_id) REFERENCES sessions(id)
+	)
+	`
+
+	_, err = DB.Exec(createSessionTables)
+
+	if err != nil {
+		panic("Could not create session tables.")
+	}
+
+	createRevokedTokensTable := `
+	CREATE TABLE IF NOT EXISTS revoked_tokens (
+		jti TEXT PRIMARY KEY,
+		expires_at DATETIME NOT NULL
+	)
+	`
+
+	_, err = DB.Exec(createRevokedTokensTable)
+
+	if err != nil {
+		panic("Could not create revoked tokens table.")
+	}
+
+	createPasswordResetsTable := `
+	CREATE TABLE IF NOT EXISTS password_resets (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		user_id INTEGER NOT NULL,
+		token_hash TEXT NOT NULL UNIQUE,
+		created_at DATETIME NOT NULL,
+		expires_at DATETIME NOT NULL,
+		used_at DATETIME,
+		FOREIGN KEY(user_id) REFERENCES users(id)
+	)
+	`
+
+	_, err = DB.Exec(createPasswordResetsTable)
+
+	if err != nil {
+		panic("Could not create password resets table.")
+	}
+
+	createEmailVerificationsTable := `
+	CREATE TABLE IF NOT EXISTS email_verifications (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		user_id INTEGER NOT NULL,
+		token_hash TEXT NOT NULL UNIQUE,
+		created_at DATETIME NOT NULL,
+		expires_at DATETIME NOT NULL,
+		used_at DATETIME,
+		FOREIGN KEY(user_id) REFERENCES users(id)
+	)
+	`
+
+	_, err = DB.Exec(createEmailVerificationsTable)
+
+	if err != nil {
+		panic("Could not create email verifications table.")
+	}
+
+	// Login attempts are both the login history users see and the counters
+	// that throttle password guessing, so unknown emails are recorded too.
+	createLoginAttemptsTable := `
+	CREATE TABLE IF NOT EXISTS login_attempts (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		user_id INTEGER,
+		email TEXT NOT NULL,
+		ip_address TEXT NOT NULL DEFAULT '',
+		user_agent TEXT NOT NULL DEFAULT '',
+		outcome TEXT NOT NULL,
+		created_at DATETIME NOT NULL
+	);
+	CREATE INDEX IF NOT EXISTS login_attempts_email ON login_attempts(email, id);
+	CREATE INDEX IF NOT EXISTS login_attempts_ip ON login_attempts(ip_address, created_at);
+	CREATE INDEX IF NOT EXISTS login_attempts_user ON login_attempts(user_id, id);
+	CREATE TABLE IF NOT EXISTS account_unlocks (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		user_id INTEGER NOT NULL,
+		token_hash TEXT NOT NULL UNIQUE,
+		created_at DATETIME NOT NULL,
+		expires_at DATETIME NOT NULL,
+		used_at DATETIME,
+		FOREIGN KEY(user_id) REFERENCES users(id)
+	)
+	`
+
+	_, err = DB.Exec(createLoginAttemptsTable)
+
+	if err != nil {
+		panic("Could not create login attempts tables.")
+	}
+
+	addColumn("events", "comments_registered_only", "INTEGER NOT NULL DEFAULT 0")
+	addColumn("events", "feedback_checked_in_only", "INTEGER NOT NULL DEFAULT 0")
+	addColumn("registrations", "checked_in_at", "DATETIME")
+	addColumn("events", "visibility", "TEXT NOT NULL DEFAULT 'public'")
+	addColumn("events", "end_dateTime", "DATETIME")
+	addColumn("events", "all_day", "INTEGER NOT NULL DEFAULT 0")
+	addColumn("events", "venue_id", "INTEGER REFERENCES venues(id)")
+	addColumn("events", "room_id", "INTEGER REFERENCES venue_rooms(id)")
+	addColumn("events", "capacity", "INTEGER NOT NULL DEFAULT 0")
+	addColumn("registrations", "answers", "TEXT NOT NULL DEFAULT '{}'")
+	addColumn("events", "max_guests", "INTEGER NOT NULL DEFAULT 0")
+	addColumn("events", "cancellation_cutoff_minutes", "INTEGER NOT NULL DEFAULT 0")
+	addColumn("events", "requires_approval", "INTEGER NOT NULL DEFAULT 0")
+	addColumn("registrations", "status", "TEXT NOT NULL DEFAULT 'approved'")
+	addColumn("registrations", "status_reason", "TEXT NOT NULL DEFAULT ''")
+	addColumn("registrations", "decided_at", "DATETIME")
+	addColumn("events", "allow_transfers", "INTEGER NOT NULL DEFAULT 0")
+	addColumn("users", "placeholder", "INTEGER NOT NULL DEFAULT 0")
+	addColumn("users", "tokens_valid_after", "DATETIME")
+	addColumn("users", "role", "TEXT NOT NULL DEFAULT 'attendee'")
+	// Accounts from before verification existed count as verified; signup
+	// inserts new users as unverified.
+	addColumn("users", "email_verified", "INTEGER NOT NULL DEFAULT 1")
+	addColumn("users", "display_name", "TEXT NOT NULL DEFAULT ''")
+	addColumn("users", "bio", "TEXT NOT NULL DEFAULT ''")
+	addColumn("users", "avatar_url", "TEXT NOT NULL DEFAULT ''")
+	addColumn("users", "time_zone", "TEXT NOT NULL DEFAULT ''")
+	addColumn("users", "locale", "TEXT NOT NULL DEFAULT ''")
+	addColumn("users", "deleted_at", "DATETIME")
+	normalizeEmails()
+}
+
+// normalizeEmails lowercases the emails that signup used to store as typed.
+// Where accounts share an address in different cases, a verified account
+// keeps it ahead of an unverified one or a placeholder, and the earliest one
+// wins ties. The others are renamed so the address no longer reaches them.
+func normalizeEmails() {
+	_, err := DB.Exec(`UPDATE users SET email = 'duplicate:' || id || ':' || LOWER(TRIM(email))
+	WHERE EXISTS (SELECT 1 FROM users kept WHERE LOWER(TRIM(kept.email)) = LOWER(TRIM(users.email))
+		AND (kept.placeholder, -kept.email_verified, kept.id) < (users.placeholder, -users.email_verified, users.id))`)
+
+	if err != nil {
+		panic("Could not rename duplicate emails.")
+	}
+
+	_, err = DB.Exec("UPDATE users SET email = LOWER(TRIM(email)) WHERE email != LOWER(TRIM(email))")
+
+	if err != nil {
+		panic("Could not normalize emails.")
+	}
+}
+
+// addColumn adds a column that was introduced after a table was first
+// created, so databases from older versions keep working.
+func addColumn(table, column, definition string) {
+	rows, err := DB.Query(fmt.Sprintf("PRAGMA table_info(%s)", table))
+
+	if err != nil {
+		panic("Could not inspect " + table + " table.")
+	}
+
+	defer rows.Close()
+
+	for rows.Next() {
+		var cid, notNull, primaryKey int
+		var name, columnType string
+		var defaultValue sql.NullString
+
+		err := rows.Scan(&cid, &name, &columnType, &notNull, &defaultValue, &primaryKey)
+
+		if err != nil {
+			panic("Could not inspect " + table + " table.")
+		}
+
+		if name == column {
+			return
+		}
+	}
+
+	_, err = DB.Exec(fmt.Sprintf("ALTER TABLE %s ADD COLUMN %s %s", table, column, definition))
+
+	if err != nil {
+		panic("Could not add " + column + " column to " + table + " table.")
+	}
 }
@@ -0,0 +1,34 @@
+package mail
+
+import "os"
+
+// Message is a plain text email.
+type Message struct {
+	To      string
+	Subject string
+	Body    string
+}
+
+// Mailer delivers email. Implementations must be safe for concurrent use.
+type Mailer interface {
+	Send(message Message) error
+}
+
+var Default Mailer
+
+// InitMailer sends email over SMTP when SMTP_ADDR is set. Otherwise messages
+// are appended to MAIL_FILE, or printed to standard output when that is not
+// set either, which is enough for local development.
+func InitMailer() {
+	if addr := os.Getenv("SMTP_ADDR"); addr != "" {
+		Default = NewSMTPMailer(addr, os.Getenv("SMTP_USERNAME"), os.Getenv("SMTP_PASSWORD"), os.Getenv("MAIL_FROM"))
+		return
+	}
+
+	if path := os.Getenv("MAIL_FILE"); path != "" {
+		Default = NewFileMailer(path)
+		return
+	}
+
+	Default = NewWriterMailer(os.Stdout)
+}
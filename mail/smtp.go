@@ -0,0 +1,48 @@
+package mail
+
+import (
+	"net"
+	"net/smtp"
+	"strings"
+)
+
+// SMTPMailer sends email through an SMTP server, authenticating with PLAIN
+// auth when a username is given.
+type SMTPMailer struct {
+	addr string
+	from string
+	auth smtp.Auth
+}
+
+func NewSMTPMailer(addr, username, password, from string) *SMTPMailer {
+	mailer := SMTPMailer{addr: addr, from: from}
+
+	if username != "" {
+		host, _, _ := net.SplitHostPort(addr)
+		mailer.auth = smtp.PlainAuth("", username, password, host)
+	}
+
+	return &mailer
+}
+
+func (m *SMTPMailer) Send(message Message) error {
+	return smtp.SendMail(m.addr, m.auth, m.from, []string{message.To}, []byte(format(m.from, message)))
+}
+
+// format renders message with the headers an SMTP server expects. Header
+// values are stripped of line breaks so they cannot inject extra headers.
+func format(from string, message Message) string {
+	clean := strings.NewReplacer("\r", "", "\n", "")
+
+	var b strings.Builder
+	if from != "" {
+		b.WriteString("From: " + clean.Replace(from) + "\r\n")
+	}
+
+	b.WriteString("To: " + clean.Replace(message.To) + "\r\n")
+	b.WriteString("Subject: " + clean.Replace(message.Subject) + "\r\n")
+	b.WriteString("Content-Type: text/plain; charset=utf-8\r\n")
+	b.WriteString("\r\n")
+	b.WriteString(strings.ReplaceAll(message.Body, "\n", "\r\n"))
+	return b.String()
+}
@@ -0,0 +1,56 @@
+package mail
+
+import (
+	"io"
+	"os"
+	"sync"
+)
+
+// WriterMailer writes each message to an io.Writer instead of sending it.
+type WriterMailer struct {
+	mu sync.Mutex
+	w  io.Writer
+}
+
+func NewWriterMailer(w io.Writer) *WriterMailer {
+	return &WriterMailer{w: w}
+}
+
+func (m *WriterMailer) Send(message Message) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	_, err := io.WriteString(m.w, format("", message)+"\r\n\r\n")
+	return err
+}
+
+// FileMailer appends each message to a file, opening it per message so the
+// file can be rotated or deleted while the server runs.
+type FileMailer struct {
+	mu   sync.Mutex
+	path string
+}
+
+func NewFileMailer(path string) *FileMailer {
+	return &FileMailer{path: path}
+}
+
+func (m *FileMailer) Send(message Message) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	f, err := os.OpenFile(m.path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o600)
+
+	if err != nil {
+		return err
+	}
+
+	_, err = io.WriteString(f, format("", message)+"\r\n\r\n")
+
+	if err != nil {
+		f.Close()
+		return err
+	}
+
+	return f.Close()
+}
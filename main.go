@@ -1,16 +1,88 @@
 package main
 
 import (
+	"fmt"
+	"log"
+	"os"
+	"time"
+
 	"github.com/gin-gonic/gin"
+	"github.com/salads-source/go_http_server/cli"
 	"github.com/salads-source/go_http_server/db"
+	"github.com/salads-source/go_http_server/mail"
+	"github.com/salads-source/go_http_server/models"
 	"github.com/salads-source/go_http_server/routes"
+	"github.com/salads-source/go_http_server/storage"
+	"github.com/salads-source/go_http_server/utils"
 )
 
 func main() {
 	db.InitDB()
+	storage.InitStore()
+	utils.InitKeys()
+	mail.InitMailer()
+
+	// Only organizers and admins may create events when this is on.
+	models.RestrictEventCreation = os.Getenv("RESTRICT_EVENT_CREATION") == "true"
+
+	requirements, err := models.ParseVerificationRequirements(os.Getenv("REQUIRE_VERIFIED_EMAIL"))
+
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+
+	models.RequireVerifiedEmail = requirements
+
+	deletionRules, err := models.ParseDeletionRules(os.Getenv("ACCOUNT_DELETION_RULES"))
+
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+
+	models.DefaultDeletionRules = deletionRules
+
+	passwords, err := models.LoadPasswordPolicy(os.Getenv("PASSWORD_MIN_LENGTH"), os.Getenv("PASSWORD_BANNED_FILE"),
+		os.Getenv("BREACHED_PASSWORDS_FILE"))
+
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+
+	models.Passwords = passwords
+
+	if len(os.Args) > 1 {
+		err := cli.Run(os.Args[1:], os.Stdout)
+
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+
+		return
+	}
+
+	go pruneLoginHistory(time.Hour)
+
 	server := gin.Default()
 
 	routes.RegisterRoutes(server)
 
 	server.Run(":8080") // loaclhost:8080
 }
+
+// pruneLoginHistory removes expired login history now and then every
+// interval, for as long as the server runs.
+func pruneLoginHistory(interval time.Duration) {
+	for {
+		_, err := models.PruneLoginAttempts()
+
+		if err != nil {
+			log.Printf("could not prune login history: %v", err)
+		}
+
+		time.Sleep(interval)
+	}
+}
@@ -1,9 +1,11 @@
 package middlewares
 
 import (
+	"errors"
 	"net/http"
 
 	"github.com/gin-gonic/gin"
+	"github.com/salads-source/go_http_server/models"
 	"github.com/salads-source/go_http_server/utils"
 )
 
@@ -15,13 +17,112 @@ func Authenticate(context *gin.Context) {
 		return
 	}
 
-	userId, err := utils.VerifyToken(token)
+	claims, err := verifyToken(token)
 
 	if err != nil {
 		context.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"message": "Not Authorized"})
 		return
 	}
 
-	context.Set("userId", userId)
+	context.Set("userId", claims.UserID)
+	context.Set("tokenClaims", claims)
 	context.Next()
 }
+
+// OptionalAuthenticate identifies the caller when a valid token is sent but
+// lets anonymous requests through, for routes whose response depends on who
+// is asking. Handlers see a userId of 0 for anonymous callers.
+func OptionalAuthenticate(context *gin.Context) {
+	token := context.Request.Header.Get("Authorization")
+
+	if token != "" {
+		claims, err := verifyToken(token)
+
+		if err == nil {
+			context.Set("userId", claims.UserID)
+			context.Set("tokenClaims", claims)
+		}
+	}
+
+	context.Next()
+}
+
+// verifyToken checks the token's signature and that it has not been revoked
+// since it was issued.
+func verifyToken(token string) (*utils.TokenClaims, error) {
+	claims, err := utils.ParseToken(token)
+
+	if err != nil {
+		return nil, err
+	}
+
+	revoked, err := models.IsTokenRevoked(claims)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if revoked {
+		return nil, errors.New("Token has been revoked")
+	}
+
+	return claims, nil
+}
+
+// RequireRole only lets callers with one of roles through. It must run
+// after Authenticate.
+func RequireRole(roles ...string) gin.HandlerFunc {
+	return func(context *gin.Context) {
+		claims := context.MustGet("tokenClaims").(*utils.TokenClaims)
+
+		for _, role := range roles {
+			if claims.Role == role {
+				context.Next()
+				return
+			}
+		}
+
+		context.AbortWithStatusJSON(http.StatusForbidden, gin.H{"message": "Your role does not allow this."})
+	}
+}
+
+// RequirePermission only lets callers whose role grants permission
+// through. It must run after Authenticate.
+func RequirePermission(permission models.Permission) gin.HandlerFunc {
+	return func(context *gin.Context) {
+		claims := context.MustGet("tokenClaims").(*utils.TokenClaims)
+
+		if !models.HasPermission(claims.Role, permission) {
+			context.AbortWithStatusJSON(http.StatusForbidden, gin.H{"message": "Your role does not allow this."})
+			return
+		}
+
+		context.Next()
+	}
+}
+
+// RequireVerifiedEmail stops callers who have not verified their email
+// address when the setting requires it for action. It must run after
+// Authenticate.
+func RequireVerifiedEmail(action models.VerifiedAction) gin.HandlerFunc {
+	return func(context *gin.Context) {
+		if !models.RequireVerifiedEmail[action] {
+			context.Next()
+			return
+		}
+
+		verified, err := models.IsEmailVerified(context.GetInt64("userId"))
+
+		if err != nil {
+			context.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"message": "Could not check your email verification, try again later"})
+			return
+		}
+
+		if !verified {
+			context.AbortWithStatusJSON(http.StatusForbidden, gin.H{"message": "Please verify your email address first."})
+			return
+		}
+
+		context.Next()
+	}
+}
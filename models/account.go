@@ -0,0 +1,591 @@
+package models
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/salads-source/go_http_server/db"
+	"github.com/salads-source/go_http_server/utils"
+)
+
+const (
+	// DeletedEventsCancel deletes the events of a deleted account. Its
+	// venues stay, for admins to look after.
+	DeletedEventsCancel = "cancel"
+	// DeletedEventsTransfer hands the events, and the venues, of a deleted
+	// account to another user.
+	DeletedEventsTransfer = "transfer"
+
+	// DeletedCommentsAnonymize keeps the comments of a deleted account in
+	// their threads, attributed to the anonymized user.
+	DeletedCommentsAnonymize = "anonymize"
+	// DeletedCommentsDelete withdraws them, as if their author had.
+	DeletedCommentsDelete = "delete"
+)
+
+var (
+	ErrInvalidDeletionRules = errors.New("events must be cancel or transfer, and comments anonymize or delete")
+	ErrInvalidTransferUser  = errors.New("events can only be transferred to the organizer account set in the server's deletion rules")
+)
+
+// DeletionRules say what happens to the things a deleted account leaves
+// behind. Registrations are always released and reviews always removed;
+// ratings stay, so organizers' averages don't change.
+type DeletionRules struct {
+	Events string
+	// TransferTo is the email of the organizer or admin that transferred
+	// events go to. Only the server's rules can set it, so nobody can push
+	// their events onto an account of their choosing.
+	TransferTo string
+	Comments   string
+}
+
+// DefaultDeletionRules fill in what a deletion request leaves out. They are
+// set from the ACCOUNT_DELETION_RULES environment variable.
+var DefaultDeletionRules = DeletionRules{Events: DeletedEventsCancel, Comments: DeletedCommentsAnonymize}
+
+// ParseDeletionRules reads a comma separated list of rules, such as
+// "events=transfer:ops@example.com,comments=delete". Rules that are not
+// given keep their default.
+func ParseDeletionRules(value string) (DeletionRules, error) {
+	rules := DefaultDeletionRules
+
+	for _, item := range strings.Split(value, ",") {
+		item = strings.TrimSpace(item)
+
+		if item == "" {
+			continue
+		}
+
+		name, setting, _ := strings.Cut(item, "=")
+
+		switch name {
+		case "events":
+			rules.Events, rules.TransferTo, _ = strings.Cut(setting, ":")
+		case "comments":
+			rules.Comments = setting
+		default:
+			return rules, fmt.Errorf("unknown account deletion rule %q, use events or comments", item)
+		}
+	}
+
+	return rules, rules.validate()
+}
+
+func (rules DeletionRules) validate() error {
+	if rules.Events != DeletedEventsCancel && rules.Events != DeletedEventsTransfer {
+		return ErrInvalidDeletionRules
+	}
+
+	if rules.Comments != DeletedCommentsAnonymize && rules.Comments != DeletedCommentsDelete {
+		return ErrInvalidDeletionRules
+	}
+
+	if rules.Events == DeletedEventsTransfer && rules.TransferTo == "" {
+		return ErrInvalidTransferUser
+	}
+
+	return nil
+}
+
+// withDefaults fills the empty fields of rules from DefaultDeletionRules.
+// The transfer target always comes from the defaults.
+func (rules DeletionRules) withDefaults() DeletionRules {
+	if rules.Events == "" {
+		rules.Events = DefaultDeletionRules.Events
+	}
+
+	rules.TransferTo = DefaultDeletionRules.TransferTo
+
+	if rules.Comments == "" {
+		rules.Comments = DefaultDeletionRules.Comments
+	}
+
+	return rules
+}
+
+// ExportedRegistration is a registration with the answers given on the
+// registration form.
+type ExportedRegistration struct {
+	UserRegistration
+	Answers Answers
+}
+
+// AccountExport is everything stored about a user.
+type AccountExport struct {
+	Profile       *Profile
+	Events        []Event
+	Registrations []ExportedRegistration
+	Transfers     []RegistrationTransfer
+	Comments      []*Comment
+	Feedback      []Feedback
+	Attachments   []Attachment
+	Venues        []Venue
+	Sessions      []Session
+	Logins        []LoginAttempt
+}
+
+// ExportAccount collects the personal data of userId, returning
+// sql.ErrNoRows if there is no such user.
+func ExportAccount(userId int64) (*AccountExport, error) {
+	var export AccountExport
+	var err error
+
+	export.Profile, err = GetProfile(userId)
+
+	if err != nil {
+		return nil, err
+	}
+
+	export.Events, err = GetAllEvents(EventFilter{UserID: userId, AllVisibilities: true})
+
+	if err != nil {
+		return nil, err
+	}
+
+	export.Registrations, err = exportRegistrations(userId)
+
+	if err != nil {
+		return nil, err
+	}
+
+	export.Transfers, err = queryTransfers("SELECT "+transferColumns+" FROM registration_transfers t WHERE t.from_user_id = ? OR t.to_user_id = ? ORDER BY t.id",
+		userId, userId)
+
+	if err != nil {
+		return nil, err
+	}
+
+	export.Comments, err = exportComments(userId)
+
+	if err != nil {
+		return nil, err
+	}
+
+	export.Feedback, err = exportFeedback(userId)
+
+	if err != nil {
+		return nil, err
+	}
+
+	export.Attachments, err = exportAttachments(userId)
+
+	if err != nil {
+		return nil, err
+	}
+
+	export.Venues, err = exportVenues(userId)
+
+	if err != nil {
+		return nil, err
+	}
+
+	export.Sessions, err = GetSessions(userId)
+
+	if err != nil {
+		return nil, err
+	}
+
+	export.Logins, err = GetLoginHistory(userId)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &export, nil
+}
+
+func exportRegistrations(userId int64) ([]ExportedRegistration, error) {
+	registrations, err := GetUserRegistrations(userId)
+
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := db.DB.Query("SELECT id, answers FROM registrations WHERE user_id = ?", userId)
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	answers := map[int64]Answers{}
+
+	for rows.Next() {
+		var registrationId int64
+		var encoded string
+		err := rows.Scan(&registrationId, &encoded)
+
+		if err != nil {
+			return nil, err
+		}
+
+		var registrationAnswers Answers
+		err = json.Unmarshal([]byte(encoded), &registrationAnswers)
+
+		if err != nil {
+			return nil, err
+		}
+
+		answers[registrationId] = registrationAnswers
+	}
+
+	exported := []ExportedRegistration{}
+
+	for _, registration := range registrations {
+		exported = append(exported, ExportedRegistration{UserRegistration: registration, Answers: answers[registration.ID]})
+	}
+
+	return exported, rows.Err()
+}
+
+// exportComments returns the comments userId wrote, including the text of
+// ones they withdrew, since it is still stored.
+func exportComments(userId int64) ([]*Comment, error) {
+	rows, err := db.DB.Query("SELECT "+commentColumns+" FROM comments WHERE user_id = ? ORDER BY id", userId)
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	comments := []*Comment{}
+
+	for rows.Next() {
+		var comment Comment
+		var parentId sql.NullInt64
+		var updatedAt sql.NullTime
+
+		err := rows.Scan(&comment.ID, &comment.EventID, &comment.UserID, &parentId, &comment.RootID, &comment.Body,
+			&comment.Status, &comment.CreatedAt, &updatedAt)
+
+		if err != nil {
+			return nil, err
+		}
+
+		if parentId.Valid {
+			comment.ParentID = &parentId.Int64
+		}
+
+		if updatedAt.Valid {
+			comment.UpdatedAt = &updatedAt.Time
+		}
+
+		comments = append(comments, &comment)
+	}
+
+	return comments, rows.Err()
+}
+
+func exportFeedback(userId int64) ([]Feedback, error) {
+	rows, err := db.DB.Query("SELECT id, event_id, user_id, rating, review, created_at FROM feedback WHERE user_id = ? ORDER BY id", userId)
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	feedback := []Feedback{}
+
+	for rows.Next() {
+		var entry Feedback
+		err := rows.Scan(&entry.ID, &entry.EventID, &entry.UserID, &entry.Rating, &entry.Review, &entry.CreatedAt)
+
+		if err != nil {
+			return nil, err
+		}
+
+		feedback = append(feedback, entry)
+	}
+
+	return feedback, rows.Err()
+}
+
+// exportAttachments lists the files userId uploaded. Only their details are
+// exported; the files themselves can be downloaded from their events.
+func exportAttachments(userId int64) ([]Attachment, error) {
+	rows, err := db.DB.Query("SELECT "+attachmentColumns+" FROM attachments WHERE user_id = ? ORDER BY id", userId)
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	attachments := []Attachment{}
+
+	for rows.Next() {
+		attachment, err := scanAttachment(rows)
+
+		if err != nil {
+			return nil, err
+		}
+
+		attachments = append(attachments, attachment)
+	}
+
+	return attachments, rows.Err()
+}
+
+func exportVenues(userId int64) ([]Venue, error) {
+	venues, err := GetAllVenues()
+
+	if err != nil {
+		return nil, err
+	}
+
+	owned := []Venue{}
+
+	for _, venue := range venues {
+		if venue.UserID == userId {
+			owned = append(owned, venue)
+		}
+	}
+
+	return owned, nil
+}
+
+// DeleteAccount checks password and then deletes the account of userId in
+// one transaction, following rules, whose empty fields are taken from
+// DefaultDeletionRules:
+//
+//   - organized events are cancelled, along with their registrations, or
+//     transferred to the organizer named by the server;
+//   - registrations, with their guests, are released, and pending transfers
+//     to or from the user are cancelled;
+//   - comments are anonymized or withdrawn, and reviews removed;
+//   - sessions, tokens, login history and invitations addressed to the
+//     user are removed;
+//   - the user row is kept, so records that point at it stay valid, but its
+//     email address, password and profile are cleared.
+//
+// It returns the attachments of cancelled events; callers are responsible
+// for removing their blobs from storage.
+func DeleteAccount(userId int64, password string, rules DeletionRules) ([]Attachment, error) {
+	rules = rules.withDefaults()
+	err := rules.validate()
+
+	if err != nil {
+		return nil, err
+	}
+
+	user, err := GetUserByID(userId)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if !utils.CheckPasswordHash(password, user.Password) {
+		return nil, ErrWrongPassword
+	}
+
+	tx, err := db.DB.Begin()
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer tx.Rollback()
+
+	now := time.Now().UTC()
+
+	events, err := queryEvents(tx, "SELECT "+eventColumns+" FROM events e WHERE e.user_id = ?", userId)
+
+	if err != nil {
+		return nil, err
+	}
+
+	var attachments []Attachment
+
+	if rules.Events == DeletedEventsTransfer {
+		err = transferEvents(tx, userId, rules.TransferTo, events)
+	} else {
+		attachments, err = cancelEvents(tx, userId, events)
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = tx.Exec("UPDATE registration_transfers SET status = ?, responded_at = ? WHERE status = ? AND (from_user_id = ? OR LOWER(to_email) = LOWER(?))",
+		TransferStatusCancelled, now, TransferStatusPending, userId, user.Email)
+
+	if err != nil {
+		return nil, err
+	}
+
+	err = deleteGuests(tx, "user_id = ?", userId)
+
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = tx.Exec("DELETE FROM registrations WHERE user_id = ?", userId)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if rules.Comments == DeletedCommentsDelete {
+		_, err = tx.Exec("UPDATE comments SET status = ?, updated_at = ? WHERE user_id = ? AND status = ?",
+			CommentStatusDeleted, now, userId, CommentStatusVisible)
+
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	// Withdrawn comments are never shown, so their text can go either way.
+	_, err = tx.Exec("UPDATE comments SET body = '' WHERE user_id = ? AND status != ?", userId, CommentStatusVisible)
+
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = tx.Exec("UPDATE feedback SET review = '' WHERE user_id = ?", userId)
+
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = tx.Exec("DELETE FROM event_invitations WHERE LOWER(email) = LOWER(?)", user.Email)
+
+	if err != nil {
+		return nil, err
+	}
+
+	// Keep the emails of past transfers from pointing at the address after
+	// someone else signs up with it.
+	_, err = tx.Exec("UPDATE registration_transfers SET to_email = ? WHERE LOWER(to_email) = LOWER(?)", deletedEmail(userId), user.Email)
+
+	if err != nil {
+		return nil, err
+	}
+
+	for _, table := range []string{"password_resets", "email_verifications", "account_unlocks", "login_attempts"} {
+		_, err = tx.Exec("DELETE FROM "+table+" WHERE user_id = ?", userId)
+
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	err = revokeUserTokens(tx, userId, tokenWatermark())
+
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = tx.Exec("DELETE FROM refresh_tokens WHERE session_id IN (SELECT id FROM sessions WHERE user_id = ?)", userId)
+
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = tx.Exec("UPDATE sessions SET user_agent = '', ip_address = '' WHERE user_id = ?", userId)
+
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = tx.Exec(`UPDATE users SET email = ?, password = '', role = ?, email_verified = 0,
+		display_name = '', bio = '', avatar_url = '', time_zone = '', locale = '', deleted_at = ?
+	WHERE id = ?`, deletedEmail(userId), RoleAttendee, now, userId)
+
+	if err != nil {
+		return nil, err
+	}
+
+	err = tx.Commit()
+
+	if err != nil {
+		return nil, err
+	}
+
+	return attachments, nil
+}
+
+// deletedEmail is the address a deleted account is left with. The .invalid
+// domain cannot receive mail, so nobody can claim it.
+func deletedEmail(userId int64) string {
+	return fmt.Sprintf("deleted-%d@deleted.invalid", userId)
+}
+
+func cancelEvents(tx *sql.Tx, userId int64, events []Event) ([]Attachment, error) {
+	var attachments []Attachment
+
+	for _, event := range events {
+		rows, err := tx.Query("SELECT "+attachmentColumns+" FROM attachments WHERE event_id = ?", event.ID)
+
+		if err != nil {
+			return nil, err
+		}
+
+		for rows.Next() {
+			attachment, err := scanAttachment(rows)
+
+			if err != nil {
+				rows.Close()
+				return nil, err
+			}
+
+			attachments = append(attachments, attachment)
+		}
+
+		rows.Close()
+
+		err = event.delete(tx, userId)
+
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return attachments, nil
+}
+
+// transferEvents hands events and the venues of userId to the organizer or
+// admin with email, recording the handover in each event's history.
+func transferEvents(tx *sql.Tx, userId int64, email string, events []Event) error {
+	var targetId int64
+	err := tx.QueryRow(`SELECT id FROM users WHERE LOWER(email) = LOWER(?) AND placeholder = 0 AND deleted_at IS NULL
+	AND role IN (?, ?)`, email, RoleOrganizer, RoleAdmin).Scan(&targetId)
+
+	if errors.Is(err, sql.ErrNoRows) || targetId == userId {
+		return ErrInvalidTransferUser
+	}
+
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.Exec("UPDATE events SET user_id = ? WHERE user_id = ?", targetId, userId)
+
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.Exec("UPDATE venues SET user_id = ? WHERE user_id = ?", targetId, userId)
+
+	if err != nil {
+		return err
+	}
+
+	for _, event := range events {
+		event.UserID = targetId
+		err = recordEventVersion(tx, event, EventActionUpdate, userId)
+
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
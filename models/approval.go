@@ -0,0 +1,86 @@
+package models
+
+import (
+	"errors"
+	"time"
+
+	"github.com/salads-source/go_http_server/db"
+)
+
+const (
+	RegistrationStatusPending  = "pending"
+	RegistrationStatusApproved = "approved"
+	RegistrationStatusRejected = "rejected"
+)
+
+var ErrNotPending = errors.New("registration is not pending")
+
+func IsRegistrationStatus(status string) bool {
+	switch status {
+	case RegistrationStatusPending, RegistrationStatusApproved, RegistrationStatusRejected:
+		return true
+	}
+
+	return false
+}
+
+// ApproveRegistration confirms a pending registration for the event. Its
+// registrant and guests then count against the capacity, so ErrEventFull is
+// returned when they no longer fit.
+func (event Event) ApproveRegistration(registrationId int64) (*Registration, error) {
+	return event.decideRegistration(registrationId, RegistrationStatusApproved, "")
+}
+
+// RejectRegistration turns down a pending registration for the event, with
+// an optional reason for the registrant.
+func (event Event) RejectRegistration(registrationId int64, reason string) (*Registration, error) {
+	return event.decideRegistration(registrationId, RegistrationStatusRejected, reason)
+}
+
+// decideRegistration moves a pending registration to status. It returns
+// sql.ErrNoRows when the event has no such registration and ErrNotPending
+// when it was already decided.
+func (event Event) decideRegistration(registrationId int64, status, reason string) (*Registration, error) {
+	tx, err := db.DB.Begin()
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer tx.Rollback()
+
+	var current string
+	err = tx.QueryRow("SELECT status FROM registrations WHERE id = ? AND event_id = ?", registrationId, event.ID).Scan(&current)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if current != RegistrationStatusPending {
+		return nil, ErrNotPending
+	}
+
+	_, err = tx.Exec("UPDATE registrations SET status = ?, status_reason = ?, decided_at = ? WHERE id = ?",
+		status, reason, time.Now().UTC(), registrationId)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if status == RegistrationStatusApproved {
+		err = checkCapacity(tx, event.ID)
+
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	err = tx.Commit()
+
+	if err != nil {
+		return nil, err
+	}
+
+	registration, err := GetRegistrationById(registrationId)
+	return &registration, err
+}
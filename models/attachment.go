@@ -0,0 +1,127 @@
+package models
+
+import (
+	"time"
+
+	"github.com/salads-source/go_http_server/db"
+)
+
+const (
+	AttachmentKindCover = "cover"
+	AttachmentKindFile  = "attachment"
+)
+
+type Attachment struct {
+	ID                   int64
+	EventID              int64
+	Kind                 string
+	FileName             string
+	ContentType          string
+	Size                 int64
+	StorageKey           string `json:"-"`
+	ThumbnailKey         string `json:"-"`
+	ThumbnailContentType string `json:"-"`
+	HasThumbnail         bool
+	UserID               int64
+	CreatedAt            time.Time
+}
+
+const attachmentColumns = "id, event_id, kind, filename, content_type, size, storage_key, thumbnail_key, thumbnail_content_type, user_id, created_at"
+
+func scanAttachment(row interface{ Scan(...any) error }) (Attachment, error) {
+	var attachment Attachment
+	err := row.Scan(&attachment.ID, &attachment.EventID, &attachment.Kind, &attachment.FileName, &attachment.ContentType,
+		&attachment.Size, &attachment.StorageKey, &attachment.ThumbnailKey, &attachment.ThumbnailContentType,
+		&attachment.UserID, &attachment.CreatedAt)
+	attachment.HasThumbnail = attachment.ThumbnailKey != ""
+	return attachment, err
+}
+
+func (attachment *Attachment) Save() error {
+	query := `INSERT INTO attachments(event_id, kind, filename, content_type, size, storage_key, thumbnail_key, thumbnail_content_type, user_id, created_at)
+	VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`
+	stmt, err := db.DB.Prepare(query)
+
+	if err != nil {
+		return err
+	}
+
+	defer stmt.Close()
+
+	attachment.CreatedAt = time.Now().UTC()
+	result, err := stmt.Exec(attachment.EventID, attachment.Kind, attachment.FileName, attachment.ContentType, attachment.Size,
+		attachment.StorageKey, attachment.ThumbnailKey, attachment.ThumbnailContentType, attachment.UserID, attachment.CreatedAt)
+
+	if err != nil {
+		return err
+	}
+
+	id, err := result.LastInsertId()
+	attachment.ID = id
+	attachment.HasThumbnail = attachment.ThumbnailKey != ""
+	return err
+}
+
+func GetAttachmentByID(attachmentId int64) (*Attachment, error) {
+	query := "SELECT " + attachmentColumns + " FROM attachments WHERE id = ?"
+	attachment, err := scanAttachment(db.DB.QueryRow(query, attachmentId))
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &attachment, nil
+}
+
+func GetAttachmentsForEvent(eventId int64) ([]Attachment, error) {
+	query := "SELECT " + attachmentColumns + " FROM attachments WHERE event_id = ? ORDER BY id"
+	rows, err := db.DB.Query(query, eventId)
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	attachments := []Attachment{}
+
+	for rows.Next() {
+		attachment, err := scanAttachment(rows)
+
+		if err != nil {
+			return nil, err
+		}
+
+		attachments = append(attachments, attachment)
+	}
+
+	return attachments, rows.Err()
+}
+
+func (attachment Attachment) Delete() error {
+	return deleteAttachments(db.DB, "id = ?", attachment.ID)
+}
+
+func deleteAttachments(q querier, where string, args ...any) error {
+	stmt, err := q.Prepare("DELETE FROM attachments WHERE " + where)
+
+	if err != nil {
+		return err
+	}
+
+	defer stmt.Close()
+
+	_, err = stmt.Exec(args...)
+	return err
+}
+
+// BlobKeys lists the storage keys an attachment occupies.
+func (attachment Attachment) BlobKeys() []string {
+	keys := []string{attachment.StorageKey}
+
+	if attachment.ThumbnailKey != "" {
+		keys = append(keys, attachment.ThumbnailKey)
+	}
+
+	return keys
+}
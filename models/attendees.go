@@ -0,0 +1,265 @@
+package models
+
+import (
+	"database/sql"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"net/mail"
+	"strings"
+
+	"github.com/salads-source/go_http_server/db"
+)
+
+var ErrInvalidEmail = errors.New("invalid email address")
+
+// AttendeeImportResult reports an attendee import. Rows are numbered from 1
+// after the header.
+type AttendeeImportResult struct {
+	Mode                ImportMode
+	Total               int
+	Imported            int
+	PlaceholdersCreated int
+	Errors              []ImportRowError
+	RegistrationIDs     []int64
+}
+
+type attendeeRow struct {
+	row   int
+	email string
+	err   error
+}
+
+func normalizeEmail(email string) (string, error) {
+	email = strings.ToLower(strings.TrimSpace(email))
+	address, err := mail.ParseAddress(email)
+
+	if err != nil || address.Address != email {
+		return "", fmt.Errorf("%w %q", ErrInvalidEmail, email)
+	}
+
+	return email, nil
+}
+
+// AddAttendee registers the user with email for the event on the
+// organizer's behalf, creating a placeholder account for people who have not
+// signed up yet. Attendees added by the organizer are approved straight away
+// but are still subject to the duplicate and capacity checks of Register.
+func (event Event) AddAttendee(email string) (*Registration, bool, error) {
+	email, err := normalizeEmail(email)
+
+	if err != nil {
+		return nil, false, err
+	}
+
+	tx, err := db.DB.Begin()
+
+	if err != nil {
+		return nil, false, err
+	}
+
+	defer tx.Rollback()
+
+	registrationId, created, err := event.addAttendee(tx, email)
+
+	if err != nil {
+		return nil, false, err
+	}
+
+	err = tx.Commit()
+
+	if err != nil {
+		return nil, false, err
+	}
+
+	registration, err := GetRegistrationById(registrationId)
+	return &registration, created, err
+}
+
+func (event Event) addAttendee(q querier, email string) (int64, bool, error) {
+	userId, created, err := findOrCreatePlaceholder(q, email)
+
+	if err != nil {
+		return 0, false, err
+	}
+
+	registrationId, err := event.register(q, userId, RegistrationStatusApproved, Answers{}, nil)
+	return registrationId, created, err
+}
+
+// RemoveAttendee cancels the registration of the user with email, returning
+// ErrNotRegistered if they have none.
+func (event Event) RemoveAttendee(email string) error {
+	var userId int64
+	err := db.DB.QueryRow(`SELECT r.user_id FROM registrations r JOIN users u ON u.id = r.user_id
+	WHERE r.event_id = ? AND (LOWER(u.email) = LOWER(?) OR u.email = ?)`,
+		event.ID, strings.TrimSpace(email), unclaimedEmail(email)).Scan(&userId)
+
+	if errors.Is(err, sql.ErrNoRows) {
+		return ErrNotRegistered
+	}
+
+	if err != nil {
+		return err
+	}
+
+	return event.CancelRegistration(userId)
+}
+
+// ImportAttendees adds the attendees listed in a CSV with an email column.
+// In atomic mode a single bad row leaves the event unchanged; in best effort
+// mode the valid rows are added and the rest reported. As with
+// ImportEvents, the returned error is only set when the input as a whole
+// could not be read.
+func (event Event) ImportAttendees(r io.Reader, mode ImportMode) (AttendeeImportResult, error) {
+	result := AttendeeImportResult{Mode: mode}
+	rows, err := readAttendeeRows(r)
+
+	if err != nil {
+		return result, err
+	}
+
+	result.Total = len(rows)
+
+	for _, row := range rows {
+		if row.err != nil {
+			result.Errors = append(result.Errors, ImportRowError{Row: row.row, Message: row.err.Error()})
+		}
+	}
+
+	if mode == ImportModeBestEffort {
+		for _, row := range rows {
+			if row.err != nil {
+				continue
+			}
+
+			registration, created, err := event.AddAttendee(row.email)
+
+			if err != nil {
+				result.Errors = append(result.Errors, ImportRowError{Row: row.row, Message: attendeeErrorMessage(err)})
+				continue
+			}
+
+			result.Imported++
+			result.RegistrationIDs = append(result.RegistrationIDs, registration.ID)
+
+			if created {
+				result.PlaceholdersCreated++
+			}
+		}
+
+		return result, nil
+	}
+
+	if len(result.Errors) > 0 {
+		return result, nil
+	}
+
+	tx, err := db.DB.Begin()
+
+	if err != nil {
+		return result, err
+	}
+
+	defer tx.Rollback()
+
+	var registrationIds []int64
+	placeholders := 0
+
+	for _, row := range rows {
+		registrationId, created, err := event.addAttendee(tx, row.email)
+
+		if err != nil {
+			result.Errors = append(result.Errors, ImportRowError{Row: row.row, Message: attendeeErrorMessage(err)})
+			return result, nil
+		}
+
+		registrationIds = append(registrationIds, registrationId)
+
+		if created {
+			placeholders++
+		}
+	}
+
+	err = tx.Commit()
+
+	if err != nil {
+		return result, err
+	}
+
+	result.Imported = len(rows)
+	result.PlaceholdersCreated = placeholders
+	result.RegistrationIDs = registrationIds
+	return result, nil
+}
+
+func attendeeErrorMessage(err error) string {
+	switch {
+	case errors.Is(err, ErrAlreadyRegistered):
+		return "already registered for this event"
+	case errors.Is(err, ErrEventFull):
+		return "event is full"
+	case errors.Is(err, ErrInvalidEmail):
+		return err.Error()
+	}
+
+	return "could not add attendee"
+}
+
+func readAttendeeRows(r io.Reader) ([]attendeeRow, error) {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+
+	header, err := reader.Read()
+
+	if err == io.EOF {
+		return nil, errors.New("CSV input is empty")
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	column := -1
+	for i, name := range header {
+		if strings.ToLower(strings.Trim(name, " \ufeff")) == "email" {
+			column = i
+		}
+	}
+
+	if column == -1 {
+		return nil, errors.New(`CSV header is missing the "email" column`)
+	}
+
+	var rows []attendeeRow
+
+	for row := 1; ; row++ {
+		record, err := reader.Read()
+
+		if err == io.EOF {
+			break
+		}
+
+		if err != nil {
+			var parseErr *csv.ParseError
+			if errors.As(err, &parseErr) {
+				rows = append(rows, attendeeRow{row: row, err: parseErr.Err})
+				continue
+			}
+			return nil, err
+		}
+
+		current := attendeeRow{row: row}
+
+		if column < len(record) {
+			current.email = record[column]
+		}
+
+		current.email, current.err = normalizeEmail(current.email)
+		rows = append(rows, current)
+	}
+
+	return rows, nil
+}
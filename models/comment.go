@@ -0,0 +1,221 @@
+package models
+
+import (
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/salads-source/go_http_server/db"
+)
+
+const (
+	CommentStatusVisible = "visible"
+	// CommentStatusDeleted marks a comment withdrawn by its author.
+	CommentStatusDeleted = "deleted"
+	// CommentStatusRemoved marks a comment taken down by the event organizer.
+	CommentStatusRemoved = "removed"
+)
+
+var ErrInvalidParentComment = errors.New("parent comment does not belong to this event")
+
+// Comment is a message on an event. Replies point at their parent and share
+// the RootID of the top-level comment that started the thread, which lets a
+// whole thread be loaded with one query.
+type Comment struct {
+	ID        int64
+	EventID   int64
+	UserID    int64
+	ParentID  *int64
+	RootID    int64 `json:"-"`
+	Body      string
+	Status    string
+	CreatedAt time.Time
+	UpdatedAt *time.Time
+	Replies   []*Comment
+}
+
+const commentColumns = "id, event_id, user_id, parent_id, root_id, body, status, created_at, updated_at"
+
+func scanComment(row interface{ Scan(...any) error }) (*Comment, error) {
+	var comment Comment
+	var parentId sql.NullInt64
+	var updatedAt sql.NullTime
+
+	err := row.Scan(&comment.ID, &comment.EventID, &comment.UserID, &parentId, &comment.RootID, &comment.Body,
+		&comment.Status, &comment.CreatedAt, &updatedAt)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if parentId.Valid {
+		comment.ParentID = &parentId.Int64
+	}
+
+	if updatedAt.Valid {
+		comment.UpdatedAt = &updatedAt.Time
+	}
+
+	// Withdrawn comments stay in the thread so replies keep their context,
+	// but their text is never returned.
+	if comment.Status != CommentStatusVisible {
+		comment.Body = ""
+	}
+
+	comment.Replies = []*Comment{}
+	return &comment, nil
+}
+
+func (comment *Comment) Save() error {
+	tx, err := db.DB.Begin()
+
+	if err != nil {
+		return err
+	}
+
+	defer tx.Rollback()
+
+	if comment.ParentID != nil {
+		var parentEventId, parentRootId int64
+		var parentStatus string
+		err := tx.QueryRow("SELECT event_id, root_id, status FROM comments WHERE id = ?", *comment.ParentID).
+			Scan(&parentEventId, &parentRootId, &parentStatus)
+
+		if errors.Is(err, sql.ErrNoRows) {
+			return ErrInvalidParentComment
+		}
+
+		if err != nil {
+			return err
+		}
+
+		if parentEventId != comment.EventID || parentStatus != CommentStatusVisible {
+			return ErrInvalidParentComment
+		}
+
+		comment.RootID = parentRootId
+	}
+
+	comment.Status = CommentStatusVisible
+	comment.CreatedAt = time.Now().UTC()
+
+	result, err := tx.Exec(`INSERT INTO comments(event_id, user_id, parent_id, root_id, body, status, created_at)
+	VALUES (?, ?, ?, ?, ?, ?, ?)`,
+		comment.EventID, comment.UserID, comment.ParentID, comment.RootID, comment.Body, comment.Status, comment.CreatedAt)
+
+	if err != nil {
+		return err
+	}
+
+	comment.ID, err = result.LastInsertId()
+
+	if err != nil {
+		return err
+	}
+
+	// A top-level comment is the root of its own thread.
+	if comment.ParentID == nil {
+		comment.RootID = comment.ID
+		_, err = tx.Exec("UPDATE comments SET root_id = ? WHERE id = ?", comment.ID, comment.ID)
+
+		if err != nil {
+			return err
+		}
+	}
+
+	comment.Replies = []*Comment{}
+	return tx.Commit()
+}
+
+func GetCommentByID(commentId int64) (*Comment, error) {
+	query := "SELECT " + commentColumns + " FROM comments WHERE id = ?"
+	return scanComment(db.DB.QueryRow(query, commentId))
+}
+
+// GetCommentThreads returns one page of top-level comments on an event,
+// oldest first, each with its replies nested below it. total is the number of
+// top-level comments across all pages.
+func GetCommentThreads(eventId int64, limit, offset int) (threads []*Comment, total int, err error) {
+	err = db.DB.QueryRow("SELECT COUNT(*) FROM comments WHERE event_id = ? AND parent_id IS NULL", eventId).Scan(&total)
+
+	if err != nil {
+		return nil, 0, err
+	}
+
+	query := "SELECT " + commentColumns + ` FROM comments
+	WHERE root_id IN (
+		SELECT id FROM comments WHERE event_id = ? AND parent_id IS NULL ORDER BY id LIMIT ? OFFSET ?
+	)
+	ORDER BY id`
+	rows, err := db.DB.Query(query, eventId, limit, offset)
+
+	if err != nil {
+		return nil, 0, err
+	}
+
+	defer rows.Close()
+
+	threads = []*Comment{}
+	byId := map[int64]*Comment{}
+
+	for rows.Next() {
+		comment, err := scanComment(rows)
+
+		if err != nil {
+			return nil, 0, err
+		}
+
+		byId[comment.ID] = comment
+
+		// Rows come in id order, so a parent is always seen before its replies.
+		if comment.ParentID == nil {
+			threads = append(threads, comment)
+		} else if parent, ok := byId[*comment.ParentID]; ok {
+			parent.Replies = append(parent.Replies, comment)
+		}
+	}
+
+	return threads, total, rows.Err()
+}
+
+func (comment *Comment) UpdateBody(body string) error {
+	now := time.Now().UTC()
+	_, err := db.DB.Exec("UPDATE comments SET body = ?, updated_at = ? WHERE id = ?", body, now, comment.ID)
+
+	if err != nil {
+		return err
+	}
+
+	comment.Body = body
+	comment.UpdatedAt = &now
+	return nil
+}
+
+// Withdraw hides a comment with the given status. The row is kept so that
+// replies below it stay attached to the thread.
+func (comment *Comment) Withdraw(status string) error {
+	now := time.Now().UTC()
+	_, err := db.DB.Exec("UPDATE comments SET status = ?, updated_at = ? WHERE id = ?", status, now, comment.ID)
+
+	if err != nil {
+		return err
+	}
+
+	comment.Status = status
+	comment.Body = ""
+	comment.UpdatedAt = &now
+	return nil
+}
+
+func deleteComments(q querier, eventId int64) error {
+	stmt, err := q.Prepare("DELETE FROM comments WHERE event_id = ?")
+
+	if err != nil {
+		return err
+	}
+
+	defer stmt.Close()
+
+	_, err = stmt.Exec(eventId)
+	return err
+}
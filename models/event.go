@@ -1,30 +1,163 @@
 package models
 
 import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
 	"time"
 
+	"github.com/gin-gonic/gin/binding"
+	"github.com/go-playground/validator/v10"
 	"github.com/salads-source/go_http_server/db"
 )
 
 type Event struct {
-	ID          int64
-	Name        string    `binding:"required"`
-	Description string    `binding:"required"`
-	Location    string    `binding:"required"`
-	DateTime    time.Time `binding:"required"`
-	UserID      int64
+	ID              int64
+	Name            string    `binding:"required"`
+	Description     string    `binding:"required"`
+	Location        string    `binding:"required_without=VenueID"`
+	DateTime        time.Time `binding:"required"`
+	EndDateTime     *time.Time
+	DurationMinutes int `binding:"omitempty,min=1"`
+	AllDay          bool
+	VenueID         *int64
+	RoomID          *int64
+	Capacity        int `binding:"min=0"`
+	// MaxGuests is how many guests each registrant may bring.
+	MaxGuests int `binding:"min=0"`
+	// CancellationCutoffMinutes closes cancellations and guest changes that
+	// long before the start. Zero leaves them open.
+	CancellationCutoffMinutes int `binding:"min=0"`
+	// RequiresApproval holds new registrations as pending until the
+	// organizer approves them.
+	RequiresApproval bool
+	// AllowTransfers lets registrants hand their place to someone else.
+	AllowTransfers bool
+	// UserID is the organizer. Responses show the Organizer profile
+	// instead.
+	UserID                 int64 `json:"-"`
+	Organizer              *PublicProfile
+	Visibility             string `binding:"omitempty,oneof=public unlisted private"`
+	CommentsRegisteredOnly bool
+	FeedbackCheckedInOnly  bool
+	CommentCount           int64
 }
 
+// eventColumns lists the columns scanEvent expects, for a query that aliases
+// the events table as e.
+const eventColumns = `e.id, e.name, e.description, e.location, e.dateTime, e.end_dateTime, e.all_day,
+	e.venue_id, e.room_id, e.capacity, e.max_guests, e.cancellation_cutoff_minutes, e.requires_approval, e.allow_transfers,
+	e.user_id, e.visibility, e.comments_registered_only, e.feedback_checked_in_only,
+	(SELECT COUNT(*) FROM comments c WHERE c.event_id = e.id AND c.status = 'visible')`
+
+func scanEvent(row interface{ Scan(...any) error }) (Event, error) {
+	var event Event
+	var endDateTime sql.NullTime
+	var venueId, roomId sql.NullInt64
+	err := row.Scan(&event.ID, &event.Name, &event.Description, &event.Location, &event.DateTime, &endDateTime, &event.AllDay,
+		&venueId, &roomId, &event.Capacity, &event.MaxGuests, &event.CancellationCutoffMinutes, &event.RequiresApproval, &event.AllowTransfers, &event.UserID, &event.Visibility, &event.CommentsRegisteredOnly, &event.FeedbackCheckedInOnly, &event.CommentCount)
+
+	if endDateTime.Valid {
+		event.EndDateTime = &endDateTime.Time
+		event.DurationMinutes = int(endDateTime.Time.Sub(event.DateTime).Minutes())
+	}
+
+	if venueId.Valid {
+		event.VenueID = &venueId.Int64
+	}
+
+	if roomId.Valid {
+		event.RoomID = &roomId.Int64
+	}
+
+	return event, err
+}
+
+// Registration is a user's place at an event. For events that require
+// approval it starts out pending, and StatusReason explains a rejection.
 type Registration struct {
-	ID      int64
-	EventID int64
-	UserID  int64
+	ID           int64
+	EventID      int64
+	UserID       int64
+	Status       string
+	StatusReason string
+	DecidedAt    *time.Time
+	CheckedInAt  *time.Time
+	Guests       []Guest
+}
+
+var (
+	ErrNotRegistered     = errors.New("user is not registered for this event")
+	ErrEventFull         = errors.New("event is full")
+	ErrNotApproved       = errors.New("registration is not approved")
+	ErrAlreadyRegistered = errors.New("user is already registered for this event")
+)
+
+// querier is satisfied by both *sql.DB and *sql.Tx, so model methods can
+// run standalone or as part of a larger transaction.
+type querier interface {
+	Prepare(query string) (*sql.Stmt, error)
+	Exec(query string, args ...any) (sql.Result, error)
+	QueryRow(query string, args ...any) *sql.Row
+}
+
+// Validate applies the same binding rules gin uses when a request body is
+// bound to an Event, for events that arrive through other paths, and then
+// normalizes the schedule.
+func (event *Event) Validate() error {
+	err := binding.Validator.ValidateStruct(event)
+
+	var validationErrors validator.ValidationErrors
+	if errors.As(err, &validationErrors) && len(validationErrors) > 0 {
+		fieldError := validationErrors[0]
+		return fmt.Errorf("%s is %s", fieldError.Field(), fieldError.Tag())
+	}
+
+	if err != nil {
+		return err
+	}
+
+	return event.NormalizeSchedule()
 }
 
+// Save inserts the event and records it as version 1 of its history, with
+// the organizer as the actor.
 func (event *Event) Save() error {
-	query := `INSERT INTO events(name, description, location, dateTime, user_id)
-	VALUES (?, ?, ?, ?, ?)`
-	stmt, err := db.DB.Prepare(query)
+	tx, err := db.DB.Begin()
+
+	if err != nil {
+		return err
+	}
+
+	defer tx.Rollback()
+
+	err = event.save(tx)
+
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func (event *Event) save(q querier) error {
+	if event.Visibility == "" {
+		event.Visibility = VisibilityPublic
+	}
+
+	err := event.bookVenue(q)
+
+	if err != nil {
+		return err
+	}
+
+	query := `INSERT INTO events(name, description, location, dateTime, end_dateTime, all_day, venue_id, room_id, capacity,
+		max_guests, cancellation_cutoff_minutes, requires_approval, allow_transfers, user_id, visibility, comments_registered_only,
+		feedback_checked_in_only)
+	VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`
+	stmt, err := q.Prepare(query)
 
 	if err != nil {
 		return err
@@ -32,20 +165,27 @@ func (event *Event) Save() error {
 
 	defer stmt.Close()
 
-	result, err := stmt.Exec(event.Name, event.Description, event.Location, event.DateTime, event.UserID)
+	result, err := stmt.Exec(event.Name, event.Description, event.Location, event.DateTime, event.EndDateTime, event.AllDay,
+		event.VenueID, event.RoomID, event.Capacity, event.MaxGuests, event.CancellationCutoffMinutes, event.RequiresApproval, event.AllowTransfers, event.UserID, event.Visibility, event.CommentsRegisteredOnly, event.FeedbackCheckedInOnly)
 
 	if err != nil {
 		return err
 	}
 
 	id, err := result.LastInsertId()
+
+	if err != nil {
+		return err
+	}
+
 	event.ID = id
-	return err
+	return recordEventVersion(q, *event, EventActionCreate, event.UserID)
 }
 
-func GetAllEvents() ([]Event, error) {
-	query := "SELECT * FROM events"
-	rows, err := db.DB.Query(query)
+func GetAllEvents(filter EventFilter) ([]Event, error) {
+	where, args := filter.where("e")
+	query := "SELECT " + eventColumns + " FROM events e" + where
+	rows, err := db.DB.Query(query, args...)
 
 	if err != nil {
 		return nil, err
@@ -56,8 +196,7 @@ func GetAllEvents() ([]Event, error) {
 	defer rows.Close()
 
 	for rows.Next() {
-		var event Event
-		err := rows.Scan(&event.ID, &event.Name, &event.Description, &event.Location, &event.DateTime, &event.UserID)
+		event, err := scanEvent(rows)
 
 		if err != nil {
 			return nil, err
@@ -70,12 +209,10 @@ func GetAllEvents() ([]Event, error) {
 }
 
 func GetEventByID(eventId int64) (*Event, error) {
-	query := "SELECT * FROM events WHERE id = ?"
+	query := "SELECT " + eventColumns + " FROM events e WHERE e.id = ?"
 	row := db.DB.QueryRow(query, eventId)
 
-	var event Event
-
-	err := row.Scan(&event.ID, &event.Name, &event.Description, &event.Location, &event.DateTime, &event.UserID)
+	event, err := scanEvent(row)
 
 	if err != nil {
 		return nil, err
@@ -84,14 +221,35 @@ func GetEventByID(eventId int64) (*Event, error) {
 	return &event, nil
 }
 
-func (event Event) Update() error {
+// Update saves the editable fields of the event and records the result as a
+// new version made by actorId. An empty Visibility keeps the current one, so
+// clients that don't know about it can't make a private event public by
+// accident.
+func (event Event) Update(actorId int64) error {
+	tx, err := db.DB.Begin()
+
+	if err != nil {
+		return err
+	}
+
+	defer tx.Rollback()
+
+	err = event.bookVenue(tx)
+
+	if err != nil {
+		return err
+	}
+
 	query := `
 	UPDATE events
-	SET name = ?, description = ?, location = ?, dateTime = ?
+	SET name = ?, description = ?, location = ?, dateTime = ?, end_dateTime = ?, all_day = ?,
+		venue_id = ?, room_id = ?, capacity = ?, max_guests = ?, cancellation_cutoff_minutes = ?, requires_approval = ?, allow_transfers = ?,
+		visibility = COALESCE(NULLIF(?, ''), visibility),
+		comments_registered_only = ?, feedback_checked_in_only = ?
 	WHERE id = ?
 	`
 
-	stmt, err := db.DB.Prepare(query)
+	stmt, err := tx.Prepare(query)
 
 	if err != nil {
 		return err
@@ -99,15 +257,96 @@ func (event Event) Update() error {
 
 	defer stmt.Close()
 
-	_, err = stmt.Exec(event.Name, event.Description, event.Location, event.DateTime, event.ID)
+	_, err = stmt.Exec(event.Name, event.Description, event.Location, event.DateTime, event.EndDateTime, event.AllDay,
+		event.VenueID, event.RoomID, event.Capacity, event.MaxGuests, event.CancellationCutoffMinutes, event.RequiresApproval, event.AllowTransfers, event.Visibility, event.CommentsRegisteredOnly, event.FeedbackCheckedInOnly, event.ID)
 
-	return err
+	if err != nil {
+		return err
+	}
+
+	updated, err := scanEvent(tx.QueryRow("SELECT "+eventColumns+" FROM events e WHERE e.id = ?", event.ID))
+
+	if err != nil {
+		return err
+	}
+
+	err = recordEventVersion(tx, updated, EventActionUpdate, actorId)
+
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// Delete removes the event together with its attachment records, comments,
+// feedback, invitations, registration form and registrations, and records the deletion by
+// actorId in the event's history. Callers are responsible for removing the
+// attachment blobs from storage.
+func (event Event) Delete(actorId int64) error {
+	tx, err := db.DB.Begin()
+
+	if err != nil {
+		return err
+	}
+
+	defer tx.Rollback()
+
+	err = event.delete(tx, actorId)
+
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
 }
 
-func (event Event) Delete() error {
+func (event Event) delete(q querier, actorId int64) error {
+	err := recordEventVersion(q, event, EventActionDelete, actorId)
+
+	if err != nil {
+		return err
+	}
+
+	err = deleteAttachments(q, "event_id = ?", event.ID)
+
+	if err != nil {
+		return err
+	}
+
+	err = deleteComments(q, event.ID)
+
+	if err != nil {
+		return err
+	}
+
+	err = deleteFeedback(q, event.ID)
+
+	if err != nil {
+		return err
+	}
+
+	err = deleteInvitations(q, event.ID)
+
+	if err != nil {
+		return err
+	}
+
+	err = deleteQuestions(q, event.ID)
+
+	if err != nil {
+		return err
+	}
+
+	err = deleteRegistrations(q, event.ID)
+
+	if err != nil {
+		return err
+	}
+
 	query := "DELETE FROM events WHERE id = ?"
 
-	stmt, err := db.DB.Prepare(query)
+	stmt, err := q.Prepare(query)
 
 	if err != nil {
 		return err
@@ -116,13 +355,52 @@ func (event Event) Delete() error {
 	defer stmt.Close()
 
 	_, err = stmt.Exec(event.ID)
+	return err
+}
+
+// deleteRegistrations removes the places at the event, with their guests
+// and any offers to hand them on, as nothing is left to register for.
+func deleteRegistrations(q querier, eventId int64) error {
+	err := deleteGuests(q, "event_id = ?", eventId)
+
+	if err != nil {
+		return err
+	}
+
+	_, err = q.Exec("DELETE FROM registrations WHERE event_id = ?", eventId)
+
+	if err != nil {
+		return err
+	}
 
+	_, err = q.Exec("DELETE FROM registration_transfers WHERE event_id = ?", eventId)
 	return err
 }
 
-func GetAllRegistrations() ([]Registration, error) {
-	query := "SELECT * FROM registrations"
-	rows, err := db.DB.Query(query)
+const registrationColumns = "r.id, r.event_id, r.user_id, r.status, r.status_reason, r.decided_at, r.checked_in_at"
+
+func scanRegistration(row interface{ Scan(...any) error }) (Registration, error) {
+	var registration Registration
+	var decidedAt, checkedInAt sql.NullTime
+
+	err := row.Scan(&registration.ID, &registration.EventID, &registration.UserID, &registration.Status, &registration.StatusReason,
+		&decidedAt, &checkedInAt)
+
+	if decidedAt.Valid {
+		registration.DecidedAt = &decidedAt.Time
+	}
+
+	if checkedInAt.Valid {
+		registration.CheckedInAt = &checkedInAt.Time
+	}
+
+	return registration, err
+}
+
+func GetAllRegistrations(filter RegistrationFilter) ([]Registration, error) {
+	where, args := filter.where("r")
+	query := "SELECT " + registrationColumns + " FROM registrations r" + where
+	rows, err := db.DB.Query(query, args...)
 
 	if err != nil {
 		return nil, err
@@ -133,8 +411,7 @@ func GetAllRegistrations() ([]Registration, error) {
 	var registrations []Registration
 
 	for rows.Next() {
-		var registration Registration
-		err := rows.Scan(&registration.ID, &registration.EventID, &registration.UserID)
+		registration, err := scanRegistration(rows)
 
 		if err != nil {
 			return nil, err
@@ -143,43 +420,222 @@ func GetAllRegistrations() ([]Registration, error) {
 		registrations = append(registrations, registration)
 	}
 
+	err = attachGuests(registrations)
+
+	if err != nil {
+		return nil, err
+	}
+
 	return registrations, nil
 }
 
 func GetRegistrationById(registrationId int64) (Registration, error) {
-	query := "SELECT * FROM registrations WHERE id = ?"
+	query := "SELECT " + registrationColumns + " FROM registrations r WHERE r.id = ?"
 
 	row := db.DB.QueryRow(query, registrationId)
 
-	var registration Registration
-
-	err := row.Scan(&registration.ID, &registration.EventID, &registration.UserID)
+	registration, err := scanRegistration(row)
 
 	if err != nil {
 		return Registration{}, err
 	}
 
-	return registration, nil
+	registrations := []Registration{registration}
+	err = attachGuests(registrations)
+	return registrations[0], err
 }
 
-func (event Event) Register(userId int64) error {
-	query := "INSERT INTO registrations(event_id, user_id) VALUES (?, ?)"
-	stmt, err := db.DB.Prepare(query)
+// GetRegistration returns the registration of userId for eventId, or
+// ErrNotRegistered if there is none.
+func GetRegistration(eventId, userId int64) (*Registration, error) {
+	query := "SELECT " + registrationColumns + " FROM registrations r WHERE r.event_id = ? AND r.user_id = ?"
+
+	registration, err := scanRegistration(db.DB.QueryRow(query, eventId, userId))
+
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrNotRegistered
+	}
 
 	if err != nil {
-		return err
+		return nil, err
+	}
+
+	registrations := []Registration{registration}
+	err = attachGuests(registrations)
+	return &registrations[0], err
+}
+
+// UserRegistration is a registration together with the event it is for.
+type UserRegistration struct {
+	Registration
+	Event Event
+}
+
+// GetUserRegistrations returns the registrations of userId with their
+// events, soonest event first.
+func GetUserRegistrations(userId int64) ([]UserRegistration, error) {
+	registrations, err := GetAllRegistrations(RegistrationFilter{UserID: userId})
+
+	if err != nil {
+		return nil, err
+	}
+
+	query := "SELECT " + eventColumns + " FROM events e WHERE e.id IN (SELECT event_id FROM registrations WHERE user_id = ?)"
+	events, err := queryEvents(db.DB, query, userId)
+
+	if err != nil {
+		return nil, err
+	}
+
+	byId := map[int64]Event{}
+
+	for _, event := range events {
+		byId[event.ID] = event
+	}
+
+	result := []UserRegistration{}
+
+	for _, registration := range registrations {
+		event, ok := byId[registration.EventID]
+
+		if !ok {
+			continue
+		}
+
+		result = append(result, UserRegistration{Registration: registration, Event: event})
+	}
+
+	slices.SortStableFunc(result, func(a, b UserRegistration) int {
+		return a.Event.DateTime.Compare(b.Event.DateTime)
+	})
+
+	return result, nil
+}
+
+// IsRegistered reports whether userId holds an approved registration for
+// the event.
+func IsRegistered(eventId, userId int64) (bool, error) {
+	query := "SELECT EXISTS(SELECT 1 FROM registrations WHERE event_id = ? AND user_id = ? AND status = 'approved')"
+
+	var registered bool
+	err := db.DB.QueryRow(query, eventId, userId).Scan(&registered)
+	return registered, err
+}
+
+// Register adds userId and their guests to the event, with their answers to
+// its registration form, which the caller has checked with ValidateAnswers.
+// Guests count against the capacity, and ErrEventFull is returned when the
+// registration does not fit. For events that require approval the
+// registration is pending and only takes up places once it is approved.
+// It returns the status of the new registration.
+func (event Event) Register(userId int64, answers Answers, guests []Guest) (string, error) {
+	if len(guests) > event.MaxGuests {
+		return "", ErrTooManyGuests
+	}
+
+	status := RegistrationStatusApproved
+
+	if event.RequiresApproval {
+		status = RegistrationStatusPending
+	}
+
+	tx, err := db.DB.Begin()
+
+	if err != nil {
+		return "", err
+	}
+
+	defer tx.Rollback()
+
+	_, err = event.register(tx, userId, status, answers, guests)
+
+	if err != nil {
+		return "", err
+	}
+
+	return status, tx.Commit()
+}
+
+// register inserts a registration with the given status inside a
+// transaction, refusing duplicates with ErrAlreadyRegistered and checking
+// the capacity once approved registrations are in. It returns the new
+// registration's id.
+func (event Event) register(q querier, userId int64, status string, answers Answers, guests []Guest) (int64, error) {
+	encoded, err := json.Marshal(answers)
+
+	if err != nil {
+		return 0, err
+	}
+
+	var registered bool
+	err = q.QueryRow("SELECT EXISTS(SELECT 1 FROM registrations WHERE event_id = ? AND user_id = ?)", event.ID, userId).Scan(&registered)
+
+	if err != nil {
+		return 0, err
+	}
+
+	if registered {
+		return 0, ErrAlreadyRegistered
+	}
+
+	stmt, err := q.Prepare("INSERT INTO registrations(event_id, user_id, answers, status) VALUES (?, ?, ?, ?)")
+
+	if err != nil {
+		return 0, err
 	}
 
 	defer stmt.Close()
 
-	_, err = stmt.Exec(event.ID, userId)
+	result, err := stmt.Exec(event.ID, userId, string(encoded), status)
 
-	return err
+	if err != nil {
+		return 0, err
+	}
+
+	registrationId, err := result.LastInsertId()
+
+	if err != nil {
+		return 0, err
+	}
+
+	for i := range guests {
+		guests[i].RegistrationID = registrationId
+		err = guests[i].save(q)
+
+		if err != nil {
+			return 0, err
+		}
+	}
+
+	if status == RegistrationStatusApproved {
+		err = checkCapacity(q, event.ID)
+
+		if err != nil {
+			return 0, err
+		}
+	}
+
+	return registrationId, nil
 }
 
+// CancelRegistration removes the registration of userId and their guests.
 func (e Event) CancelRegistration(userId int64) error {
+	tx, err := db.DB.Begin()
+
+	if err != nil {
+		return err
+	}
+
+	defer tx.Rollback()
+
+	err = deleteGuests(tx, "event_id = ? AND user_id = ?", e.ID, userId)
+
+	if err != nil {
+		return err
+	}
+
 	query := "DELETE FROM registrations WHERE event_id = ? AND user_id = ?"
-	stmt, err := db.DB.Prepare(query)
+	stmt, err := tx.Prepare(query)
 
 	if err != nil {
 		return err
@@ -189,5 +645,33 @@ func (e Event) CancelRegistration(userId int64) error {
 
 	_, err = stmt.Exec(e.ID, userId)
 
-	return err
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// CheckIn records that a registered user arrived at the event. Checking in
+// twice keeps the first time, and registrations that are not approved
+// return ErrNotApproved.
+func (event Event) CheckIn(userId int64) (*Registration, error) {
+	_, err := db.DB.Exec("UPDATE registrations SET checked_in_at = ? WHERE event_id = ? AND user_id = ? AND status = 'approved' AND checked_in_at IS NULL",
+		time.Now().UTC(), event.ID, userId)
+
+	if err != nil {
+		return nil, err
+	}
+
+	registration, err := GetRegistration(event.ID, userId)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if registration.Status != RegistrationStatusApproved {
+		return nil, ErrNotApproved
+	}
+
+	return registration, nil
 }
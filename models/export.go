@@ -0,0 +1,146 @@
+package models
+
+import (
+	"database/sql"
+	"strconv"
+	"time"
+
+	"github.com/salads-source/go_http_server/db"
+)
+
+// EmailAccess decides whose email addresses an export may include. A viewer
+// sees their own email and, for registrations, the emails of people
+// registered for events they organize. All exposes every email and is meant
+// for operators running the CLI.
+type EmailAccess struct {
+	ViewerID int64
+	All      bool
+}
+
+type EventExportRow struct {
+	ID             int64     `parquet:"id"`
+	Name           string    `parquet:"name"`
+	Description    string    `parquet:"description"`
+	Location       string    `parquet:"location"`
+	DateTime       time.Time `parquet:"date_time,timestamp(millisecond)"`
+	UserID         int64     `parquet:"user_id"`
+	OrganizerEmail string    `parquet:"organizer_email"`
+}
+
+type RegistrationExportRow struct {
+	ID            int64     `parquet:"id"`
+	EventID       int64     `parquet:"event_id"`
+	EventName     string    `parquet:"event_name"`
+	EventDateTime time.Time `parquet:"event_date_time,timestamp(millisecond)"`
+	UserID        int64     `parquet:"user_id"`
+	UserEmail     string    `parquet:"user_email"`
+}
+
+func (EventExportRow) CSVHeader() []string {
+	return []string{"id", "name", "description", "location", "date_time", "user_id", "organizer_email"}
+}
+
+func (row EventExportRow) CSVRecord() []string {
+	return []string{
+		strconv.FormatInt(row.ID, 10),
+		row.Name,
+		row.Description,
+		row.Location,
+		row.DateTime.Format(time.RFC3339),
+		strconv.FormatInt(row.UserID, 10),
+		row.OrganizerEmail,
+	}
+}
+
+func (RegistrationExportRow) CSVHeader() []string {
+	return []string{"id", "event_id", "event_name", "event_date_time", "user_id", "user_email"}
+}
+
+func (row RegistrationExportRow) CSVRecord() []string {
+	return []string{
+		strconv.FormatInt(row.ID, 10),
+		strconv.FormatInt(row.EventID, 10),
+		row.EventName,
+		row.EventDateTime.Format(time.RFC3339),
+		strconv.FormatInt(row.UserID, 10),
+		row.UserEmail,
+	}
+}
+
+// StreamEvents calls fn for every event matching filter, one row at a time,
+// so callers can export arbitrarily many events in constant memory.
+func StreamEvents(filter EventFilter, access EmailAccess, fn func(EventExportRow) error) error {
+	where, args := filter.where("e")
+	query := `
+	SELECT e.id, e.name, e.description, e.location, e.dateTime, e.user_id,
+		CASE WHEN ? OR e.user_id = ? THEN COALESCE(u.email, '') ELSE '' END
+	FROM events e
+	LEFT JOIN users u ON u.id = e.user_id` + where + `
+	ORDER BY e.id`
+
+	rows, err := db.DB.Query(query, append([]any{access.All, access.ViewerID}, args...)...)
+
+	if err != nil {
+		return err
+	}
+
+	defer rows.Close()
+
+	for rows.Next() {
+		var row EventExportRow
+		err := rows.Scan(&row.ID, &row.Name, &row.Description, &row.Location, &row.DateTime, &row.UserID, &row.OrganizerEmail)
+
+		if err != nil {
+			return err
+		}
+
+		err = fn(row)
+
+		if err != nil {
+			return err
+		}
+	}
+
+	return rows.Err()
+}
+
+// StreamRegistrations is the registration counterpart of StreamEvents. A
+// registrant's email is visible to themselves and to the event's organizer.
+func StreamRegistrations(filter RegistrationFilter, access EmailAccess, fn func(RegistrationExportRow) error) error {
+	where, args := filter.where("r")
+	query := `
+	SELECT r.id, r.event_id, COALESCE(e.name, ''), e.dateTime, r.user_id,
+		CASE WHEN ? OR r.user_id = ? OR e.user_id = ? THEN COALESCE(u.email, '') ELSE '' END
+	FROM registrations r
+	LEFT JOIN events e ON e.id = r.event_id
+	LEFT JOIN users u ON u.id = r.user_id` + where + `
+	ORDER BY r.id`
+
+	rows, err := db.DB.Query(query, append([]any{access.All, access.ViewerID, access.ViewerID}, args...)...)
+
+	if err != nil {
+		return err
+	}
+
+	defer rows.Close()
+
+	for rows.Next() {
+		var row RegistrationExportRow
+		var eventDateTime sql.NullTime
+		err := rows.Scan(&row.ID, &row.EventID, &row.EventName, &eventDateTime, &row.UserID, &row.UserEmail)
+
+		if err != nil {
+			return err
+		}
+
+		row.EventDateTime = eventDateTime.Time
+
+		err = fn(row)
+
+		if err != nil {
+			return err
+		}
+	}
+
+	return rows.Err()
+}
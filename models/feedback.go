@@ -0,0 +1,183 @@
+package models
+
+import (
+	"errors"
+	"time"
+
+	"github.com/mattn/go-sqlite3"
+	"github.com/salads-source/go_http_server/db"
+)
+
+var ErrFeedbackExists = errors.New("feedback already submitted for this event")
+
+type Feedback struct {
+	ID        int64
+	EventID   int64
+	UserID    int64
+	Rating    int    `binding:"required,min=1,max=5"`
+	Review    string `binding:"max=5000"`
+	CreatedAt time.Time
+}
+
+// FeedbackSummary aggregates ratings. Distribution maps each rating from 1
+// to 5 to the number of times it was given.
+type FeedbackSummary struct {
+	Count        int
+	Average      float64
+	Distribution map[int]int
+}
+
+type EventFeedbackSummary struct {
+	EventID   int64
+	EventName string
+	FeedbackSummary
+}
+
+func newFeedbackSummary() FeedbackSummary {
+	return FeedbackSummary{Distribution: map[int]int{1: 0, 2: 0, 3: 0, 4: 0, 5: 0}}
+}
+
+func (summary *FeedbackSummary) add(rating, count int) {
+	total := summary.Average * float64(summary.Count)
+	summary.Distribution[rating] += count
+	summary.Count += count
+	summary.Average = (total + float64(rating*count)) / float64(summary.Count)
+}
+
+func (feedback *Feedback) Save() error {
+	query := "INSERT INTO feedback(event_id, user_id, rating, review, created_at) VALUES (?, ?, ?, ?, ?)"
+	stmt, err := db.DB.Prepare(query)
+
+	if err != nil {
+		return err
+	}
+
+	defer stmt.Close()
+
+	feedback.CreatedAt = time.Now().UTC()
+	result, err := stmt.Exec(feedback.EventID, feedback.UserID, feedback.Rating, feedback.Review, feedback.CreatedAt)
+
+	var sqliteErr sqlite3.Error
+	if errors.As(err, &sqliteErr) && sqliteErr.ExtendedCode == sqlite3.ErrConstraintUnique {
+		return ErrFeedbackExists
+	}
+
+	if err != nil {
+		return err
+	}
+
+	feedback.ID, err = result.LastInsertId()
+	return err
+}
+
+func GetEventFeedback(eventId int64) ([]Feedback, error) {
+	query := "SELECT id, event_id, user_id, rating, review, created_at FROM feedback WHERE event_id = ? ORDER BY id"
+	rows, err := db.DB.Query(query, eventId)
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	feedback := []Feedback{}
+
+	for rows.Next() {
+		var entry Feedback
+		err := rows.Scan(&entry.ID, &entry.EventID, &entry.UserID, &entry.Rating, &entry.Review, &entry.CreatedAt)
+
+		if err != nil {
+			return nil, err
+		}
+
+		feedback = append(feedback, entry)
+	}
+
+	return feedback, rows.Err()
+}
+
+func GetEventFeedbackSummary(eventId int64) (FeedbackSummary, error) {
+	summary := newFeedbackSummary()
+	rows, err := db.DB.Query("SELECT rating, COUNT(*) FROM feedback WHERE event_id = ? GROUP BY rating", eventId)
+
+	if err != nil {
+		return summary, err
+	}
+
+	defer rows.Close()
+
+	for rows.Next() {
+		var rating, count int
+		err := rows.Scan(&rating, &count)
+
+		if err != nil {
+			return summary, err
+		}
+
+		summary.add(rating, count)
+	}
+
+	return summary, rows.Err()
+}
+
+// GetOrganizerFeedbackSummary aggregates the feedback on every event userId
+// organizes, both overall and per event. Events without feedback are
+// included with a zero count.
+func GetOrganizerFeedbackSummary(userId int64) (FeedbackSummary, []EventFeedbackSummary, error) {
+	overall := newFeedbackSummary()
+	query := `
+	SELECT e.id, e.name, f.rating, COUNT(f.id)
+	FROM events e
+	LEFT JOIN feedback f ON f.event_id = e.id
+	WHERE e.user_id = ?
+	GROUP BY e.id, f.rating
+	ORDER BY e.id`
+	rows, err := db.DB.Query(query, userId)
+
+	if err != nil {
+		return overall, nil, err
+	}
+
+	defer rows.Close()
+
+	events := []EventFeedbackSummary{}
+
+	for rows.Next() {
+		var eventId int64
+		var eventName string
+		var rating *int
+		var count int
+
+		err := rows.Scan(&eventId, &eventName, &rating, &count)
+
+		if err != nil {
+			return overall, nil, err
+		}
+
+		if len(events) == 0 || events[len(events)-1].EventID != eventId {
+			events = append(events, EventFeedbackSummary{EventID: eventId, EventName: eventName, FeedbackSummary: newFeedbackSummary()})
+		}
+
+		if rating == nil {
+			continue
+		}
+
+		events[len(events)-1].add(*rating, count)
+		overall.add(*rating, count)
+	}
+
+	return overall, events, rows.Err()
+}
+
+func deleteFeedback(q querier, eventId int64) error {
+	stmt, err := q.Prepare("DELETE FROM feedback WHERE event_id = ?")
+
+	if err != nil {
+		return err
+	}
+
+	defer stmt.Close()
+
+	_, err = stmt.Exec(eventId)
+	return err
+}
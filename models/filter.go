@@ -0,0 +1,97 @@
+package models
+
+import (
+	"strings"
+	"time"
+)
+
+// EventFilter narrows event listings and exports. Zero values are ignored.
+// Listings only include the events ViewerID may see in a list (see
+// listableCondition); a zero ViewerID is an anonymous caller. AllVisibilities
+// turns that check off for operator tools.
+type EventFilter struct {
+	From            time.Time
+	To              time.Time
+	UserID          int64
+	Location        string
+	ViewerID        int64
+	AllVisibilities bool
+}
+
+// RegistrationFilter narrows registration listings and exports. Zero values
+// are ignored. A ViewerID limits the results to that user's own
+// registrations and the registrations for events they organize.
+type RegistrationFilter struct {
+	EventID  int64
+	UserID   int64
+	Status   string
+	ViewerID int64
+}
+
+func (filter EventFilter) where(alias string) (string, []any) {
+	var conditions []string
+	var args []any
+
+	if !filter.AllVisibilities {
+		condition, conditionArgs := listableCondition(alias, filter.ViewerID)
+		conditions = append(conditions, condition)
+		args = append(args, conditionArgs...)
+	}
+
+	if !filter.From.IsZero() {
+		conditions = append(conditions, "julianday("+alias+".dateTime) >= julianday(?)")
+		args = append(args, filter.From.UTC())
+	}
+
+	if !filter.To.IsZero() {
+		conditions = append(conditions, "julianday("+alias+".dateTime) <= julianday(?)")
+		args = append(args, filter.To.UTC())
+	}
+
+	if filter.UserID != 0 {
+		conditions = append(conditions, alias+".user_id = ?")
+		args = append(args, filter.UserID)
+	}
+
+	if filter.Location != "" {
+		conditions = append(conditions, alias+".location LIKE ?")
+		args = append(args, "%"+filter.Location+"%")
+	}
+
+	return joinConditions(conditions), args
+}
+
+func (filter RegistrationFilter) where(alias string) (string, []any) {
+	var conditions []string
+	var args []any
+
+	if filter.EventID != 0 {
+		conditions = append(conditions, alias+".event_id = ?")
+		args = append(args, filter.EventID)
+	}
+
+	if filter.UserID != 0 {
+		conditions = append(conditions, alias+".user_id = ?")
+		args = append(args, filter.UserID)
+	}
+
+	if filter.Status != "" {
+		conditions = append(conditions, alias+".status = ?")
+		args = append(args, filter.Status)
+	}
+
+	if filter.ViewerID != 0 {
+		conditions = append(conditions, "("+alias+".user_id = ? OR EXISTS (SELECT 1 FROM events ve WHERE ve.id = "+alias+".event_id AND ve.user_id = ?))")
+		args = append(args, filter.ViewerID, filter.ViewerID)
+	}
+
+	return joinConditions(conditions), args
+}
+
+func joinConditions(conditions []string) string {
+	if len(conditions) == 0 {
+		return ""
+	}
+
+	return " WHERE " + strings.Join(conditions, " AND ")
+}
@@ -0,0 +1,194 @@
+package models
+
+import (
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/salads-source/go_http_server/db"
+)
+
+var (
+	ErrTooManyGuests = errors.New("too many guests for this event")
+	ErrGuestNotFound = errors.New("guest not found")
+)
+
+// Guest is a named person brought along by a registrant. Guests do not need
+// an account but count against the event's capacity.
+type Guest struct {
+	ID             int64
+	RegistrationID int64
+	Name           string `binding:"required,max=100"`
+	Email          string `binding:"omitempty,email"`
+	CreatedAt      time.Time
+}
+
+// RegistrationsLocked reports whether registrations can no longer be
+// cancelled or have their guests changed. Without a cutoff they stay open.
+func (event Event) RegistrationsLocked(now time.Time) bool {
+	if event.CancellationCutoffMinutes == 0 {
+		return false
+	}
+
+	cutoff := event.DateTime.Add(-time.Duration(event.CancellationCutoffMinutes) * time.Minute)
+	return !now.Before(cutoff)
+}
+
+// checkCapacity returns ErrEventFull when the approved registrations and
+// their guests exceed the event's capacity. It runs after the change it checks, in the
+// same transaction, so concurrent registrations cannot both take the last
+// place.
+func checkCapacity(q querier, eventId int64) error {
+	query := `
+	SELECT e.capacity,
+		(SELECT COUNT(*) FROM registrations r WHERE r.event_id = e.id AND r.status = 'approved')
+		+ (SELECT COUNT(*) FROM registration_guests g JOIN registrations r ON r.id = g.registration_id
+			WHERE r.event_id = e.id AND r.status = 'approved')
+	FROM events e WHERE e.id = ?`
+
+	var capacity, attendees int
+	err := q.QueryRow(query, eventId).Scan(&capacity, &attendees)
+
+	if err != nil {
+		return err
+	}
+
+	if capacity > 0 && attendees > capacity {
+		return ErrEventFull
+	}
+
+	return nil
+}
+
+func (guest *Guest) save(q querier) error {
+	guest.CreatedAt = time.Now().UTC()
+	result, err := q.Exec("INSERT INTO registration_guests(registration_id, name, email, created_at) VALUES (?, ?, ?, ?)",
+		guest.RegistrationID, guest.Name, guest.Email, guest.CreatedAt)
+
+	if err != nil {
+		return err
+	}
+
+	guest.ID, err = result.LastInsertId()
+	return err
+}
+
+// AddGuest adds a guest to the registration, within the event's guest
+// limit and capacity.
+func (event Event) AddGuest(registration Registration, guest *Guest) error {
+	tx, err := db.DB.Begin()
+
+	if err != nil {
+		return err
+	}
+
+	defer tx.Rollback()
+
+	var guests int
+	err = tx.QueryRow("SELECT COUNT(*) FROM registration_guests WHERE registration_id = ?", registration.ID).Scan(&guests)
+
+	if err != nil {
+		return err
+	}
+
+	if guests >= event.MaxGuests {
+		return ErrTooManyGuests
+	}
+
+	guest.RegistrationID = registration.ID
+	err = guest.save(tx)
+
+	if err != nil {
+		return err
+	}
+
+	err = checkCapacity(tx, event.ID)
+
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// RemoveGuest removes a guest of the registration, or returns
+// ErrGuestNotFound if it has no such guest.
+func (registration Registration) RemoveGuest(guestId int64) error {
+	result, err := db.DB.Exec("DELETE FROM registration_guests WHERE id = ? AND registration_id = ?", guestId, registration.ID)
+
+	if err != nil {
+		return err
+	}
+
+	removed, err := result.RowsAffected()
+
+	if err != nil {
+		return err
+	}
+
+	if removed == 0 {
+		return ErrGuestNotFound
+	}
+
+	return nil
+}
+
+// guestBatchSize keeps the number of query parameters in attachGuests well
+// under SQLite's limit.
+const guestBatchSize = 500
+
+// attachGuests loads the guests of each registration, so attendee lists can
+// show them under the person who registered them.
+func attachGuests(registrations []Registration) error {
+	byId := map[int64]*Registration{}
+
+	for i := range registrations {
+		registrations[i].Guests = []Guest{}
+		byId[registrations[i].ID] = &registrations[i]
+	}
+
+	for start := 0; start < len(registrations); start += guestBatchSize {
+		batch := registrations[start:min(start+guestBatchSize, len(registrations))]
+		args := make([]any, len(batch))
+
+		for i, registration := range batch {
+			args[i] = registration.ID
+		}
+
+		placeholders := strings.Repeat(",?", len(batch))[1:]
+		query := "SELECT id, registration_id, name, email, created_at FROM registration_guests WHERE registration_id IN (" +
+			placeholders + ") ORDER BY id"
+		rows, err := db.DB.Query(query, args...)
+
+		if err != nil {
+			return err
+		}
+
+		for rows.Next() {
+			var guest Guest
+			err := rows.Scan(&guest.ID, &guest.RegistrationID, &guest.Name, &guest.Email, &guest.CreatedAt)
+
+			if err != nil {
+				rows.Close()
+				return err
+			}
+
+			registration := byId[guest.RegistrationID]
+			registration.Guests = append(registration.Guests, guest)
+		}
+
+		err = rows.Err()
+		rows.Close()
+
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func deleteGuests(q querier, registrationWhere string, args ...any) error {
+	_, err := q.Exec("DELETE FROM registration_guests WHERE registration_id IN (SELECT id FROM registrations WHERE "+registrationWhere+")", args...)
+	return err
+}
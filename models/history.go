@@ -0,0 +1,215 @@
+package models
+
+import (
+	"bytes"
+	"encoding/json"
+	"reflect"
+	"time"
+
+	"github.com/salads-source/go_http_server/db"
+)
+
+const (
+	EventActionCreate = "create"
+	EventActionUpdate = "update"
+	EventActionDelete = "delete"
+)
+
+// EventSnapshot holds the fields of an event that are tracked in its
+// history. It is stored as JSON so older versions stay readable when fields
+// are added.
+type EventSnapshot struct {
+	Name                      string
+	Description               string
+	Location                  string
+	DateTime                  time.Time
+	EndDateTime               *time.Time
+	AllDay                    bool
+	VenueID                   *int64
+	RoomID                    *int64
+	Capacity                  int
+	MaxGuests                 int
+	CancellationCutoffMinutes int
+	RequiresApproval          bool
+	AllowTransfers            bool
+	// UserID is the organizer. It is kept in storage, but responses show the
+	// Organizer profile instead.
+	UserID                 int64          `json:"-"`
+	Organizer              *PublicProfile `json:",omitempty"`
+	Visibility             string
+	CommentsRegisteredOnly bool
+	FeedbackCheckedInOnly  bool
+}
+
+// storedSnapshot is how a snapshot is saved, with the organizer's ID that
+// responses leave out.
+type storedSnapshot struct {
+	EventSnapshot
+	UserID int64
+}
+
+// EventVersion is one entry in an event's history. Versions are numbered
+// from 1 per event, and a delete version keeps the last state of the event.
+type EventVersion struct {
+	EventID   int64
+	Version   int
+	Action    string
+	ActorID   int64
+	CreatedAt time.Time
+	Snapshot  EventSnapshot
+}
+
+// FieldChange describes one field that differs between two versions.
+type FieldChange struct {
+	Field string
+	From  any
+	To    any
+}
+
+func (event Event) snapshot() EventSnapshot {
+	return EventSnapshot{
+		Name:                      event.Name,
+		Description:               event.Description,
+		Location:                  event.Location,
+		DateTime:                  event.DateTime.UTC(),
+		EndDateTime:               utcTime(event.EndDateTime),
+		AllDay:                    event.AllDay,
+		VenueID:                   event.VenueID,
+		RoomID:                    event.RoomID,
+		Capacity:                  event.Capacity,
+		MaxGuests:                 event.MaxGuests,
+		CancellationCutoffMinutes: event.CancellationCutoffMinutes,
+		RequiresApproval:          event.RequiresApproval,
+		AllowTransfers:            event.AllowTransfers,
+		UserID:                    event.UserID,
+		Visibility:                event.Visibility,
+		CommentsRegisteredOnly:    event.CommentsRegisteredOnly,
+		FeedbackCheckedInOnly:     event.FeedbackCheckedInOnly,
+	}
+}
+
+// recordEventVersion appends the current state of event to its history. It
+// is meant to run in the same transaction as the change it records, so the
+// version numbers stay gapless.
+func recordEventVersion(q querier, event Event, action string, actorId int64) error {
+	stored := storedSnapshot{EventSnapshot: event.snapshot(), UserID: event.UserID}
+	snapshot, err := json.Marshal(stored)
+
+	if err != nil {
+		return err
+	}
+
+	var version int
+	err = q.QueryRow("SELECT COALESCE(MAX(version), 0) + 1 FROM event_versions WHERE event_id = ?", event.ID).Scan(&version)
+
+	if err != nil {
+		return err
+	}
+
+	_, err = q.Exec(`INSERT INTO event_versions(event_id, version, action, actor_id, snapshot, created_at)
+	VALUES (?, ?, ?, ?, ?, ?)`, event.ID, version, action, actorId, string(snapshot), time.Now().UTC())
+	return err
+}
+
+const eventVersionColumns = "event_id, version, action, actor_id, snapshot, created_at"
+
+func scanEventVersion(row interface{ Scan(...any) error }) (*EventVersion, error) {
+	var version EventVersion
+	var snapshot string
+
+	err := row.Scan(&version.EventID, &version.Version, &version.Action, &version.ActorID, &snapshot, &version.CreatedAt)
+
+	if err != nil {
+		return nil, err
+	}
+
+	var stored storedSnapshot
+	err = json.Unmarshal([]byte(snapshot), &stored)
+
+	if err != nil {
+		return nil, err
+	}
+
+	version.Snapshot = stored.EventSnapshot
+	version.Snapshot.UserID = stored.UserID
+
+	return &version, nil
+}
+
+// GetEventHistory returns every version of an event, oldest first. It still
+// works after the event has been deleted.
+func GetEventHistory(eventId int64) ([]EventVersion, error) {
+	query := "SELECT " + eventVersionColumns + " FROM event_versions WHERE event_id = ? ORDER BY version"
+	rows, err := db.DB.Query(query, eventId)
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	versions := []EventVersion{}
+
+	for rows.Next() {
+		version, err := scanEventVersion(rows)
+
+		if err != nil {
+			return nil, err
+		}
+
+		versions = append(versions, *version)
+	}
+
+	return versions, rows.Err()
+}
+
+func GetEventVersion(eventId int64, version int) (*EventVersion, error) {
+	query := "SELECT " + eventVersionColumns + " FROM event_versions WHERE event_id = ? AND version = ?"
+	return scanEventVersion(db.DB.QueryRow(query, eventId, version))
+}
+
+// GetLatestEventVersion returns the most recent version of an event, which
+// for a deleted event is its delete version.
+func GetLatestEventVersion(eventId int64) (*EventVersion, error) {
+	query := "SELECT " + eventVersionColumns + " FROM event_versions WHERE event_id = ? ORDER BY version DESC LIMIT 1"
+	return scanEventVersion(db.DB.QueryRow(query, eventId))
+}
+
+// DiffEventVersions lists the snapshot fields that differ between two
+// versions, in the order they are declared on EventSnapshot. Fields hidden
+// from responses are left out; a new organizer shows up as a change of
+// Organizer once the profiles are attached.
+func DiffEventVersions(from, to EventVersion) ([]FieldChange, error) {
+	changes := []FieldChange{}
+	fromValue := reflect.ValueOf(from.Snapshot)
+	toValue := reflect.ValueOf(to.Snapshot)
+
+	for _, field := range reflect.VisibleFields(fromValue.Type()) {
+		if field.Tag.Get("json") == "-" {
+			continue
+		}
+
+		before := fromValue.FieldByIndex(field.Index).Interface()
+		after := toValue.FieldByIndex(field.Index).Interface()
+
+		// Compare the values as they are stored, since == on time.Time also
+		// compares its monotonic reading and location.
+		beforeJSON, err := json.Marshal(before)
+
+		if err != nil {
+			return nil, err
+		}
+
+		afterJSON, err := json.Marshal(after)
+
+		if err != nil {
+			return nil, err
+		}
+
+		if !bytes.Equal(beforeJSON, afterJSON) {
+			changes = append(changes, FieldChange{Field: field.Name, From: before, To: after})
+		}
+	}
+
+	return changes, nil
+}
@@ -0,0 +1,287 @@
+package models
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/salads-source/go_http_server/db"
+)
+
+type ImportFormat string
+
+const (
+	ImportFormatCSV    ImportFormat = "csv"
+	ImportFormatNDJSON ImportFormat = "ndjson"
+)
+
+type ImportMode string
+
+const (
+	// ImportModeAtomic imports every row or none of them.
+	ImportModeAtomic ImportMode = "atomic"
+	// ImportModeBestEffort imports the valid rows and reports the rest.
+	ImportModeBestEffort ImportMode = "best_effort"
+)
+
+type ImportRowError struct {
+	Row     int
+	Message string
+}
+
+type ImportResult struct {
+	Mode     ImportMode
+	DryRun   bool
+	Total    int
+	Valid    int
+	Imported int
+	Errors   []ImportRowError
+	EventIDs []int64
+}
+
+type importRow struct {
+	row   int
+	event Event
+	err   error
+}
+
+var csvColumns = []string{"name", "description", "location", "datetime"}
+
+func ParseImportFormat(value string) (ImportFormat, error) {
+	switch strings.ToLower(strings.TrimSpace(value)) {
+	case "csv", "text/csv":
+		return ImportFormatCSV, nil
+	case "ndjson", "jsonl", "application/x-ndjson", "application/ndjson", "application/jsonl":
+		return ImportFormatNDJSON, nil
+	}
+
+	return "", fmt.Errorf("unsupported import format %q", value)
+}
+
+func ParseImportMode(value string) (ImportMode, error) {
+	switch ImportMode(strings.ToLower(strings.TrimSpace(value))) {
+	case "", ImportModeAtomic:
+		return ImportModeAtomic, nil
+	case ImportModeBestEffort:
+		return ImportModeBestEffort, nil
+	}
+
+	return "", fmt.Errorf("unsupported import mode %q", value)
+}
+
+// ImportEvents reads events in the given format and saves them as owned by
+// userId. CSV rows are numbered from 1 after the header and NDJSON rows by
+// line. The returned error is only set when the input as a whole could not be
+// processed; per-row problems are reported in ImportResult.Errors.
+func ImportEvents(r io.Reader, format ImportFormat, mode ImportMode, dryRun bool, userId int64) (ImportResult, error) {
+	result := ImportResult{Mode: mode, DryRun: dryRun}
+
+	var rows []importRow
+	var err error
+
+	switch format {
+	case ImportFormatCSV:
+		rows, err = readCSVRows(r)
+	case ImportFormatNDJSON:
+		rows, err = readNDJSONRows(r)
+	default:
+		err = fmt.Errorf("unsupported import format %q", format)
+	}
+
+	if err != nil {
+		return result, err
+	}
+
+	result.Total = len(rows)
+
+	for i := range rows {
+		if rows[i].err == nil {
+			rows[i].event.UserID = userId
+			rows[i].err = rows[i].event.Validate()
+		}
+
+		if rows[i].err != nil {
+			result.Errors = append(result.Errors, ImportRowError{Row: rows[i].row, Message: rows[i].err.Error()})
+			continue
+		}
+
+		result.Valid++
+	}
+
+	if dryRun {
+		return result, nil
+	}
+
+	if mode == ImportModeBestEffort {
+		for i := range rows {
+			if rows[i].err != nil {
+				continue
+			}
+
+			err := rows[i].event.Save()
+
+			if err != nil {
+				result.Errors = append(result.Errors, ImportRowError{Row: rows[i].row, Message: saveErrorMessage(err)})
+				continue
+			}
+
+			result.Imported++
+			result.EventIDs = append(result.EventIDs, rows[i].event.ID)
+		}
+
+		return result, nil
+	}
+
+	if len(result.Errors) > 0 {
+		return result, nil
+	}
+
+	tx, err := db.DB.Begin()
+
+	if err != nil {
+		return result, err
+	}
+
+	defer tx.Rollback()
+
+	for i := range rows {
+		err := rows[i].event.save(tx)
+
+		if err != nil {
+			result.Errors = append(result.Errors, ImportRowError{Row: rows[i].row, Message: saveErrorMessage(err)})
+			return result, nil
+		}
+	}
+
+	err = tx.Commit()
+
+	if err != nil {
+		return result, err
+	}
+
+	for i := range rows {
+		result.EventIDs = append(result.EventIDs, rows[i].event.ID)
+	}
+
+	result.Imported = len(rows)
+	return result, nil
+}
+
+func readCSVRows(r io.Reader) ([]importRow, error) {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+
+	header, err := reader.Read()
+
+	if err == io.EOF {
+		return nil, errors.New("CSV input is empty")
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	columns := map[string]int{}
+	for i, name := range header {
+		name = strings.ToLower(strings.TrimSpace(name))
+		name = strings.NewReplacer("_", "", " ", "", "\ufeff", "").Replace(name)
+		columns[name] = i
+	}
+
+	for _, name := range csvColumns {
+		if _, ok := columns[name]; !ok {
+			return nil, fmt.Errorf("CSV header is missing the %q column", name)
+		}
+	}
+
+	field := func(record []string, name string) string {
+		i := columns[name]
+		if i >= len(record) {
+			return ""
+		}
+		return strings.TrimSpace(record[i])
+	}
+
+	var rows []importRow
+
+	for row := 1; ; row++ {
+		record, err := reader.Read()
+
+		if err == io.EOF {
+			break
+		}
+
+		if err != nil {
+			var parseErr *csv.ParseError
+			if errors.As(err, &parseErr) {
+				rows = append(rows, importRow{row: row, err: parseErr.Err})
+				continue
+			}
+			return nil, err
+		}
+
+		current := importRow{row: row}
+		current.event.Name = field(record, "name")
+		current.event.Description = field(record, "description")
+		current.event.Location = field(record, "location")
+
+		if value := field(record, "datetime"); value != "" {
+			current.event.DateTime, current.err = time.Parse(time.RFC3339, value)
+			if current.err != nil {
+				current.err = fmt.Errorf("DateTime %q is not an RFC 3339 timestamp", value)
+			}
+		}
+
+		rows = append(rows, current)
+	}
+
+	return rows, nil
+}
+
+func readNDJSONRows(r io.Reader) ([]importRow, error) {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+
+	var rows []importRow
+
+	for row := 1; scanner.Scan(); row++ {
+		line := bytes.TrimSpace(scanner.Bytes())
+
+		if len(line) == 0 {
+			continue
+		}
+
+		current := importRow{row: row}
+
+		err := json.Unmarshal(line, &current.event)
+
+		if err != nil {
+			current.err = errors.New("row is not a valid JSON event")
+		}
+
+		// IDs and owners are assigned on import, never taken from the input.
+		current.event.ID = 0
+		current.event.UserID = 0
+
+		rows = append(rows, current)
+	}
+
+	return rows, scanner.Err()
+}
+
+// saveErrorMessage explains why a row could not be saved, without leaking
+// details of unexpected database errors.
+func saveErrorMessage(err error) string {
+	if errors.Is(err, ErrRoomBooked) || errors.Is(err, ErrInvalidVenue) || errors.Is(err, ErrInvalidRoom) {
+		return err.Error()
+	}
+
+	return "could not save event"
+}
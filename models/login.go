@@ -0,0 +1,303 @@
+package models
+
+import (
+	"database/sql"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/salads-source/go_http_server/db"
+	"github.com/salads-source/go_http_server/utils"
+)
+
+const (
+	// LoginOutcomePending is an attempt whose password is still being
+	// checked. It counts as a failure until then, so guesses made in
+	// parallel are throttled like ones made one after another.
+	LoginOutcomePending = "pending"
+	LoginOutcomeSuccess = "success"
+	LoginOutcomeFailure = "failure"
+	// LoginOutcomeThrottled is an attempt refused without checking the
+	// password.
+	LoginOutcomeThrottled = "throttled"
+	// LoginOutcomeUnlocked marks an account unlocked by email, which resets
+	// its failure count like a successful login does.
+	LoginOutcomeUnlocked = "unlocked"
+)
+
+// Failures against an account count since its last successful login or
+// unlock; failures from an IP address count within ipFailureWindow, so one
+// address cannot guess at many accounts either. After a few failures each
+// further one doubles the wait before the next attempt.
+const (
+	loginBackoffBase       = time.Second
+	accountBackoffAfter    = 3
+	ipBackoffAfter         = 20
+	ipFailureWindow        = 15 * time.Minute
+	maxIPBackoff           = 15 * time.Minute
+	loginHistoryRetention  = 90 * 24 * time.Hour
+	loginHistoryLimit      = 100
+	AccountLockoutAfter    = 10
+	AccountLockoutDuration = time.Hour
+	AccountUnlockLifetime  = 24 * time.Hour
+)
+
+var ErrInvalidUnlockToken = errors.New("invalid or expired unlock token")
+
+// LoginAttempt is one entry of a user's login history.
+type LoginAttempt struct {
+	ID        int64
+	UserID    *int64 `json:"-"`
+	Email     string
+	IPAddress string
+	UserAgent string
+	Outcome   string
+	CreatedAt time.Time
+}
+
+// LoginThrottle says how long someone has to wait before trying to log in
+// again. Locked is set when the wait is an account lockout.
+type LoginThrottle struct {
+	RetryAfter time.Duration
+	Locked     bool
+}
+
+func loginKey(email string) string {
+	return strings.ToLower(strings.TrimSpace(email))
+}
+
+// loginBackoff is the wait after failures, once there are at least after
+// of them, up to limit.
+func loginBackoff(failures, after int, limit time.Duration) time.Duration {
+	if failures < after {
+		return 0
+	}
+
+	// Past this the wait has long reached any sensible maximum; stop
+	// before the shift overflows.
+	if failures-after > 30 {
+		return limit
+	}
+
+	return min(loginBackoffBase<<(failures-after), limit)
+}
+
+// lastFailure returns the number of failures, including pending attempts,
+// matching where and the time of the latest one.
+func lastFailure(where string, args ...any) (int, time.Time, error) {
+	var failures int
+	err := db.DB.QueryRow("SELECT COUNT(*) FROM login_attempts WHERE outcome IN ('failure', 'pending') AND "+where, args...).Scan(&failures)
+
+	if err != nil || failures == 0 {
+		return 0, time.Time{}, err
+	}
+
+	var latest time.Time
+	err = db.DB.QueryRow("SELECT created_at FROM login_attempts WHERE outcome IN ('failure', 'pending') AND "+where+" ORDER BY id DESC LIMIT 1", args...).
+		Scan(&latest)
+	return failures, latest, err
+}
+
+// accountFailures counts the failures on email up to the attempt with
+// throughId since its last successful login or unlock.
+func accountFailures(email string, throughId int64) (int, time.Time, error) {
+	return lastFailure(`email = ? AND id <= ? AND id > COALESCE((SELECT MAX(id) FROM login_attempts
+		WHERE email = ? AND id <= ? AND outcome IN ('success', 'unlocked')), 0)`, email, throughId, email, throughId)
+}
+
+// StartLoginAttempt adds attempt to the login history as pending, linking
+// it to the account with its email if there is one, and returns how long
+// the attempt has to wait because of the ones before it. Attempts that have
+// to wait are recorded as throttled; the others are settled with
+// FinishLoginAttempt once the password is checked. Emails without an
+// account are throttled the same way, so the answer does not tell whether
+// one exists.
+func StartLoginAttempt(attempt *LoginAttempt) (LoginThrottle, error) {
+	var throttle LoginThrottle
+	attempt.Email = loginKey(attempt.Email)
+	attempt.Outcome = LoginOutcomePending
+	attempt.CreatedAt = time.Now().UTC()
+
+	var userId int64
+	err := db.DB.QueryRow("SELECT id FROM users WHERE LOWER(email) = ? AND placeholder = 0", attempt.Email).Scan(&userId)
+
+	if err == nil {
+		attempt.UserID = &userId
+	} else if !errors.Is(err, sql.ErrNoRows) {
+		return throttle, err
+	}
+
+	// Recording the attempt before looking at the earlier ones means each
+	// of several parallel attempts sees the others.
+	result, err := db.DB.Exec("INSERT INTO login_attempts(user_id, email, ip_address, user_agent, outcome, created_at) VALUES (?, ?, ?, ?, ?, ?)",
+		attempt.UserID, attempt.Email, attempt.IPAddress, attempt.UserAgent, attempt.Outcome, attempt.CreatedAt)
+
+	if err != nil {
+		return throttle, err
+	}
+
+	attempt.ID, err = result.LastInsertId()
+
+	if err != nil {
+		return throttle, err
+	}
+
+	now := attempt.CreatedAt
+	failures, latest, err := accountFailures(attempt.Email, attempt.ID-1)
+
+	if err != nil {
+		return throttle, err
+	}
+
+	if failures >= AccountLockoutAfter {
+		throttle.RetryAfter = latest.Add(AccountLockoutDuration).Sub(now)
+		throttle.Locked = throttle.RetryAfter > 0
+	} else {
+		throttle.RetryAfter = latest.Add(loginBackoff(failures, accountBackoffAfter, AccountLockoutDuration)).Sub(now)
+	}
+
+	failures, latest, err = lastFailure("ip_address = ? AND id < ? AND created_at > ?", attempt.IPAddress, attempt.ID, now.Add(-ipFailureWindow))
+
+	if err != nil {
+		return throttle, err
+	}
+
+	throttle.RetryAfter = max(throttle.RetryAfter, latest.Add(loginBackoff(failures, ipBackoffAfter, maxIPBackoff)).Sub(now), 0)
+
+	if throttle.RetryAfter > 0 {
+		_, err = FinishLoginAttempt(attempt, LoginOutcomeThrottled)
+	}
+
+	return throttle, err
+}
+
+// FinishLoginAttempt records the outcome of an attempt begun with
+// StartLoginAttempt. When a failure locks an account it returns a token
+// that unlocks it, for the caller to email to the account's owner.
+func FinishLoginAttempt(attempt *LoginAttempt, outcome string) (string, error) {
+	// Waits run from when the password check ended, not from when it began.
+	now := time.Now().UTC()
+	_, err := db.DB.Exec("UPDATE login_attempts SET outcome = ?, created_at = ? WHERE id = ?", outcome, now, attempt.ID)
+
+	if err != nil {
+		return "", err
+	}
+
+	attempt.Outcome = outcome
+	attempt.CreatedAt = now
+
+	if outcome != LoginOutcomeFailure || attempt.UserID == nil {
+		return "", nil
+	}
+
+	failures, _, err := accountFailures(attempt.Email, attempt.ID)
+
+	// Attempts during a lockout are refused before they get here, and
+	// failures are only counted up to this one, so this sends one email per
+	// lockout and cannot be used to flood the owner's inbox.
+	if err != nil || failures < AccountLockoutAfter {
+		return "", err
+	}
+
+	token, err := utils.RandomToken(32)
+
+	if err != nil {
+		return "", err
+	}
+
+	_, err = db.DB.Exec("INSERT INTO account_unlocks(user_id, token_hash, created_at, expires_at) VALUES (?, ?, ?, ?)",
+		*attempt.UserID, hashToken(token), now, now.Add(AccountUnlockLifetime))
+
+	if err != nil {
+		return "", err
+	}
+
+	return token, nil
+}
+
+// PruneLoginAttempts removes login history older than it is kept for,
+// returning how many attempts went.
+func PruneLoginAttempts() (int64, error) {
+	result, err := db.DB.Exec("DELETE FROM login_attempts WHERE created_at < ?", time.Now().UTC().Add(-loginHistoryRetention))
+
+	if err != nil {
+		return 0, err
+	}
+
+	return result.RowsAffected()
+}
+
+// UnlockAccount lifts the lockout of the owner of token and resets its
+// failure count. All of the user's unlock tokens stop working.
+func UnlockAccount(token string) error {
+	tx, err := db.DB.Begin()
+
+	if err != nil {
+		return err
+	}
+
+	defer tx.Rollback()
+
+	now := time.Now().UTC()
+
+	var userId int64
+	var email string
+	err = tx.QueryRow(`SELECT u.id, u.email FROM account_unlocks a JOIN users u ON u.id = a.user_id
+	WHERE a.token_hash = ? AND a.used_at IS NULL AND a.expires_at > ?`, hashToken(token), now).Scan(&userId, &email)
+
+	if errors.Is(err, sql.ErrNoRows) {
+		return ErrInvalidUnlockToken
+	}
+
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.Exec("UPDATE account_unlocks SET used_at = ? WHERE user_id = ? AND used_at IS NULL", now, userId)
+
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.Exec("INSERT INTO login_attempts(user_id, email, outcome, created_at) VALUES (?, ?, ?, ?)",
+		userId, loginKey(email), LoginOutcomeUnlocked, now)
+
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// GetLoginHistory returns the latest login attempts on the account of
+// userId, newest first.
+func GetLoginHistory(userId int64) ([]LoginAttempt, error) {
+	query := `
+	SELECT id, email, ip_address, user_agent, outcome, created_at
+	FROM login_attempts
+	WHERE user_id = ?
+	ORDER BY id DESC
+	LIMIT ?`
+	rows, err := db.DB.Query(query, userId, loginHistoryLimit)
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	attempts := []LoginAttempt{}
+
+	for rows.Next() {
+		attempt := LoginAttempt{UserID: &userId}
+		err := rows.Scan(&attempt.ID, &attempt.Email, &attempt.IPAddress, &attempt.UserAgent, &attempt.Outcome, &attempt.CreatedAt)
+
+		if err != nil {
+			return nil, err
+		}
+
+		attempts = append(attempts, attempt)
+	}
+
+	return attempts, rows.Err()
+}
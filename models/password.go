@@ -0,0 +1,185 @@
+package models
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/salads-source/go_http_server/utils"
+)
+
+// maxPasswordBytes is as much as bcrypt looks at.
+const maxPasswordBytes = 72
+
+const (
+	PasswordTooShort      = "too_short"
+	PasswordTooLong       = "too_long"
+	PasswordBanned        = "banned"
+	PasswordContainsEmail = "contains_email"
+	PasswordBreached      = "breached"
+)
+
+// PasswordViolation is one way a password fails the policy. Code is stable
+// for clients to act on; Message is for people.
+type PasswordViolation struct {
+	Code    string
+	Message string
+}
+
+// PasswordPolicyError lists everything wrong with a password, so it can be
+// fixed in one go.
+type PasswordPolicyError struct {
+	Violations []PasswordViolation
+}
+
+func (err *PasswordPolicyError) Error() string {
+	var messages []string
+
+	for _, violation := range err.Violations {
+		messages = append(messages, violation.Message)
+	}
+
+	return "password does not meet the policy: " + strings.Join(messages, "; ")
+}
+
+// PasswordPolicy is what new passwords are checked against at signup,
+// password change and reset.
+type PasswordPolicy struct {
+	MinLength int
+	// Banned holds forbidden passwords in lower case; they are matched
+	// ignoring case.
+	Banned map[string]bool
+	// RejectEmail forbids passwords containing the account's email
+	// address or the part of it before the @.
+	RejectEmail bool
+	// Breached, when set, is searched for passwords known from breaches.
+	Breached *utils.BreachedPasswords
+}
+
+// Passwords is the policy in force. It is set from the PASSWORD_MIN_LENGTH,
+// PASSWORD_BANNED_FILE and BREACHED_PASSWORDS_FILE environment variables.
+var Passwords = PasswordPolicy{MinLength: 8, RejectEmail: true}
+
+// LoadPasswordPolicy builds a policy from its settings. minLength may be
+// empty for the default; bannedFile is a list of passwords, one per line;
+// breachedFile is a sorted hash file for utils.OpenBreachedPasswords. Empty
+// paths turn those checks off.
+func LoadPasswordPolicy(minLength, bannedFile, breachedFile string) (PasswordPolicy, error) {
+	policy := Passwords
+
+	if minLength != "" {
+		length, err := strconv.Atoi(minLength)
+
+		if err != nil || length < 1 || length > maxPasswordBytes {
+			return policy, fmt.Errorf("invalid minimum password length %q, use a number from 1 to %d", minLength, maxPasswordBytes)
+		}
+
+		policy.MinLength = length
+	}
+
+	if bannedFile != "" {
+		banned, err := readBannedPasswords(bannedFile)
+
+		if err != nil {
+			return policy, fmt.Errorf("could not read banned passwords: %w", err)
+		}
+
+		policy.Banned = banned
+	}
+
+	if breachedFile != "" {
+		breached, err := utils.OpenBreachedPasswords(breachedFile)
+
+		if err != nil {
+			return policy, fmt.Errorf("could not open breached passwords: %w", err)
+		}
+
+		policy.Breached = breached
+	}
+
+	return policy, nil
+}
+
+func readBannedPasswords(path string) (map[string]bool, error) {
+	file, err := os.Open(path)
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer file.Close()
+
+	banned := map[string]bool{}
+	scanner := bufio.NewScanner(file)
+
+	for scanner.Scan() {
+		password := strings.TrimSpace(scanner.Text())
+
+		if password != "" {
+			banned[strings.ToLower(password)] = true
+		}
+	}
+
+	return banned, scanner.Err()
+}
+
+// Check returns a *PasswordPolicyError if password is not allowed for the
+// account with email.
+func (policy PasswordPolicy) Check(password, email string) error {
+	var violations []PasswordViolation
+
+	if utf8.RuneCountInString(password) < policy.MinLength {
+		violations = append(violations, PasswordViolation{PasswordTooShort,
+			fmt.Sprintf("must be at least %d characters long", policy.MinLength)})
+	}
+
+	if len(password) > maxPasswordBytes {
+		violations = append(violations, PasswordViolation{PasswordTooLong,
+			fmt.Sprintf("must be at most %d bytes long", maxPasswordBytes)})
+	}
+
+	lower := strings.ToLower(password)
+
+	if policy.Banned[lower] {
+		violations = append(violations, PasswordViolation{PasswordBanned, "is too common"})
+	}
+
+	if policy.RejectEmail && containsEmail(lower, email) {
+		violations = append(violations, PasswordViolation{PasswordContainsEmail, "must not contain your email address"})
+	}
+
+	if policy.Breached != nil {
+		breached, err := policy.Breached.Contains(password)
+
+		if err != nil {
+			return err
+		}
+
+		if breached {
+			violations = append(violations, PasswordViolation{PasswordBreached, "has appeared in a data breach"})
+		}
+	}
+
+	if len(violations) > 0 {
+		return &PasswordPolicyError{Violations: violations}
+	}
+
+	return nil
+}
+
+// containsEmail reports whether password contains email or its local part.
+// Very short local parts are ignored, as they turn up in passwords by
+// chance.
+func containsEmail(password, email string) bool {
+	email = strings.ToLower(strings.TrimSpace(email))
+	local, _, _ := strings.Cut(email, "@")
+
+	if email != "" && strings.Contains(password, email) {
+		return true
+	}
+
+	return len(local) >= 3 && strings.Contains(password, local)
+}
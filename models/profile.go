@@ -0,0 +1,200 @@
+package models
+
+import (
+	"errors"
+	"net/url"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/salads-source/go_http_server/db"
+)
+
+var (
+	ErrInvalidTimeZone  = errors.New("time zone must be an IANA name such as Europe/Paris")
+	ErrInvalidLocale    = errors.New("locale must be a language tag such as en or pt-BR")
+	ErrInvalidAvatarURL = errors.New("avatar must be an http or https URL")
+)
+
+var localePattern = regexp.MustCompile(`^[a-zA-Z]{2,3}(-[a-zA-Z0-9]{2,8})*$`)
+
+// PublicProfile is what other users can see of someone, for example of an
+// event's organizer.
+type PublicProfile struct {
+	ID          int64
+	DisplayName string
+	Bio         string
+	AvatarURL   string
+}
+
+// Profile is a user's own view of their account.
+type Profile struct {
+	PublicProfile
+	Email         string
+	TimeZone      string
+	Locale        string
+	Role          string
+	EmailVerified bool
+}
+
+// ProfileUpdate holds the profile fields to change; nil fields are left as
+// they are.
+type ProfileUpdate struct {
+	DisplayName *string `binding:"omitempty,max=100"`
+	Bio         *string `binding:"omitempty,max=1000"`
+	AvatarURL   *string `binding:"omitempty,max=500"`
+	TimeZone    *string
+	Locale      *string
+}
+
+const profileColumns = "id, display_name, bio, avatar_url, email, time_zone, locale, role, email_verified"
+
+// GetProfile returns the profile of userId, or sql.ErrNoRows.
+func GetProfile(userId int64) (*Profile, error) {
+	var profile Profile
+	err := db.DB.QueryRow("SELECT "+profileColumns+" FROM users WHERE id = ?", userId).Scan(&profile.ID, &profile.DisplayName,
+		&profile.Bio, &profile.AvatarURL, &profile.Email, &profile.TimeZone, &profile.Locale, &profile.Role, &profile.EmailVerified)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &profile, nil
+}
+
+// UpdateProfile validates and applies update to userId's profile and
+// returns the result.
+func UpdateProfile(userId int64, update ProfileUpdate) (*Profile, error) {
+	if update.TimeZone != nil && *update.TimeZone != "" {
+		// LoadLocation also accepts "Local", which means nothing to anyone
+		// but this server.
+		_, err := time.LoadLocation(*update.TimeZone)
+
+		if err != nil || *update.TimeZone == "Local" {
+			return nil, ErrInvalidTimeZone
+		}
+	}
+
+	if update.Locale != nil && *update.Locale != "" && !localePattern.MatchString(*update.Locale) {
+		return nil, ErrInvalidLocale
+	}
+
+	if update.AvatarURL != nil && *update.AvatarURL != "" {
+		avatar, err := url.Parse(*update.AvatarURL)
+
+		if err != nil || (avatar.Scheme != "http" && avatar.Scheme != "https") || avatar.Host == "" {
+			return nil, ErrInvalidAvatarURL
+		}
+	}
+
+	var sets []string
+	var args []any
+
+	for column, value := range map[string]*string{
+		"display_name": update.DisplayName,
+		"bio":          update.Bio,
+		"avatar_url":   update.AvatarURL,
+		"time_zone":    update.TimeZone,
+		"locale":       update.Locale,
+	} {
+		if value != nil {
+			sets = append(sets, column+" = ?")
+			args = append(args, strings.TrimSpace(*value))
+		}
+	}
+
+	if len(sets) > 0 {
+		_, err := db.DB.Exec("UPDATE users SET "+strings.Join(sets, ", ")+" WHERE id = ?", append(args, userId)...)
+
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return GetProfile(userId)
+}
+
+// AttachOrganizers fills in the Organizer of each event with one query.
+func AttachOrganizers(events ...*Event) error {
+	var ids []int64
+
+	for _, event := range events {
+		ids = append(ids, event.UserID)
+	}
+
+	profiles, err := getPublicProfiles(ids)
+
+	if err != nil {
+		return err
+	}
+
+	for _, event := range events {
+		event.Organizer = profiles[event.UserID]
+	}
+
+	return nil
+}
+
+// AttachSnapshotOrganizers fills in the Organizer of each version's snapshot
+// with one query.
+func AttachSnapshotOrganizers(versions ...*EventVersion) error {
+	var ids []int64
+
+	for _, version := range versions {
+		ids = append(ids, version.Snapshot.UserID)
+	}
+
+	profiles, err := getPublicProfiles(ids)
+
+	if err != nil {
+		return err
+	}
+
+	for _, version := range versions {
+		version.Snapshot.Organizer = profiles[version.Snapshot.UserID]
+	}
+
+	return nil
+}
+
+// getPublicProfiles looks up the profiles of the given users, by ID.
+func getPublicProfiles(userIds []int64) (map[int64]*PublicProfile, error) {
+	profiles := map[int64]*PublicProfile{}
+
+	if len(userIds) == 0 {
+		return profiles, nil
+	}
+
+	ids := map[int64]bool{}
+	var placeholders []string
+	var args []any
+
+	for _, id := range userIds {
+		if !ids[id] {
+			ids[id] = true
+			placeholders = append(placeholders, "?")
+			args = append(args, id)
+		}
+	}
+
+	rows, err := db.DB.Query("SELECT id, display_name, bio, avatar_url FROM users WHERE id IN ("+strings.Join(placeholders, ", ")+")", args...)
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	for rows.Next() {
+		var profile PublicProfile
+		err := rows.Scan(&profile.ID, &profile.DisplayName, &profile.Bio, &profile.AvatarURL)
+
+		if err != nil {
+			return nil, err
+		}
+
+		profiles[profile.ID] = &profile
+	}
+
+	return profiles, rows.Err()
+}
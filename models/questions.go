@@ -0,0 +1,483 @@
+package models
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/salads-source/go_http_server/db"
+)
+
+const (
+	QuestionTypeText        = "text"
+	QuestionTypeChoice      = "choice"
+	QuestionTypeMultiChoice = "multi_choice"
+	QuestionTypeNumber      = "number"
+)
+
+// maxTextAnswerLength bounds free-text answers.
+const maxTextAnswerLength = 1000
+
+var questionKeyPattern = regexp.MustCompile(`^[a-z0-9_]+$`)
+
+// Answers maps question keys to answers: a string for text and choice
+// questions, a list of strings for multi-choice questions and a number for
+// number questions.
+type Answers map[string]any
+
+// Question is one field of an event's registration form. Answers are keyed
+// by Key, which must be unique within the form. A question with ShowIf is
+// only asked when the earlier question it names was answered with one of
+// the given values.
+type Question struct {
+	ID       int64
+	EventID  int64
+	Key      string `binding:"required,max=50"`
+	Label    string `binding:"required,max=200"`
+	Type     string `binding:"required,oneof=text choice multi_choice number"`
+	Required bool
+	Options  []string
+	Min      *float64
+	Max      *float64
+	ShowIf   *QuestionCondition
+}
+
+type QuestionCondition struct {
+	Key    string   `binding:"required"`
+	Values []string `binding:"required,min=1"`
+}
+
+// AnswerExportRow is one registration's answers. The CSV columns follow the
+// order of the event's questions.
+type AnswerExportRow struct {
+	RegistrationID int64   `json:"registration_id"`
+	UserID         int64   `json:"user_id"`
+	UserEmail      string  `json:"user_email"`
+	Answers        Answers `json:"answers"`
+	keys           []string
+}
+
+func (row AnswerExportRow) CSVHeader() []string {
+	return append([]string{"registration_id", "user_id", "user_email"}, row.keys...)
+}
+
+// AnswerExportHeader is the CSV header of the answers to a form with
+// questions, whether or not anyone has answered yet.
+func AnswerExportHeader(questions []Question) []string {
+	return AnswerExportRow{keys: questionKeys(questions)}.CSVHeader()
+}
+
+func questionKeys(questions []Question) []string {
+	keys := make([]string, len(questions))
+
+	for i, question := range questions {
+		keys[i] = question.Key
+	}
+
+	return keys
+}
+
+func (row AnswerExportRow) CSVRecord() []string {
+	record := []string{strconv.FormatInt(row.RegistrationID, 10), strconv.FormatInt(row.UserID, 10), row.UserEmail}
+
+	for _, key := range row.keys {
+		record = append(record, formatAnswer(row.Answers[key]))
+	}
+
+	return record
+}
+
+func formatAnswer(answer any) string {
+	switch value := answer.(type) {
+	case nil:
+		return ""
+	case string:
+		return value
+	case float64:
+		return strconv.FormatFloat(value, 'f', -1, 64)
+	case []any:
+		parts := make([]string, len(value))
+		for i, part := range value {
+			parts[i] = formatAnswer(part)
+		}
+		return strings.Join(parts, "; ")
+	}
+
+	return fmt.Sprint(answer)
+}
+
+// ValidateQuestions checks a form definition as a whole: keys are unique,
+// choice questions have options, and conditions point at an earlier choice
+// question and one of its options.
+func ValidateQuestions(questions []Question) error {
+	byKey := map[string]Question{}
+
+	for _, question := range questions {
+		if !questionKeyPattern.MatchString(question.Key) {
+			return fmt.Errorf("question key %q may only contain lowercase letters, digits and underscores", question.Key)
+		}
+
+		if _, ok := byKey[question.Key]; ok {
+			return fmt.Errorf("question key %q is used twice", question.Key)
+		}
+
+		isChoice := question.Type == QuestionTypeChoice || question.Type == QuestionTypeMultiChoice
+
+		if isChoice && len(question.Options) == 0 {
+			return fmt.Errorf("question %q needs at least one option", question.Key)
+		}
+
+		if !isChoice && len(question.Options) > 0 {
+			return fmt.Errorf("question %q cannot have options", question.Key)
+		}
+
+		if question.Type != QuestionTypeNumber && (question.Min != nil || question.Max != nil) {
+			return fmt.Errorf("question %q cannot have a minimum or maximum", question.Key)
+		}
+
+		if question.Min != nil && question.Max != nil && *question.Min > *question.Max {
+			return fmt.Errorf("question %q has a minimum above its maximum", question.Key)
+		}
+
+		if question.ShowIf != nil {
+			parent, ok := byKey[question.ShowIf.Key]
+
+			if !ok || (parent.Type != QuestionTypeChoice && parent.Type != QuestionTypeMultiChoice) {
+				return fmt.Errorf("question %q can only depend on an earlier choice question", question.Key)
+			}
+
+			for _, value := range question.ShowIf.Values {
+				if !slices.Contains(parent.Options, value) {
+					return fmt.Errorf("question %q depends on %q, which is not an option of %q", question.Key, value, parent.Key)
+				}
+			}
+		}
+
+		byKey[question.Key] = question
+	}
+
+	return nil
+}
+
+// SetQuestions replaces the registration form of the event. Answers already
+// given are kept under their keys.
+func (event Event) SetQuestions(questions []Question) error {
+	err := ValidateQuestions(questions)
+
+	if err != nil {
+		return err
+	}
+
+	tx, err := db.DB.Begin()
+
+	if err != nil {
+		return err
+	}
+
+	defer tx.Rollback()
+
+	_, err = tx.Exec("DELETE FROM registration_questions WHERE event_id = ?", event.ID)
+
+	if err != nil {
+		return err
+	}
+
+	for i := range questions {
+		question := &questions[i]
+		options, err := json.Marshal(question.Options)
+
+		if err != nil {
+			return err
+		}
+
+		var showIf []byte
+
+		if question.ShowIf != nil {
+			showIf, err = json.Marshal(question.ShowIf)
+
+			if err != nil {
+				return err
+			}
+		}
+
+		result, err := tx.Exec(`INSERT INTO registration_questions(event_id, position, key, label, type, required, options, min, max, show_if)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+			event.ID, i, question.Key, question.Label, question.Type, question.Required, string(options), question.Min, question.Max, nullableString(showIf))
+
+		if err != nil {
+			return err
+		}
+
+		question.ID, err = result.LastInsertId()
+
+		if err != nil {
+			return err
+		}
+
+		question.EventID = event.ID
+	}
+
+	return tx.Commit()
+}
+
+func nullableString(value []byte) sql.NullString {
+	return sql.NullString{String: string(value), Valid: value != nil}
+}
+
+// GetQuestions returns the registration form of an event in order.
+func GetQuestions(eventId int64) ([]Question, error) {
+	query := "SELECT id, event_id, key, label, type, required, options, min, max, show_if FROM registration_questions WHERE event_id = ? ORDER BY position"
+	rows, err := db.DB.Query(query, eventId)
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	questions := []Question{}
+
+	for rows.Next() {
+		var question Question
+		var options string
+		var min, max sql.NullFloat64
+		var showIf sql.NullString
+
+		err := rows.Scan(&question.ID, &question.EventID, &question.Key, &question.Label, &question.Type, &question.Required,
+			&options, &min, &max, &showIf)
+
+		if err != nil {
+			return nil, err
+		}
+
+		err = json.Unmarshal([]byte(options), &question.Options)
+
+		if err != nil {
+			return nil, err
+		}
+
+		if question.Options == nil {
+			question.Options = []string{}
+		}
+
+		if min.Valid {
+			question.Min = &min.Float64
+		}
+
+		if max.Valid {
+			question.Max = &max.Float64
+		}
+
+		if showIf.Valid {
+			question.ShowIf = &QuestionCondition{}
+			err = json.Unmarshal([]byte(showIf.String), question.ShowIf)
+
+			if err != nil {
+				return nil, err
+			}
+		}
+
+		questions = append(questions, question)
+	}
+
+	return questions, rows.Err()
+}
+
+// ValidateAnswers checks answers against a registration form and returns
+// them cleaned up. Answers to questions that are hidden by their condition
+// are dropped, and unknown keys are rejected.
+func ValidateAnswers(questions []Question, answers Answers) (Answers, error) {
+	known := map[string]bool{}
+	cleaned := Answers{}
+
+	for _, question := range questions {
+		known[question.Key] = true
+
+		if question.ShowIf != nil && !conditionMet(*question.ShowIf, cleaned[question.ShowIf.Key]) {
+			continue
+		}
+
+		answer, err := validateAnswer(question, answers[question.Key])
+
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", question.Key, err)
+		}
+
+		if answer != nil {
+			cleaned[question.Key] = answer
+		}
+	}
+
+	for key := range answers {
+		if !known[key] {
+			return nil, fmt.Errorf("%s: unknown question", key)
+		}
+	}
+
+	return cleaned, nil
+}
+
+func conditionMet(condition QuestionCondition, answer any) bool {
+	switch value := answer.(type) {
+	case string:
+		return slices.Contains(condition.Values, value)
+	case []any:
+		for _, part := range value {
+			if text, ok := part.(string); ok && slices.Contains(condition.Values, text) {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+func validateAnswer(question Question, answer any) (any, error) {
+	if text, ok := answer.(string); ok {
+		answer = strings.TrimSpace(text)
+	}
+
+	if answer == nil || answer == "" {
+		if question.Required {
+			return nil, errors.New("an answer is required")
+		}
+
+		return nil, nil
+	}
+
+	switch question.Type {
+	case QuestionTypeText:
+		text, ok := answer.(string)
+
+		if !ok {
+			return nil, errors.New("must be text")
+		}
+
+		if len(text) > maxTextAnswerLength {
+			return nil, fmt.Errorf("must be at most %d characters", maxTextAnswerLength)
+		}
+
+		return text, nil
+	case QuestionTypeChoice:
+		choice, ok := answer.(string)
+
+		if !ok || !slices.Contains(question.Options, choice) {
+			return nil, errors.New("must be one of the options")
+		}
+
+		return choice, nil
+	case QuestionTypeMultiChoice:
+		values, ok := answer.([]any)
+
+		if !ok {
+			return nil, errors.New("must be a list of options")
+		}
+
+		if len(values) == 0 {
+			if question.Required {
+				return nil, errors.New("an answer is required")
+			}
+
+			return nil, nil
+		}
+
+		seen := map[string]bool{}
+
+		for _, value := range values {
+			choice, ok := value.(string)
+
+			if !ok || !slices.Contains(question.Options, choice) {
+				return nil, errors.New("must only contain the options")
+			}
+
+			if seen[choice] {
+				return nil, errors.New("must not repeat an option")
+			}
+
+			seen[choice] = true
+		}
+
+		return values, nil
+	case QuestionTypeNumber:
+		number, ok := answer.(float64)
+
+		if !ok {
+			return nil, errors.New("must be a number")
+		}
+
+		if question.Min != nil && number < *question.Min {
+			return nil, fmt.Errorf("must be at least %v", *question.Min)
+		}
+
+		if question.Max != nil && number > *question.Max {
+			return nil, fmt.Errorf("must be at most %v", *question.Max)
+		}
+
+		return number, nil
+	}
+
+	return nil, fmt.Errorf("unknown question type %q", question.Type)
+}
+
+func deleteQuestions(q querier, eventId int64) error {
+	stmt, err := q.Prepare("DELETE FROM registration_questions WHERE event_id = ?")
+
+	if err != nil {
+		return err
+	}
+
+	defer stmt.Close()
+
+	_, err = stmt.Exec(eventId)
+	return err
+}
+
+// StreamAnswers calls fn with the answers of every registration for the
+// event, in registration order, in the columns of the event's questions.
+func StreamAnswers(eventId int64, questions []Question, fn func(AnswerExportRow) error) error {
+	keys := questionKeys(questions)
+
+	query := `
+	SELECT r.id, r.user_id, COALESCE(u.email, ''), r.answers
+	FROM registrations r
+	LEFT JOIN users u ON u.id = r.user_id
+	WHERE r.event_id = ?
+	ORDER BY r.id`
+	rows, err := db.DB.Query(query, eventId)
+
+	if err != nil {
+		return err
+	}
+
+	defer rows.Close()
+
+	for rows.Next() {
+		row := AnswerExportRow{keys: keys}
+		var answers string
+
+		err := rows.Scan(&row.RegistrationID, &row.UserID, &row.UserEmail, &answers)
+
+		if err != nil {
+			return err
+		}
+
+		err = json.Unmarshal([]byte(answers), &row.Answers)
+
+		if err != nil {
+			return err
+		}
+
+		err = fn(row)
+
+		if err != nil {
+			return err
+		}
+	}
+
+	return rows.Err()
+}
@@ -0,0 +1,119 @@
+package models
+
+import (
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/salads-source/go_http_server/db"
+	"github.com/salads-source/go_http_server/utils"
+)
+
+// PasswordResetLifetime is how long a password reset token can be used.
+const PasswordResetLifetime = time.Hour
+
+var (
+	ErrInvalidResetToken      = errors.New("invalid or expired reset token")
+	ErrPasswordResetThrottled = errors.New("a password reset email was sent too recently")
+)
+
+// CreatePasswordReset issues a reset token for the account with email,
+// returning sql.ErrNoRows when there is none. Placeholder accounts have no
+// password to reset; their owner signs up instead. Tokens are throttled like
+// verification emails and return ErrPasswordResetThrottled.
+func CreatePasswordReset(email string) (*User, string, error) {
+	var user User
+	err := db.DB.QueryRow("SELECT id, email FROM users WHERE LOWER(email) = LOWER(?) AND placeholder = 0", email).Scan(&user.ID, &user.Email)
+
+	if err != nil {
+		return nil, "", err
+	}
+
+	now := time.Now().UTC()
+	throttled, err := tokenEmailThrottled("password_resets", user.ID, now)
+
+	if err != nil {
+		return nil, "", err
+	}
+
+	if throttled {
+		return nil, "", ErrPasswordResetThrottled
+	}
+
+	token, err := utils.RandomToken(32)
+
+	if err != nil {
+		return nil, "", err
+	}
+
+	_, err = db.DB.Exec("INSERT INTO password_resets(user_id, token_hash, created_at, expires_at) VALUES (?, ?, ?, ?)",
+		user.ID, hashToken(token), now, now.Add(PasswordResetLifetime))
+
+	if err != nil {
+		return nil, "", err
+	}
+
+	return &user, token, nil
+}
+
+// ResetPassword sets a new password using a reset token, if it meets the
+// password policy. The token and any others issued to the same user stop
+// working, and so do all of the user's sessions and access tokens.
+func ResetPassword(token, newPassword string) error {
+	tx, err := db.DB.Begin()
+
+	if err != nil {
+		return err
+	}
+
+	defer tx.Rollback()
+
+	now := time.Now().UTC()
+
+	var userId int64
+	var email string
+	err = tx.QueryRow(`SELECT r.user_id, u.email FROM password_resets r JOIN users u ON u.id = r.user_id
+	WHERE r.token_hash = ? AND r.used_at IS NULL AND r.expires_at > ?`, hashToken(token), now).Scan(&userId, &email)
+
+	if errors.Is(err, sql.ErrNoRows) {
+		return ErrInvalidResetToken
+	}
+
+	if err != nil {
+		return err
+	}
+
+	// A rejected password leaves the token unused, so the user can try
+	// another one.
+	err = Passwords.Check(newPassword, email)
+
+	if err != nil {
+		return err
+	}
+
+	hashedPassword, err := utils.HashPassWord(newPassword)
+
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.Exec("UPDATE password_resets SET used_at = ? WHERE user_id = ? AND used_at IS NULL", now, userId)
+
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.Exec("UPDATE users SET password = ? WHERE id = ?", hashedPassword, userId)
+
+	if err != nil {
+		return err
+	}
+
+	err = revokeUserTokens(tx, userId, tokenWatermark())
+
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
@@ -0,0 +1,77 @@
+package models
+
+import (
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/salads-source/go_http_server/db"
+	"github.com/salads-source/go_http_server/utils"
+)
+
+// RevokeToken stops the access token with the given id from being accepted
+// before it expires. Entries for tokens that have expired anyway are
+// removed at the same time, so the table only holds live tokens.
+func RevokeToken(claims *utils.TokenClaims) error {
+	now := time.Now().UTC()
+
+	_, err := db.DB.Exec("DELETE FROM revoked_tokens WHERE expires_at <= ?", now)
+
+	if err != nil {
+		return err
+	}
+
+	_, err = db.DB.Exec("INSERT OR IGNORE INTO revoked_tokens(jti, expires_at) VALUES (?, ?)", claims.ID, claims.ExpiresAt.UTC())
+	return err
+}
+
+// IsTokenRevoked reports whether a verified access token was logged out,
+// issued before its user's last password change, issued to a session that
+// was signed out, or belongs to a user that no longer exists. It is a single
+// indexed lookup, as it runs on every authenticated request.
+func IsTokenRevoked(claims *utils.TokenClaims) (bool, error) {
+	query := `
+	SELECT u.tokens_valid_after,
+		EXISTS(SELECT 1 FROM revoked_tokens WHERE jti = ?),
+		EXISTS(SELECT 1 FROM sessions WHERE id = ? AND revoked_at IS NOT NULL)
+	FROM users u
+	WHERE u.id = ?`
+
+	var validAfter sql.NullTime
+	var revoked, sessionRevoked bool
+	err := db.DB.QueryRow(query, claims.ID, claims.SessionID, claims.UserID).Scan(&validAfter, &revoked, &sessionRevoked)
+
+	if errors.Is(err, sql.ErrNoRows) {
+		return true, nil
+	}
+
+	if err != nil {
+		return false, err
+	}
+
+	if validAfter.Valid && claims.IssuedAt.Before(validAfter.Time) {
+		return true, nil
+	}
+
+	return revoked || sessionRevoked, nil
+}
+
+// tokenWatermark is the time stored in tokens_valid_after. It has the
+// millisecond precision of the iat claim, so a token issued right after the
+// change is not mistaken for one issued before it.
+func tokenWatermark() time.Time {
+	return time.Now().UTC().Truncate(time.Millisecond)
+}
+
+// revokeUserTokens invalidates every access token userId holds and signs
+// out all of their sessions.
+func revokeUserTokens(q querier, userId int64, now time.Time) error {
+	_, err := q.Exec("UPDATE users SET tokens_valid_after = ? WHERE id = ?", now, userId)
+
+	if err != nil {
+		return err
+	}
+
+	_, err = q.Exec("UPDATE sessions SET revoked_at = ? WHERE user_id = ? AND revoked_at IS NULL", now, userId)
+	return err
+}
@@ -0,0 +1,88 @@
+package models
+
+import (
+	"database/sql"
+	"errors"
+
+	"github.com/salads-source/go_http_server/db"
+)
+
+const (
+	// RoleAdmin users can manage every event and other users' roles.
+	RoleAdmin = "admin"
+	// RoleOrganizer users can create events even when event creation is
+	// restricted.
+	RoleOrganizer = "organizer"
+	// RoleAttendee is the role every new user starts with.
+	RoleAttendee = "attendee"
+)
+
+var ErrInvalidRole = errors.New("role must be admin, organizer or attendee")
+
+// Permission is an action that depends on the caller's role rather than on
+// owning the resource.
+type Permission string
+
+const (
+	PermissionCreateEvents Permission = "events:create"
+	// PermissionManageAnyEvent covers every event and every venue.
+	PermissionManageAnyEvent Permission = "events:manage_any"
+	PermissionManageUsers    Permission = "users:manage"
+)
+
+// rolePermissions is the permission matrix. Owners can always manage their
+// own events; these are the permissions on top of that.
+var rolePermissions = map[string][]Permission{
+	RoleAdmin:     {PermissionCreateEvents, PermissionManageAnyEvent, PermissionManageUsers},
+	RoleOrganizer: {PermissionCreateEvents},
+	RoleAttendee:  {},
+}
+
+// RestrictEventCreation limits creating events to roles with
+// PermissionCreateEvents. When it is off anyone signed in can create events.
+var RestrictEventCreation bool
+
+func IsRole(role string) bool {
+	_, ok := rolePermissions[role]
+	return ok
+}
+
+// HasPermission reports whether role grants permission.
+func HasPermission(role string, permission Permission) bool {
+	if permission == PermissionCreateEvents && !RestrictEventCreation {
+		return true
+	}
+
+	for _, granted := range rolePermissions[role] {
+		if granted == permission {
+			return true
+		}
+	}
+
+	return false
+}
+
+// SetUserRole changes the role of userId, returning sql.ErrNoRows if there
+// is no such user. Access tokens carry the role, so the user's current ones
+// are revoked; their sessions stay signed in and pick up the new role on
+// the next refresh.
+func SetUserRole(userId int64, role string) error {
+	if !IsRole(role) {
+		return ErrInvalidRole
+	}
+
+	result, err := db.DB.Exec("UPDATE users SET role = ?, tokens_valid_after = ? WHERE id = ? AND placeholder = 0",
+		role, tokenWatermark(), userId)
+
+	if err != nil {
+		return err
+	}
+
+	affected, err := result.RowsAffected()
+
+	if err == nil && affected == 0 {
+		return sql.ErrNoRows
+	}
+
+	return err
+}
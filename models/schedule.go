@@ -0,0 +1,139 @@
+package models
+
+import (
+	"errors"
+	"time"
+
+	"github.com/salads-source/go_http_server/db"
+)
+
+var (
+	ErrEventEndBeforeStart = errors.New("end must be after the start")
+	ErrEventEndAndDuration = errors.New("end time and duration do not agree")
+)
+
+// NormalizeSchedule works out the end of the event from EndDateTime or
+// DurationMinutes and checks it comes after the start. Both may be given, as
+// they are when an event is read back and resubmitted, as long as they agree
+// to the minute: DurationMinutes is reported in whole minutes, so an end time
+// with seconds in it keeps its seconds.
+// All-day events are widened to whole UTC days: they start at midnight and
+// end at the next midnight at or after their end, defaulting to one day.
+// Events without an end have no known length.
+func (event *Event) NormalizeSchedule() error {
+	if event.EndDateTime != nil && event.DurationMinutes > 0 {
+		if int(event.EndDateTime.Sub(event.DateTime).Minutes()) != event.DurationMinutes {
+			return ErrEventEndAndDuration
+		}
+
+		event.DurationMinutes = 0
+	}
+
+	if event.EndDateTime != nil && !event.EndDateTime.After(event.DateTime) {
+		return ErrEventEndBeforeStart
+	}
+
+	if event.AllDay {
+		event.DateTime = startOfDay(event.DateTime)
+		end := event.DateTime.AddDate(0, 0, 1)
+
+		if event.DurationMinutes > 0 {
+			end = event.DateTime.Add(time.Duration(event.DurationMinutes) * time.Minute)
+		} else if event.EndDateTime != nil {
+			end = *event.EndDateTime
+		}
+
+		// The end is exclusive, so an end already at midnight stays put.
+		end = startOfDay(end.Add(-time.Nanosecond)).AddDate(0, 0, 1)
+		event.EndDateTime = &end
+	} else if event.DurationMinutes > 0 {
+		end := event.DateTime.Add(time.Duration(event.DurationMinutes) * time.Minute)
+		event.EndDateTime = &end
+	}
+
+	if event.EndDateTime == nil {
+		return nil
+	}
+
+	if !event.EndDateTime.After(event.DateTime) {
+		return ErrEventEndBeforeStart
+	}
+
+	event.DurationMinutes = int(event.EndDateTime.Sub(event.DateTime).Minutes())
+	return nil
+}
+
+func startOfDay(t time.Time) time.Time {
+	year, month, day := t.Date()
+	return time.Date(year, month, day, 0, 0, 0, 0, time.UTC)
+}
+
+func utcTime(t *time.Time) *time.Time {
+	if t == nil {
+		return nil
+	}
+
+	utc := t.UTC()
+	return &utc
+}
+
+// overlapCondition matches events, aliased as alias, whose time overlaps
+// event. An event without an end clashes with events running at its start
+// time.
+func overlapCondition(alias string, event Event) (string, []any) {
+	start := event.DateTime.UTC()
+	end := start
+
+	if event.EndDateTime != nil {
+		end = event.EndDateTime.UTC()
+	}
+
+	condition := `((julianday(` + alias + `.dateTime) < julianday(?) AND julianday(COALESCE(` + alias + `.end_dateTime, ` + alias + `.dateTime)) > julianday(?))
+		OR julianday(` + alias + `.dateTime) = julianday(?))`
+	return condition, []any{end, start, start}
+}
+
+// GetRegistrationConflicts returns the events userId is registered for whose
+// time overlaps event, other than event itself. Rejected registrations are
+// not considered.
+func GetRegistrationConflicts(userId int64, event Event) ([]Event, error) {
+	overlap, args := overlapCondition("e", event)
+	query := "SELECT " + eventColumns + ` FROM events e
+	JOIN registrations r ON r.event_id = e.id
+	WHERE r.user_id = ? AND r.status != 'rejected' AND e.id != ? AND ` + overlap + `
+	ORDER BY e.dateTime`
+
+	return queryEvents(db.DB, query, append([]any{userId, event.ID}, args...)...)
+}
+
+func queryEvents(q querier, query string, args ...any) ([]Event, error) {
+	stmt, err := q.Prepare(query)
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer stmt.Close()
+
+	rows, err := stmt.Query(args...)
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	events := []Event{}
+
+	for rows.Next() {
+		event, err := scanEvent(rows)
+
+		if err != nil {
+			return nil, err
+		}
+
+		events = append(events, event)
+	}
+
+	return events, rows.Err()
+}
@@ -0,0 +1,223 @@
+package models
+
+import (
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/salads-source/go_http_server/db"
+	"github.com/salads-source/go_http_server/utils"
+)
+
+// RefreshTokenLifetime is how long a refresh token can be exchanged for a
+// new access token. Every exchange issues a new refresh token, so active
+// sessions never expire.
+const RefreshTokenLifetime = 30 * 24 * time.Hour
+
+var (
+	ErrInvalidRefreshToken = errors.New("invalid refresh token")
+	ErrRefreshTokenReused  = errors.New("refresh token was already used")
+)
+
+// Session is one signed-in device. Its refresh tokens form a family: each
+// one can be used once, and presenting a used token again revokes the
+// session, since it means the token was copied.
+type Session struct {
+	ID         int64
+	UserID     int64
+	UserAgent  string
+	IPAddress  string
+	CreatedAt  time.Time
+	LastUsedAt time.Time
+}
+
+// CreateSession starts a session for userId and returns it with its first
+// refresh token.
+func CreateSession(userId int64, userAgent, ipAddress string) (*Session, string, error) {
+	now := time.Now().UTC()
+	session := Session{UserID: userId, UserAgent: userAgent, IPAddress: ipAddress, CreatedAt: now, LastUsedAt: now}
+
+	tx, err := db.DB.Begin()
+
+	if err != nil {
+		return nil, "", err
+	}
+
+	defer tx.Rollback()
+
+	result, err := tx.Exec("INSERT INTO sessions(user_id, user_agent, ip_address, created_at, last_used_at) VALUES (?, ?, ?, ?, ?)",
+		session.UserID, session.UserAgent, session.IPAddress, session.CreatedAt, session.LastUsedAt)
+
+	if err != nil {
+		return nil, "", err
+	}
+
+	session.ID, err = result.LastInsertId()
+
+	if err != nil {
+		return nil, "", err
+	}
+
+	token, err := issueRefreshToken(tx, session.ID, now)
+
+	if err != nil {
+		return nil, "", err
+	}
+
+	return &session, token, tx.Commit()
+}
+
+func issueRefreshToken(q querier, sessionId int64, now time.Time) (string, error) {
+	token, err := utils.RandomToken(32)
+
+	if err != nil {
+		return "", err
+	}
+
+	_, err = q.Exec("INSERT INTO refresh_tokens(session_id, token_hash, created_at, expires_at) VALUES (?, ?, ?, ?)",
+		sessionId, hashToken(token), now, now.Add(RefreshTokenLifetime))
+
+	if err != nil {
+		return "", err
+	}
+
+	return token, nil
+}
+
+// RotateRefreshToken exchanges a refresh token for a new one in the same
+// session. A token that was already exchanged revokes the whole session and
+// returns ErrRefreshTokenReused.
+func RotateRefreshToken(token string) (*Session, string, error) {
+	now := time.Now().UTC()
+
+	tx, err := db.DB.Begin()
+
+	if err != nil {
+		return nil, "", err
+	}
+
+	defer tx.Rollback()
+
+	query := `
+	SELECT t.id, t.expires_at, t.used_at, s.id, s.user_id, s.user_agent, s.ip_address, s.created_at, s.last_used_at, s.revoked_at
+	FROM refresh_tokens t
+	JOIN sessions s ON s.id = t.session_id
+	WHERE t.token_hash = ?`
+
+	var session Session
+	var tokenId int64
+	var expiresAt time.Time
+	var usedAt, revokedAt sql.NullTime
+
+	err = tx.QueryRow(query, hashToken(token)).Scan(&tokenId, &expiresAt, &usedAt, &session.ID, &session.UserID,
+		&session.UserAgent, &session.IPAddress, &session.CreatedAt, &session.LastUsedAt, &revokedAt)
+
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, "", ErrInvalidRefreshToken
+	}
+
+	if err != nil {
+		return nil, "", err
+	}
+
+	if revokedAt.Valid {
+		return nil, "", ErrInvalidRefreshToken
+	}
+
+	if usedAt.Valid {
+		err = revokeSession(tx, session.ID, now)
+
+		if err != nil {
+			return nil, "", err
+		}
+
+		err = tx.Commit()
+
+		if err != nil {
+			return nil, "", err
+		}
+
+		return nil, "", ErrRefreshTokenReused
+	}
+
+	if !now.Before(expiresAt) {
+		return nil, "", ErrInvalidRefreshToken
+	}
+
+	_, err = tx.Exec("UPDATE refresh_tokens SET used_at = ? WHERE id = ?", now, tokenId)
+
+	if err != nil {
+		return nil, "", err
+	}
+
+	_, err = tx.Exec("UPDATE sessions SET last_used_at = ? WHERE id = ?", now, session.ID)
+
+	if err != nil {
+		return nil, "", err
+	}
+
+	session.LastUsedAt = now
+	newToken, err := issueRefreshToken(tx, session.ID, now)
+
+	if err != nil {
+		return nil, "", err
+	}
+
+	return &session, newToken, tx.Commit()
+}
+
+// GetSessions returns the active sessions of userId, most recently used
+// first.
+func GetSessions(userId int64) ([]Session, error) {
+	query := `
+	SELECT id, user_id, user_agent, ip_address, created_at, last_used_at
+	FROM sessions
+	WHERE user_id = ? AND revoked_at IS NULL
+	ORDER BY last_used_at DESC, id DESC`
+	rows, err := db.DB.Query(query, userId)
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	sessions := []Session{}
+
+	for rows.Next() {
+		var session Session
+		err := rows.Scan(&session.ID, &session.UserID, &session.UserAgent, &session.IPAddress, &session.CreatedAt, &session.LastUsedAt)
+
+		if err != nil {
+			return nil, err
+		}
+
+		sessions = append(sessions, session)
+	}
+
+	return sessions, rows.Err()
+}
+
+// RevokeSession signs out one of userId's sessions, returning sql.ErrNoRows
+// if they have no such active session.
+func RevokeSession(userId, sessionId int64) error {
+	result, err := db.DB.Exec("UPDATE sessions SET revoked_at = ? WHERE id = ? AND user_id = ? AND revoked_at IS NULL",
+		time.Now().UTC(), sessionId, userId)
+
+	if err != nil {
+		return err
+	}
+
+	affected, err := result.RowsAffected()
+
+	if err == nil && affected == 0 {
+		return sql.ErrNoRows
+	}
+
+	return err
+}
+
+func revokeSession(q querier, sessionId int64, now time.Time) error {
+	_, err := q.Exec("UPDATE sessions SET revoked_at = ? WHERE id = ? AND revoked_at IS NULL", now, sessionId)
+	return err
+}
@@ -0,0 +1,315 @@
+package models
+
+import (
+	"database/sql"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/salads-source/go_http_server/db"
+)
+
+const (
+	TransferStatusPending   = "pending"
+	TransferStatusAccepted  = "accepted"
+	TransferStatusDeclined  = "declined"
+	TransferStatusCancelled = "cancelled"
+)
+
+var (
+	ErrTransfersDisabled  = errors.New("transfers are not allowed for this event")
+	ErrTransferToSelf     = errors.New("cannot transfer a registration to yourself")
+	ErrTransferNotPending = errors.New("transfer is no longer pending")
+)
+
+// RegistrationTransfer is an offer to hand a registration to the user with
+// ToEmail. Answered offers are kept as the record of who held the place.
+type RegistrationTransfer struct {
+	ID             int64
+	RegistrationID int64
+	EventID        int64
+	FromUserID     int64
+	ToEmail        string
+	ToUserID       *int64
+	Status         string
+	CreatedAt      time.Time
+	RespondedAt    *time.Time
+}
+
+const transferColumns = "t.id, t.registration_id, t.event_id, t.from_user_id, t.to_email, t.to_user_id, t.status, t.created_at, t.responded_at"
+
+func scanTransfer(row interface{ Scan(...any) error }) (RegistrationTransfer, error) {
+	var transfer RegistrationTransfer
+	var toUserId sql.NullInt64
+	var respondedAt sql.NullTime
+
+	err := row.Scan(&transfer.ID, &transfer.RegistrationID, &transfer.EventID, &transfer.FromUserID, &transfer.ToEmail,
+		&toUserId, &transfer.Status, &transfer.CreatedAt, &respondedAt)
+
+	if toUserId.Valid {
+		transfer.ToUserID = &toUserId.Int64
+	}
+
+	if respondedAt.Valid {
+		transfer.RespondedAt = &respondedAt.Time
+	}
+
+	return transfer, err
+}
+
+// OfferTransfer offers the registration to the user with email. An earlier
+// offer that is still pending is cancelled, so each registration has at most
+// one open offer.
+func (event Event) OfferTransfer(registration Registration, email string) (*RegistrationTransfer, error) {
+	if !event.AllowTransfers {
+		return nil, ErrTransfersDisabled
+	}
+
+	transfer := RegistrationTransfer{
+		RegistrationID: registration.ID,
+		EventID:        event.ID,
+		FromUserID:     registration.UserID,
+		ToEmail:        strings.ToLower(strings.TrimSpace(email)),
+		Status:         TransferStatusPending,
+		CreatedAt:      time.Now().UTC(),
+	}
+
+	tx, err := db.DB.Begin()
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer tx.Rollback()
+
+	var ownEmail string
+	err = tx.QueryRow("SELECT LOWER(email) FROM users WHERE id = ?", registration.UserID).Scan(&ownEmail)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if ownEmail == transfer.ToEmail {
+		return nil, ErrTransferToSelf
+	}
+
+	_, err = tx.Exec("UPDATE registration_transfers SET status = ?, responded_at = ? WHERE registration_id = ? AND status = ?",
+		TransferStatusCancelled, transfer.CreatedAt, registration.ID, TransferStatusPending)
+
+	if err != nil {
+		return nil, err
+	}
+
+	result, err := tx.Exec(`INSERT INTO registration_transfers(registration_id, event_id, from_user_id, to_email, status, created_at)
+	VALUES (?, ?, ?, ?, ?, ?)`,
+		transfer.RegistrationID, transfer.EventID, transfer.FromUserID, transfer.ToEmail, transfer.Status, transfer.CreatedAt)
+
+	if err != nil {
+		return nil, err
+	}
+
+	transfer.ID, err = result.LastInsertId()
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &transfer, tx.Commit()
+}
+
+// CancelTransfer withdraws the pending offer for the registration, returning
+// sql.ErrNoRows if there is none.
+func (registration Registration) CancelTransfer() error {
+	result, err := db.DB.Exec("UPDATE registration_transfers SET status = ?, responded_at = ? WHERE registration_id = ? AND status = ?",
+		TransferStatusCancelled, time.Now().UTC(), registration.ID, TransferStatusPending)
+
+	if err != nil {
+		return err
+	}
+
+	affected, err := result.RowsAffected()
+
+	if err == nil && affected == 0 {
+		return sql.ErrNoRows
+	}
+
+	return err
+}
+
+// GetPendingTransfers returns the open offers made to the email of userId,
+// once that email is verified.
+func GetPendingTransfers(userId int64) ([]RegistrationTransfer, error) {
+	query := "SELECT " + transferColumns + ` FROM registration_transfers t
+	JOIN users u ON LOWER(u.email) = t.to_email AND u.email_verified = 1
+	WHERE u.id = ? AND t.status = ?
+	ORDER BY t.id`
+
+	return queryTransfers(query, userId, TransferStatusPending)
+}
+
+// GetEventTransfers returns every transfer offered for the event, oldest
+// first.
+func GetEventTransfers(eventId int64) ([]RegistrationTransfer, error) {
+	query := "SELECT " + transferColumns + " FROM registration_transfers t WHERE t.event_id = ? ORDER BY t.id"
+	return queryTransfers(query, eventId)
+}
+
+func queryTransfers(query string, args ...any) ([]RegistrationTransfer, error) {
+	rows, err := db.DB.Query(query, args...)
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	transfers := []RegistrationTransfer{}
+
+	for rows.Next() {
+		transfer, err := scanTransfer(rows)
+
+		if err != nil {
+			return nil, err
+		}
+
+		transfers = append(transfers, transfer)
+	}
+
+	return transfers, rows.Err()
+}
+
+// loadTransferFor fetches a transfer offered to the verified email of userId.
+// Offers made to someone else return sql.ErrNoRows.
+func loadTransferFor(q querier, transferId, userId int64) (RegistrationTransfer, error) {
+	query := "SELECT " + transferColumns + ` FROM registration_transfers t
+	JOIN users u ON LOWER(u.email) = t.to_email AND u.email_verified = 1
+	WHERE t.id = ? AND u.id = ?`
+
+	transfer, err := scanTransfer(q.QueryRow(query, transferId, userId))
+
+	if err != nil {
+		return transfer, err
+	}
+
+	if transfer.Status != TransferStatusPending {
+		return transfer, ErrTransferNotPending
+	}
+
+	return transfer, nil
+}
+
+// AcceptTransfer moves the registration to userId. The previous holder's
+// answers, guests and check-in describe them rather than the place, so they
+// are cleared. The registration and the transfer record change in one
+// transaction. Offers of a place at a private event the user may not see
+// return sql.ErrNoRows.
+func AcceptTransfer(transferId, userId int64) (*Registration, error) {
+	tx, err := db.DB.Begin()
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer tx.Rollback()
+
+	transfer, err := loadTransferFor(tx, transferId, userId)
+
+	if err != nil {
+		return nil, err
+	}
+
+	visible, err := viewable(tx, transfer.EventID, userId)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if !visible {
+		return nil, sql.ErrNoRows
+	}
+
+	var registered bool
+	err = tx.QueryRow("SELECT EXISTS(SELECT 1 FROM registrations WHERE event_id = ? AND user_id = ?)", transfer.EventID, userId).Scan(&registered)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if registered {
+		return nil, ErrAlreadyRegistered
+	}
+
+	// The offer lapses if the registrant cancelled or lost the place since.
+	result, err := tx.Exec(`UPDATE registrations SET user_id = ?, answers = '{}', checked_in_at = NULL
+	WHERE id = ? AND user_id = ? AND status = ?`,
+		userId, transfer.RegistrationID, transfer.FromUserID, RegistrationStatusApproved)
+
+	if err != nil {
+		return nil, err
+	}
+
+	affected, err := result.RowsAffected()
+
+	if err != nil {
+		return nil, err
+	}
+
+	if affected == 0 {
+		return nil, ErrTransferNotPending
+	}
+
+	err = deleteGuests(tx, "id = ?", transfer.RegistrationID)
+
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = tx.Exec("UPDATE registration_transfers SET status = ?, to_user_id = ?, responded_at = ? WHERE id = ?",
+		TransferStatusAccepted, userId, time.Now().UTC(), transfer.ID)
+
+	if err != nil {
+		return nil, err
+	}
+
+	err = tx.Commit()
+
+	if err != nil {
+		return nil, err
+	}
+
+	registration, err := GetRegistrationById(transfer.RegistrationID)
+	return &registration, err
+}
+
+// DeclineTransfer turns down an offer made to the email of userId.
+func DeclineTransfer(transferId, userId int64) error {
+	tx, err := db.DB.Begin()
+
+	if err != nil {
+		return err
+	}
+
+	defer tx.Rollback()
+
+	transfer, err := loadTransferFor(tx, transferId, userId)
+
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.Exec("UPDATE registration_transfers SET status = ?, to_user_id = ?, responded_at = ? WHERE id = ?",
+		TransferStatusDeclined, userId, time.Now().UTC(), transfer.ID)
+
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// GetPendingTransferFor returns a pending transfer offered to the email of
+// userId. Offers made to someone else return sql.ErrNoRows, so they can't be
+// told apart from transfers that don't exist.
+func GetPendingTransferFor(transferId, userId int64) (RegistrationTransfer, error) {
+	return loadTransferFor(db.DB, transferId, userId)
+}
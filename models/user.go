@@ -1,27 +1,46 @@
 package models
 
 import (
+	"database/sql"
 	"errors"
+	"strings"
 
 	"github.com/salads-source/go_http_server/db"
 	"github.com/salads-source/go_http_server/utils"
 )
 
+var (
+	ErrWrongPassword      = errors.New("current password is incorrect")
+	ErrInvalidCredentials = errors.New("Invalid credentials")
+)
+
 type User struct {
-	ID       int64
-	Email    string `binding:"required"`
-	Password string `binding:"required"`
+	ID            int64
+	Email         string
+	Password      string `json:"-"`
+	Role          string `json:"-"`
+	EmailVerified bool   `json:"-"`
 }
 
-func (u User) Save() error {
-	query := "INSERT INTO users(email, password) VALUES (?, ?)"
-	stmt, err := db.DB.Prepare(query)
+// Save creates the user with an unverified email address, stored in lower
+// case so that each address can only have one account. If an organizer
+// already added someone with this email to an event, the placeholder account
+// created for them is set aside rather than handed over: its registrations
+// move to the new account once the email is verified.
+func (u *User) Save() error {
+	email, err := normalizeEmail(u.Email)
 
 	if err != nil {
 		return err
 	}
 
-	defer stmt.Close()
+	u.Email = email
+
+	err = Passwords.Check(u.Password, u.Email)
+
+	if err != nil {
+		return err
+	}
 
 	hashedPassword, err := utils.HashPassWord(u.Password)
 
@@ -29,7 +48,21 @@ func (u User) Save() error {
 		return err
 	}
 
-	result, err := stmt.Exec(u.Email, hashedPassword)
+	tx, err := db.DB.Begin()
+
+	if err != nil {
+		return err
+	}
+
+	defer tx.Rollback()
+
+	_, err = tx.Exec("UPDATE users SET email = ? WHERE email = ? AND placeholder = 1", unclaimedEmail(u.Email), u.Email)
+
+	if err != nil {
+		return err
+	}
+
+	result, err := tx.Exec("INSERT INTO users(email, password, email_verified) VALUES (?, ?, 0)", u.Email, hashedPassword)
 
 	if err != nil {
 		return err
@@ -37,26 +70,196 @@ func (u User) Save() error {
 
 	userId, err := result.LastInsertId()
 
+	if err != nil {
+		return err
+	}
+
 	u.ID = userId
-	return err
+	return tx.Commit()
 }
 
+// GetUserByID returns the user with userId, or sql.ErrNoRows.
+func GetUserByID(userId int64) (*User, error) {
+	var user User
+	err := db.DB.QueryRow("SELECT id, email, password, role FROM users WHERE id = ?", userId).Scan(&user.ID, &user.Email, &user.Password, &user.Role)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &user, nil
+}
+
+// ValidateCredentials checks the email and password, filling in the user
+// if they match. Unknown emails take as long as wrong passwords and get the
+// same ErrInvalidCredentials. Emails match in any case. The user's ID is set whenever the email
+// belongs to an account, even if the password is wrong.
 func (u *User) ValidateCredentials() error {
-	query := "SELECT id, password FROM users WHERE email = ?"
+	u.Email = loginKey(u.Email)
+	query := "SELECT id, password, role, email_verified FROM users WHERE email = ? AND placeholder = 0"
 	row := db.DB.QueryRow(query, u.Email)
 
 	var retrievedPassword string
-	err := row.Scan(&u.ID, &retrievedPassword)
+	err := row.Scan(&u.ID, &retrievedPassword, &u.Role, &u.EmailVerified)
+
+	if errors.Is(err, sql.ErrNoRows) || (err == nil && retrievedPassword == "") {
+		utils.SimulatePasswordCheck(u.Password)
+		return ErrInvalidCredentials
+	}
 
 	if err != nil {
-		return errors.New("Invalid Credentials")
+		return err
 	}
 
 	passwordIsValid := utils.CheckPasswordHash(u.Password, retrievedPassword)
 
 	if !passwordIsValid {
-		return errors.New("Invalid credentials")
+		return ErrInvalidCredentials
 	}
 
 	return nil
 }
+
+// ChangePassword replaces the password of userId after checking the current
+// one and the policy for new ones. Every token issued before the change
+// stops working, and all sessions are signed out.
+func ChangePassword(userId int64, currentPassword, newPassword string) error {
+	user, err := GetUserByID(userId)
+
+	if err != nil {
+		return err
+	}
+
+	if !utils.CheckPasswordHash(currentPassword, user.Password) {
+		return ErrWrongPassword
+	}
+
+	err = Passwords.Check(newPassword, user.Email)
+
+	if err != nil {
+		return err
+	}
+
+	hashedPassword, err := utils.HashPassWord(newPassword)
+
+	if err != nil {
+		return err
+	}
+
+	tx, err := db.DB.Begin()
+
+	if err != nil {
+		return err
+	}
+
+	defer tx.Rollback()
+
+	_, err = tx.Exec("UPDATE users SET password = ? WHERE id = ?", hashedPassword, userId)
+
+	if err != nil {
+		return err
+	}
+
+	err = revokeUserTokens(tx, userId, tokenWatermark())
+
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// findOrCreatePlaceholder returns the id of the user with email, creating a
+// placeholder account without a password when there is none. Placeholders
+// cannot log in; their registrations go to whoever signs up with their email
+// and verifies it. Until then an unverified account with the email is not
+// trusted with new registrations either, and they go to the placeholder.
+func findOrCreatePlaceholder(q querier, email string) (int64, bool, error) {
+	email = strings.ToLower(strings.TrimSpace(email))
+
+	var userId int64
+	err := q.QueryRow(`SELECT id FROM users
+	WHERE (LOWER(email) = ? AND (placeholder = 1 OR email_verified = 1)) OR email = ?
+	ORDER BY placeholder, id LIMIT 1`, email, unclaimedEmail(email)).Scan(&userId)
+
+	if err == nil {
+		return userId, false, nil
+	}
+
+	if !errors.Is(err, sql.ErrNoRows) {
+		return 0, false, err
+	}
+
+	var taken bool
+	err = q.QueryRow("SELECT EXISTS(SELECT 1 FROM users WHERE LOWER(email) = ?)", email).Scan(&taken)
+
+	if err != nil {
+		return 0, false, err
+	}
+
+	placeholderEmail := email
+
+	if taken {
+		placeholderEmail = unclaimedEmail(email)
+	}
+
+	result, err := q.Exec("INSERT INTO users(email, password, placeholder) VALUES (?, '', 1)", placeholderEmail)
+
+	if err != nil {
+		return 0, false, err
+	}
+
+	userId, err = result.LastInsertId()
+	return userId, true, err
+}
+
+// unclaimedEmail is what a placeholder's email becomes while an unverified
+// account holds the real one.
+func unclaimedEmail(email string) string {
+	return "unclaimed:" + strings.ToLower(strings.TrimSpace(email))
+}
+
+// claimPlaceholder moves the registrations of the placeholder set aside for
+// the email of userId to that user and removes the placeholder. Places the
+// user already holds themselves are kept, and the placeholder's are dropped.
+func claimPlaceholder(q querier, userId int64) error {
+	var email string
+	err := q.QueryRow("SELECT email FROM users WHERE id = ?", userId).Scan(&email)
+
+	if err != nil {
+		return err
+	}
+
+	var placeholderId int64
+	err = q.QueryRow("SELECT id FROM users WHERE email = ? AND placeholder = 1", unclaimedEmail(email)).Scan(&placeholderId)
+
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil
+	}
+
+	if err != nil {
+		return err
+	}
+
+	duplicate := "user_id = ? AND event_id IN (SELECT event_id FROM registrations WHERE user_id = ?)"
+	err = deleteGuests(q, duplicate, placeholderId, userId)
+
+	if err != nil {
+		return err
+	}
+
+	_, err = q.Exec("DELETE FROM registrations WHERE "+duplicate, placeholderId, userId)
+
+	if err != nil {
+		return err
+	}
+
+	_, err = q.Exec("UPDATE registrations SET user_id = ? WHERE user_id = ?", userId, placeholderId)
+
+	if err != nil {
+		return err
+	}
+
+	_, err = q.Exec("DELETE FROM users WHERE id = ?", placeholderId)
+	return err
+}
@@ -0,0 +1,373 @@
+package models
+
+import (
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/salads-source/go_http_server/db"
+)
+
+var (
+	ErrInvalidVenue = errors.New("venue does not exist")
+	ErrInvalidRoom  = errors.New("room does not belong to the venue")
+	ErrRoomBooked   = errors.New("the room is already booked at that time")
+	ErrVenueInUse   = errors.New("venue or room is used by events")
+)
+
+// Venue is a place events can be booked into. Capacity is the number of
+// attendees the venue holds, or 0 when it is not limited.
+type Venue struct {
+	ID        int64
+	Name      string `binding:"required"`
+	Address   string `binding:"required"`
+	Capacity  int    `binding:"min=0"`
+	UserID    int64
+	CreatedAt time.Time
+	Rooms     []Room `binding:"dive"`
+}
+
+// Room is a bookable part of a venue. A zero Capacity falls back to the
+// venue's.
+type Room struct {
+	ID       int64
+	VenueID  int64
+	Name     string `binding:"required"`
+	Capacity int    `binding:"min=0"`
+}
+
+// Booking is a slot taken in a venue's calendar. Only the time and room are
+// given so the calendar does not reveal events the caller cannot see.
+type Booking struct {
+	RoomID      *int64
+	DateTime    time.Time
+	EndDateTime *time.Time
+}
+
+func (venue *Venue) Save() error {
+	tx, err := db.DB.Begin()
+
+	if err != nil {
+		return err
+	}
+
+	defer tx.Rollback()
+
+	venue.CreatedAt = time.Now().UTC()
+	result, err := tx.Exec("INSERT INTO venues(name, address, capacity, user_id, created_at) VALUES (?, ?, ?, ?, ?)",
+		venue.Name, venue.Address, venue.Capacity, venue.UserID, venue.CreatedAt)
+
+	if err != nil {
+		return err
+	}
+
+	venue.ID, err = result.LastInsertId()
+
+	if err != nil {
+		return err
+	}
+
+	for i := range venue.Rooms {
+		venue.Rooms[i].VenueID = venue.ID
+		err = venue.Rooms[i].save(tx)
+
+		if err != nil {
+			return err
+		}
+	}
+
+	if venue.Rooms == nil {
+		venue.Rooms = []Room{}
+	}
+
+	return tx.Commit()
+}
+
+func GetAllVenues() ([]Venue, error) {
+	rows, err := db.DB.Query("SELECT id, name, address, capacity, user_id, created_at FROM venues ORDER BY name, id")
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	venues := []Venue{}
+	byId := map[int64]int{}
+
+	for rows.Next() {
+		var venue Venue
+		err := rows.Scan(&venue.ID, &venue.Name, &venue.Address, &venue.Capacity, &venue.UserID, &venue.CreatedAt)
+
+		if err != nil {
+			return nil, err
+		}
+
+		venue.Rooms = []Room{}
+		byId[venue.ID] = len(venues)
+		venues = append(venues, venue)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	rooms, err := getRooms("")
+
+	if err != nil {
+		return nil, err
+	}
+
+	for _, room := range rooms {
+		if i, ok := byId[room.VenueID]; ok {
+			venues[i].Rooms = append(venues[i].Rooms, room)
+		}
+	}
+
+	return venues, nil
+}
+
+func GetVenueByID(venueId int64) (*Venue, error) {
+	var venue Venue
+	err := db.DB.QueryRow("SELECT id, name, address, capacity, user_id, created_at FROM venues WHERE id = ?", venueId).
+		Scan(&venue.ID, &venue.Name, &venue.Address, &venue.Capacity, &venue.UserID, &venue.CreatedAt)
+
+	if err != nil {
+		return nil, err
+	}
+
+	venue.Rooms, err = getRooms("WHERE venue_id = ?", venueId)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &venue, nil
+}
+
+func getRooms(where string, args ...any) ([]Room, error) {
+	rows, err := db.DB.Query("SELECT id, venue_id, name, capacity FROM venue_rooms "+where+" ORDER BY id", args...)
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	rooms := []Room{}
+
+	for rows.Next() {
+		var room Room
+		err := rows.Scan(&room.ID, &room.VenueID, &room.Name, &room.Capacity)
+
+		if err != nil {
+			return nil, err
+		}
+
+		rooms = append(rooms, room)
+	}
+
+	return rooms, rows.Err()
+}
+
+// Update saves the name, address and capacity of the venue. Rooms are
+// managed separately so their ids stay stable for the events booked in them.
+func (venue Venue) Update() error {
+	_, err := db.DB.Exec("UPDATE venues SET name = ?, address = ?, capacity = ? WHERE id = ?",
+		venue.Name, venue.Address, venue.Capacity, venue.ID)
+	return err
+}
+
+// Delete removes the venue and its rooms. Venues with events booked in them
+// are kept and ErrVenueInUse is returned.
+func (venue Venue) Delete() error {
+	tx, err := db.DB.Begin()
+
+	if err != nil {
+		return err
+	}
+
+	defer tx.Rollback()
+
+	var inUse bool
+	err = tx.QueryRow("SELECT EXISTS(SELECT 1 FROM events WHERE venue_id = ?)", venue.ID).Scan(&inUse)
+
+	if err != nil {
+		return err
+	}
+
+	if inUse {
+		return ErrVenueInUse
+	}
+
+	_, err = tx.Exec("DELETE FROM venue_rooms WHERE venue_id = ?", venue.ID)
+
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.Exec("DELETE FROM venues WHERE id = ?", venue.ID)
+
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func (room *Room) Save() error {
+	return room.save(db.DB)
+}
+
+func (room *Room) save(q querier) error {
+	result, err := q.Exec("INSERT INTO venue_rooms(venue_id, name, capacity) VALUES (?, ?, ?)", room.VenueID, room.Name, room.Capacity)
+
+	if err != nil {
+		return err
+	}
+
+	room.ID, err = result.LastInsertId()
+	return err
+}
+
+// GetRoom returns the room of venueId with the given id.
+func GetRoom(venueId, roomId int64) (*Room, error) {
+	rooms, err := getRooms("WHERE venue_id = ? AND id = ?", venueId, roomId)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if len(rooms) == 0 {
+		return nil, sql.ErrNoRows
+	}
+
+	return &rooms[0], nil
+}
+
+func (room Room) Update() error {
+	_, err := db.DB.Exec("UPDATE venue_rooms SET name = ?, capacity = ? WHERE id = ?", room.Name, room.Capacity, room.ID)
+	return err
+}
+
+// Delete removes the room unless events are booked in it, in which case
+// ErrVenueInUse is returned.
+func (room Room) Delete() error {
+	result, err := db.DB.Exec("DELETE FROM venue_rooms WHERE id = ? AND NOT EXISTS(SELECT 1 FROM events WHERE room_id = ?)", room.ID, room.ID)
+
+	if err != nil {
+		return err
+	}
+
+	deleted, err := result.RowsAffected()
+
+	if err != nil {
+		return err
+	}
+
+	if deleted == 0 {
+		return ErrVenueInUse
+	}
+
+	return nil
+}
+
+// GetVenueBookings returns the events booked at a venue that overlap the
+// period from from to to, in start order.
+func GetVenueBookings(venueId int64, from, to time.Time) ([]Booking, error) {
+	overlap, args := overlapCondition("e", Event{DateTime: from, EndDateTime: &to})
+	events, err := queryEvents(db.DB, "SELECT "+eventColumns+" FROM events e WHERE e.venue_id = ? AND "+overlap+" ORDER BY e.dateTime",
+		append([]any{venueId}, args...)...)
+
+	if err != nil {
+		return nil, err
+	}
+
+	bookings := []Booking{}
+
+	for _, event := range events {
+		bookings = append(bookings, Booking{RoomID: event.RoomID, DateTime: event.DateTime, EndDateTime: event.EndDateTime})
+	}
+
+	return bookings, nil
+}
+
+// bookVenue checks the venue and room of an event before it is saved. The
+// venue is taken from the room when only a room is given, the capacity
+// defaults to the room's or else the venue's, and ErrRoomBooked is returned
+// when another event overlaps it in the same room. An event booked without a
+// room takes the whole venue and clashes with every room.
+func (event *Event) bookVenue(q querier) error {
+	if event.RoomID != nil && event.VenueID == nil {
+		var venueId int64
+		err := q.QueryRow("SELECT venue_id FROM venue_rooms WHERE id = ?", *event.RoomID).Scan(&venueId)
+
+		if errors.Is(err, sql.ErrNoRows) {
+			return ErrInvalidRoom
+		}
+
+		if err != nil {
+			return err
+		}
+
+		event.VenueID = &venueId
+	}
+
+	if event.VenueID == nil {
+		return nil
+	}
+
+	var venueName, venueAddress string
+	var capacity int
+	err := q.QueryRow("SELECT name, address, capacity FROM venues WHERE id = ?", *event.VenueID).Scan(&venueName, &venueAddress, &capacity)
+
+	if errors.Is(err, sql.ErrNoRows) {
+		return ErrInvalidVenue
+	}
+
+	if err != nil {
+		return err
+	}
+
+	if event.RoomID != nil {
+		var roomCapacity int
+		err := q.QueryRow("SELECT capacity FROM venue_rooms WHERE id = ? AND venue_id = ?", *event.RoomID, *event.VenueID).Scan(&roomCapacity)
+
+		if errors.Is(err, sql.ErrNoRows) {
+			return ErrInvalidRoom
+		}
+
+		if err != nil {
+			return err
+		}
+
+		if roomCapacity > 0 {
+			capacity = roomCapacity
+		}
+	}
+
+	if event.Capacity == 0 {
+		event.Capacity = capacity
+	}
+
+	if event.Location == "" {
+		event.Location = venueName + ", " + venueAddress
+	}
+
+	overlap, args := overlapCondition("e", *event)
+	query := `SELECT EXISTS(SELECT 1 FROM events e
+	WHERE e.venue_id = ? AND e.id != ? AND (? IS NULL OR e.room_id IS NULL OR e.room_id = ?) AND ` + overlap + `)`
+
+	var booked bool
+	err = q.QueryRow(query, append([]any{*event.VenueID, event.ID, event.RoomID, event.RoomID}, args...)...).Scan(&booked)
+
+	if err != nil {
+		return err
+	}
+
+	if booked {
+		return ErrRoomBooked
+	}
+
+	return nil
+}
@@ -0,0 +1,210 @@
+package models
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/salads-source/go_http_server/db"
+	"github.com/salads-source/go_http_server/utils"
+)
+
+// EmailVerificationLifetime is how long a verification token can be used.
+const EmailVerificationLifetime = 24 * time.Hour
+
+// Emails carrying verification and password reset tokens are throttled per
+// account, so the endpoints cannot be used to flood someone's inbox.
+const (
+	tokenEmailInterval   = time.Minute
+	tokenEmailDailyLimit = 5
+)
+
+var (
+	ErrInvalidVerificationToken = errors.New("invalid or expired verification token")
+	ErrAlreadyVerified          = errors.New("email is already verified")
+	ErrVerificationThrottled    = errors.New("a verification email was sent too recently")
+)
+
+// VerifiedAction is something that can be limited to users who have
+// verified their email address.
+type VerifiedAction string
+
+const (
+	VerifiedActionLogin        VerifiedAction = "login"
+	VerifiedActionCreateEvents VerifiedAction = "create_events"
+	VerifiedActionRegister     VerifiedAction = "register"
+)
+
+// RequireVerifiedEmail holds the actions that need a verified email. It is
+// set from the REQUIRE_VERIFIED_EMAIL environment variable; nothing needs
+// one by default.
+var RequireVerifiedEmail = map[VerifiedAction]bool{}
+
+// ParseVerificationRequirements reads a comma separated list of actions,
+// such as "create_events,register".
+func ParseVerificationRequirements(value string) (map[VerifiedAction]bool, error) {
+	requirements := map[VerifiedAction]bool{}
+
+	for _, item := range strings.Split(value, ",") {
+		action := VerifiedAction(strings.TrimSpace(item))
+
+		switch action {
+		case "":
+		case VerifiedActionLogin, VerifiedActionCreateEvents, VerifiedActionRegister:
+			requirements[action] = true
+		default:
+			return nil, fmt.Errorf("unknown verification requirement %q, use login, create_events or register", item)
+		}
+	}
+
+	return requirements, nil
+}
+
+// IsEmailVerified reports whether userId has verified their email address.
+func IsEmailVerified(userId int64) (bool, error) {
+	var verified bool
+	err := db.DB.QueryRow("SELECT email_verified FROM users WHERE id = ?", userId).Scan(&verified)
+	return verified, err
+}
+
+// CreateEmailVerification issues a verification token for userId. Tokens
+// issued too recently or too often return ErrVerificationThrottled.
+func CreateEmailVerification(userId int64) (string, error) {
+	now := time.Now().UTC()
+	throttled, err := tokenEmailThrottled("email_verifications", userId, now)
+
+	if err != nil {
+		return "", err
+	}
+
+	if throttled {
+		return "", ErrVerificationThrottled
+	}
+
+	token, err := utils.RandomToken(32)
+
+	if err != nil {
+		return "", err
+	}
+
+	_, err = db.DB.Exec("INSERT INTO email_verifications(user_id, token_hash, created_at, expires_at) VALUES (?, ?, ?, ?)",
+		userId, hashToken(token), now, now.Add(EmailVerificationLifetime))
+
+	if err != nil {
+		return "", err
+	}
+
+	return token, nil
+}
+
+// ResendEmailVerification issues a new token for the unverified account
+// with email. It returns sql.ErrNoRows when there is no such account and
+// ErrAlreadyVerified when there is nothing to verify.
+func ResendEmailVerification(email string) (*User, string, error) {
+	var user User
+	var verified bool
+	err := db.DB.QueryRow("SELECT id, email, email_verified FROM users WHERE LOWER(email) = LOWER(?) AND placeholder = 0", email).
+		Scan(&user.ID, &user.Email, &verified)
+
+	if err != nil {
+		return nil, "", err
+	}
+
+	if verified {
+		return nil, "", ErrAlreadyVerified
+	}
+
+	token, err := CreateEmailVerification(user.ID)
+
+	if err != nil {
+		return nil, "", err
+	}
+
+	return &user, token, nil
+}
+
+// VerifyEmail marks the owner of token as verified and gives them the
+// registrations of any placeholder account with their email. All of the
+// user's verification tokens stop working.
+func VerifyEmail(token string) error {
+	tx, err := db.DB.Begin()
+
+	if err != nil {
+		return err
+	}
+
+	defer tx.Rollback()
+
+	now := time.Now().UTC()
+
+	var userId int64
+	err = tx.QueryRow("SELECT user_id FROM email_verifications WHERE token_hash = ? AND used_at IS NULL AND expires_at > ?",
+		hashToken(token), now).Scan(&userId)
+
+	if errors.Is(err, sql.ErrNoRows) {
+		return ErrInvalidVerificationToken
+	}
+
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.Exec("UPDATE email_verifications SET used_at = ? WHERE user_id = ? AND used_at IS NULL", now, userId)
+
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.Exec("UPDATE users SET email_verified = 1 WHERE id = ?", userId)
+
+	if err != nil {
+		return err
+	}
+
+	err = claimPlaceholder(tx, userId)
+
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// tokenEmailThrottled reports whether userId was sent a token from table,
+// email_verifications or password_resets, too recently or too often to be
+// sent another.
+func tokenEmailThrottled(table string, userId int64, now time.Time) (bool, error) {
+	// Scanned one by one, as sqlite returns aggregates of DATETIME columns
+	// as plain strings.
+	rows, err := db.DB.Query("SELECT created_at FROM "+table+" WHERE user_id = ? AND created_at > ? ORDER BY created_at DESC",
+		userId, now.Add(-24*time.Hour))
+
+	if err != nil {
+		return false, err
+	}
+
+	defer rows.Close()
+
+	var sent []time.Time
+
+	for rows.Next() {
+		var createdAt time.Time
+		err = rows.Scan(&createdAt)
+
+		if err != nil {
+			return false, err
+		}
+
+		sent = append(sent, createdAt)
+	}
+
+	err = rows.Err()
+
+	if err != nil {
+		return false, err
+	}
+
+	return len(sent) >= tokenEmailDailyLimit || (len(sent) > 0 && now.Sub(sent[0]) < tokenEmailInterval), nil
+}
@@ -0,0 +1,274 @@
+package models
+
+import (
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/salads-source/go_http_server/db"
+	"github.com/salads-source/go_http_server/utils"
+)
+
+const (
+	// VisibilityPublic events are listed and readable by anyone.
+	VisibilityPublic = "public"
+	// VisibilityUnlisted events are readable by anyone with the link but are
+	// left out of listings.
+	VisibilityUnlisted = "unlisted"
+	// VisibilityPrivate events are only readable by their organizer,
+	// registered and invited users, and holders of a valid invite code.
+	VisibilityPrivate = "private"
+)
+
+var ErrInvitationExists = errors.New("user is already invited to this event")
+
+type Invitation struct {
+	ID        int64
+	EventID   int64
+	Email     string
+	CreatedAt time.Time
+}
+
+// InviteCode grants access to a private event to whoever holds it. Only a
+// hash is stored; the plain code is returned once, when it is created.
+type InviteCode struct {
+	ID        int64
+	EventID   int64
+	Code      string `json:",omitempty"`
+	ExpiresAt *time.Time
+	RevokedAt *time.Time
+	CreatedAt time.Time
+}
+
+// listableCondition matches events that belong in viewerId's listings:
+// public events, events they organize or were approved for, and private
+// events they were invited to. Pending and rejected registrations don't count,
+// so registering is not a way into a private event, and an invitation only
+// counts once the address it went to is verified.
+func listableCondition(alias string, viewerId int64) (string, []any) {
+	if viewerId == 0 {
+		return alias + ".visibility = 'public'", nil
+	}
+
+	condition := `(` + alias + `.visibility = 'public' OR ` + alias + `.user_id = ?
+		OR EXISTS (SELECT 1 FROM registrations vr WHERE vr.event_id = ` + alias + `.id AND vr.user_id = ?
+			AND vr.status = 'approved')
+		OR (` + alias + `.visibility = 'private' AND EXISTS (
+			SELECT 1 FROM event_invitations vi JOIN users vu ON LOWER(vu.email) = vi.email AND vu.email_verified = 1
+			WHERE vi.event_id = ` + alias + `.id AND vu.id = ?)))`
+
+	return condition, []any{viewerId, viewerId, viewerId}
+}
+
+// CanView reports whether viewerId (0 for anonymous callers) may read the
+// event, optionally presenting an invite code.
+func (event Event) CanView(viewerId int64, inviteCode string) (bool, error) {
+	if event.Visibility != VisibilityPrivate {
+		return true, nil
+	}
+
+	if viewerId != 0 {
+		visible, err := viewable(db.DB, event.ID, viewerId)
+
+		if err != nil || visible {
+			return visible, err
+		}
+	}
+
+	if inviteCode == "" {
+		return false, nil
+	}
+
+	return event.validInviteCode(inviteCode)
+}
+
+// viewable reports whether viewerId may read the event with eventId without
+// an invite code.
+func viewable(q querier, eventId, viewerId int64) (bool, error) {
+	condition, args := listableCondition("e", viewerId)
+	var visible bool
+	err := q.QueryRow("SELECT EXISTS(SELECT 1 FROM events e WHERE e.id = ? AND (e.visibility != 'private' OR "+condition+"))",
+		append([]any{eventId}, args...)...).Scan(&visible)
+	return visible, err
+}
+
+func (event Event) validInviteCode(code string) (bool, error) {
+	query := `SELECT EXISTS(SELECT 1 FROM invite_codes
+	WHERE event_id = ? AND code_hash = ? AND revoked_at IS NULL AND (expires_at IS NULL OR expires_at > ?))`
+
+	var valid bool
+	err := db.DB.QueryRow(query, event.ID, hashToken(code), time.Now().UTC()).Scan(&valid)
+	return valid, err
+}
+
+// hashToken is what is stored in place of a secret token, so a leaked
+// database does not leak usable codes.
+func hashToken(code string) string {
+	sum := sha256.Sum256([]byte(code))
+	return hex.EncodeToString(sum[:])
+}
+
+func (event Event) Invite(email string) (*Invitation, error) {
+	invitation := Invitation{EventID: event.ID, Email: strings.ToLower(strings.TrimSpace(email)), CreatedAt: time.Now().UTC()}
+
+	var exists bool
+	err := db.DB.QueryRow("SELECT EXISTS(SELECT 1 FROM event_invitations WHERE event_id = ? AND email = ?)",
+		event.ID, invitation.Email).Scan(&exists)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if exists {
+		return nil, ErrInvitationExists
+	}
+
+	result, err := db.DB.Exec("INSERT INTO event_invitations(event_id, email, created_at) VALUES (?, ?, ?)",
+		invitation.EventID, invitation.Email, invitation.CreatedAt)
+
+	if err != nil {
+		return nil, err
+	}
+
+	invitation.ID, err = result.LastInsertId()
+	return &invitation, err
+}
+
+func GetInvitations(eventId int64) ([]Invitation, error) {
+	rows, err := db.DB.Query("SELECT id, event_id, email, created_at FROM event_invitations WHERE event_id = ? ORDER BY id", eventId)
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	invitations := []Invitation{}
+
+	for rows.Next() {
+		var invitation Invitation
+		err := rows.Scan(&invitation.ID, &invitation.EventID, &invitation.Email, &invitation.CreatedAt)
+
+		if err != nil {
+			return nil, err
+		}
+
+		invitations = append(invitations, invitation)
+	}
+
+	return invitations, rows.Err()
+}
+
+// DeleteInvitation removes an invitation, returning sql.ErrNoRows if it does
+// not belong to the event.
+func (event Event) DeleteInvitation(invitationId int64) error {
+	result, err := db.DB.Exec("DELETE FROM event_invitations WHERE id = ? AND event_id = ?", invitationId, event.ID)
+
+	if err != nil {
+		return err
+	}
+
+	affected, err := result.RowsAffected()
+
+	if err == nil && affected == 0 {
+		return sql.ErrNoRows
+	}
+
+	return err
+}
+
+// CreateInviteCode issues a new code for the event. A nil expiresAt never
+// expires.
+func (event Event) CreateInviteCode(expiresAt *time.Time) (*InviteCode, error) {
+	code, err := utils.RandomToken(16)
+
+	if err != nil {
+		return nil, err
+	}
+
+	invite := InviteCode{EventID: event.ID, Code: code, ExpiresAt: expiresAt, CreatedAt: time.Now().UTC()}
+	result, err := db.DB.Exec("INSERT INTO invite_codes(event_id, code_hash, expires_at, created_at) VALUES (?, ?, ?, ?)",
+		invite.EventID, hashToken(code), invite.ExpiresAt, invite.CreatedAt)
+
+	if err != nil {
+		return nil, err
+	}
+
+	invite.ID, err = result.LastInsertId()
+	return &invite, err
+}
+
+func GetInviteCodes(eventId int64) ([]InviteCode, error) {
+	rows, err := db.DB.Query("SELECT id, event_id, expires_at, revoked_at, created_at FROM invite_codes WHERE event_id = ? ORDER BY id", eventId)
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	codes := []InviteCode{}
+
+	for rows.Next() {
+		var invite InviteCode
+		var expiresAt, revokedAt sql.NullTime
+		err := rows.Scan(&invite.ID, &invite.EventID, &expiresAt, &revokedAt, &invite.CreatedAt)
+
+		if err != nil {
+			return nil, err
+		}
+
+		if expiresAt.Valid {
+			invite.ExpiresAt = &expiresAt.Time
+		}
+
+		if revokedAt.Valid {
+			invite.RevokedAt = &revokedAt.Time
+		}
+
+		codes = append(codes, invite)
+	}
+
+	return codes, rows.Err()
+}
+
+// RevokeInviteCode stops a code from granting access, returning
+// sql.ErrNoRows if it does not belong to the event.
+func (event Event) RevokeInviteCode(codeId int64) error {
+	result, err := db.DB.Exec("UPDATE invite_codes SET revoked_at = COALESCE(revoked_at, ?) WHERE id = ? AND event_id = ?",
+		time.Now().UTC(), codeId, event.ID)
+
+	if err != nil {
+		return err
+	}
+
+	affected, err := result.RowsAffected()
+
+	if err == nil && affected == 0 {
+		return sql.ErrNoRows
+	}
+
+	return err
+}
+
+func deleteInvitations(q querier, eventId int64) error {
+	for _, query := range []string{"DELETE FROM event_invitations WHERE event_id = ?", "DELETE FROM invite_codes WHERE event_id = ?"} {
+		stmt, err := q.Prepare(query)
+
+		if err != nil {
+			return err
+		}
+
+		_, err = stmt.Exec(eventId)
+		stmt.Close()
+
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,118 @@
+package routes
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/salads-source/go_http_server/models"
+)
+
+type accountDeletionInput struct {
+	Password string `binding:"required"`
+	Events   string
+	Comments string
+}
+
+// exportMe sends the caller's personal data as a zip of JSON files, one per
+// kind of record. The archive is built in memory first, so a failure can
+// still be reported with a proper status.
+func exportMe(context *gin.Context) {
+	userId := context.GetInt64("userId")
+	export, err := models.ExportAccount(userId)
+
+	if err != nil {
+		context.JSON(http.StatusInternalServerError, gin.H{"message": "Could not export account, try again later"})
+		return
+	}
+
+	var archive bytes.Buffer
+	writer := zip.NewWriter(&archive)
+
+	for _, file := range []struct {
+		name string
+		data any
+	}{
+		{"profile.json", export.Profile},
+		{"events.json", export.Events},
+		{"registrations.json", export.Registrations},
+		{"transfers.json", export.Transfers},
+		{"comments.json", export.Comments},
+		{"feedback.json", export.Feedback},
+		{"attachments.json", export.Attachments},
+		{"venues.json", export.Venues},
+		{"sessions.json", export.Sessions},
+		{"logins.json", export.Logins},
+	} {
+		err = writeZipJSON(writer, file.name, file.data)
+
+		if err != nil {
+			context.JSON(http.StatusInternalServerError, gin.H{"message": "Could not export account, try again later"})
+			return
+		}
+	}
+
+	err = writer.Close()
+
+	if err != nil {
+		context.JSON(http.StatusInternalServerError, gin.H{"message": "Could not export account, try again later"})
+		return
+	}
+
+	filename := fmt.Sprintf("account-%d-%s.zip", userId, time.Now().UTC().Format("20060102T150405Z"))
+	context.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="%s"`, filename))
+	context.Data(http.StatusOK, "application/zip", archive.Bytes())
+}
+
+func writeZipJSON(writer *zip.Writer, name string, data any) error {
+	file, err := writer.Create(name)
+
+	if err != nil {
+		return err
+	}
+
+	encoder := json.NewEncoder(file)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(data)
+}
+
+// deleteMe deletes the caller's account after checking their password. The
+// rules in the request choose what happens to their events and comments;
+// the ones left out follow the server's defaults. Events can only be
+// transferred to the account the server's rules name.
+func deleteMe(context *gin.Context) {
+	var input accountDeletionInput
+	err := context.ShouldBindJSON(&input)
+
+	if err != nil {
+		context.JSON(http.StatusBadRequest, gin.H{"message": "Could not parse request data."})
+		return
+	}
+
+	rules := models.DeletionRules{Events: input.Events, Comments: input.Comments}
+	attachments, err := models.DeleteAccount(context.GetInt64("userId"), input.Password, rules)
+
+	if errors.Is(err, models.ErrWrongPassword) {
+		context.JSON(http.StatusUnauthorized, gin.H{"message": "Password is incorrect."})
+		return
+	}
+
+	if errors.Is(err, models.ErrInvalidDeletionRules) || errors.Is(err, models.ErrInvalidTransferUser) {
+		context.JSON(http.StatusBadRequest, gin.H{"message": err.Error()})
+		return
+	}
+
+	if err != nil {
+		context.JSON(http.StatusInternalServerError, gin.H{"message": "Could not delete account, try again later"})
+		return
+	}
+
+	removeAttachmentBlobs(attachments)
+
+	context.JSON(http.StatusOK, gin.H{"message": "Account deleted"})
+}
@@ -0,0 +1,221 @@
+package routes
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/salads-source/go_http_server/db"
+	"github.com/salads-source/go_http_server/models"
+	"github.com/stretchr/testify/assert"
+)
+
+func readExport(t *testing.T, body []byte) map[string]string {
+	reader, err := zip.NewReader(bytes.NewReader(body), int64(len(body)))
+	if err != nil {
+		t.Fatalf("Failed to open export: %v", err)
+	}
+
+	files := map[string]string{}
+	for _, file := range reader.File {
+		opened, err := file.Open()
+		if err != nil {
+			t.Fatalf("Failed to open %s: %v", file.Name, err)
+		}
+		content, _ := io.ReadAll(opened)
+		opened.Close()
+		files[file.Name] = string(content)
+	}
+	return files
+}
+
+func TestAccountExport(t *testing.T) {
+	_, router := setupTestRouter(t)
+	userId := createTestUser(t, db.DB, "exporter@example.com", "password123")
+	token := generateTestToken(t, "exporter@example.com", userId)
+	organizerId := createTestUser(t, db.DB, "host@example.com", "password123")
+
+	ownEventId := createTestEvent(t, userId)
+	db.DB.Exec("UPDATE events SET name = 'Exported meetup' WHERE id = ?", ownEventId)
+	otherEventId := createTestEvent(t, organizerId)
+
+	w := postJSON(t, router, http.MethodPost, fmt.Sprintf("/events/%d/register", otherEventId), token, nil)
+	assert.Equal(t, http.StatusCreated, w.Code)
+	w = postJSON(t, router, http.MethodPost, fmt.Sprintf("/events/%d/comments", otherEventId), token, map[string]interface{}{"body": "See you there"})
+	assert.Equal(t, http.StatusCreated, w.Code)
+
+	w = getAs(router, "/me/export", token)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "application/zip", w.Header().Get("Content-Type"))
+	assert.True(t, strings.HasPrefix(w.Header().Get("Content-Disposition"), "attachment;"))
+
+	files := readExport(t, w.Body.Bytes())
+	for _, name := range []string{"profile.json", "events.json", "registrations.json", "transfers.json", "comments.json",
+		"feedback.json", "attachments.json", "venues.json", "sessions.json", "logins.json"} {
+		assert.Contains(t, files, name)
+	}
+	assert.Contains(t, files["profile.json"], "exporter@example.com")
+	assert.Contains(t, files["events.json"], "Exported meetup")
+	assert.Contains(t, files["comments.json"], "See you there")
+
+	var registrations []struct {
+		EventID int64
+	}
+	json.Unmarshal([]byte(files["registrations.json"]), &registrations)
+	if assert.Len(t, registrations, 1) {
+		assert.Equal(t, otherEventId, registrations[0].EventID)
+	}
+
+	t.Run("requires authentication", func(t *testing.T) {
+		w := getAs(router, "/me/export", "")
+		assert.Equal(t, http.StatusUnauthorized, w.Code)
+	})
+}
+
+func TestAccountDeletion(t *testing.T) {
+	_, router := setupTestRouter(t)
+	organizerId := createTestUser(t, db.DB, "host@example.com", "password123")
+	otherEventId := createTestEvent(t, organizerId)
+
+	// newAccount creates a user who organizes an event, is registered for
+	// another one and has commented on it.
+	newAccount := func(t *testing.T, email string) (int64, string, int64, int64) {
+		userId := createTestUser(t, db.DB, email, "password123")
+		token := generateTestToken(t, email, userId)
+		eventId := createTestEvent(t, userId)
+
+		w := postJSON(t, router, http.MethodPost, fmt.Sprintf("/events/%d/register", otherEventId), token, nil)
+		assert.Equal(t, http.StatusCreated, w.Code)
+		w = postJSON(t, router, http.MethodPost, fmt.Sprintf("/events/%d/comments", otherEventId), token, map[string]interface{}{"body": "Hello from " + email})
+		assert.Equal(t, http.StatusCreated, w.Code)
+
+		var commentId int64
+		db.DB.QueryRow("SELECT id FROM comments WHERE user_id = ?", userId).Scan(&commentId)
+		return userId, token, eventId, commentId
+	}
+
+	t.Run("wrong password is rejected", func(t *testing.T) {
+		userId, token, _, _ := newAccount(t, "careful@example.com")
+
+		w := postJSON(t, router, http.MethodDelete, "/me", token, map[string]interface{}{"password": "wrong"})
+		assert.Equal(t, http.StatusUnauthorized, w.Code)
+
+		w = getAs(router, "/me", token)
+		assert.Equal(t, http.StatusOK, w.Code)
+
+		var email string
+		db.DB.QueryRow("SELECT email FROM users WHERE id = ?", userId).Scan(&email)
+		assert.Equal(t, "careful@example.com", email)
+	})
+
+	// Transfers go to the account named in the server's rules.
+	original := models.DefaultDeletionRules
+	t.Cleanup(func() { models.DefaultDeletionRules = original })
+
+	t.Run("invalid rules are rejected", func(t *testing.T) {
+		_, token, _, _ := newAccount(t, "rules@example.com")
+		createTestUser(t, db.DB, "attendee-only@example.com", "password123")
+
+		for _, payload := range []map[string]interface{}{
+			{"password": "password123", "events": "archive"},
+			{"password": "password123", "comments": "shred"},
+			{"password": "password123", "events": "transfer"},
+			{"password": "password123", "events": "transfer", "transferTo": "nobody@example.com"},
+			{"password": "password123", "events": "transfer", "transferTo": "rules@example.com"},
+		} {
+			w := postJSON(t, router, http.MethodDelete, "/me", token, payload)
+			assert.Equal(t, http.StatusBadRequest, w.Code, payload)
+		}
+
+		// Only organizers and admins can take events over.
+		models.DefaultDeletionRules.TransferTo = "attendee-only@example.com"
+		w := postJSON(t, router, http.MethodDelete, "/me", token, map[string]interface{}{"password": "password123", "events": "transfer"})
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+		models.DefaultDeletionRules = original
+
+		w = getAs(router, "/me", token)
+		assert.Equal(t, http.StatusOK, w.Code)
+	})
+
+	t.Run("default rules cancel events and anonymize the account", func(t *testing.T) {
+		userId, token, eventId, commentId := newAccount(t, "leaving@example.com")
+
+		// Someone registered for the cancelled event, with a guest and an
+		// offer to pass the place on.
+		attendeeId := createTestUser(t, db.DB, "left-behind@example.com", "password123")
+		result, _ := db.DB.Exec("INSERT INTO registrations(event_id, user_id) VALUES (?, ?)", eventId, attendeeId)
+		registrationId, _ := result.LastInsertId()
+		db.DB.Exec("INSERT INTO registration_guests(registration_id, name, created_at) VALUES (?, 'Guest', ?)", registrationId, time.Now().UTC())
+		db.DB.Exec(`INSERT INTO registration_transfers(registration_id, event_id, from_user_id, to_email, status, created_at)
+		VALUES (?, ?, ?, 'friend@example.com', 'pending', ?)`, registrationId, eventId, attendeeId, time.Now().UTC())
+
+		w := postJSON(t, router, http.MethodDelete, "/me", token, map[string]interface{}{"password": "password123"})
+		assert.Equal(t, http.StatusOK, w.Code)
+
+		w = getAs(router, "/me", token)
+		assert.Equal(t, http.StatusUnauthorized, w.Code)
+
+		w = postJSON(t, router, http.MethodPost, "/login", "", map[string]interface{}{"email": "leaving@example.com", "password": "password123"})
+		assert.Equal(t, http.StatusUnauthorized, w.Code)
+
+		var events int
+		db.DB.QueryRow("SELECT COUNT(*) FROM events WHERE id = ?", eventId).Scan(&events)
+		assert.Equal(t, 0, events)
+
+		var registrations int
+		db.DB.QueryRow("SELECT COUNT(*) FROM registrations WHERE user_id = ?", userId).Scan(&registrations)
+		assert.Equal(t, 0, registrations)
+
+		var leftovers int
+		db.DB.QueryRow(`SELECT (SELECT COUNT(*) FROM registrations WHERE event_id = ?)
+		+ (SELECT COUNT(*) FROM registration_guests WHERE registration_id = ?)
+		+ (SELECT COUNT(*) FROM registration_transfers WHERE event_id = ?)`, eventId, registrationId, eventId).Scan(&leftovers)
+		assert.Zero(t, leftovers)
+
+		var email, displayName string
+		var deleted bool
+		db.DB.QueryRow("SELECT email, display_name, deleted_at IS NOT NULL FROM users WHERE id = ?", userId).Scan(&email, &displayName, &deleted)
+		assert.Equal(t, fmt.Sprintf("deleted-%d@deleted.invalid", userId), email)
+		assert.Empty(t, displayName)
+		assert.True(t, deleted)
+
+		var status string
+		db.DB.QueryRow("SELECT status FROM comments WHERE id = ?", commentId).Scan(&status)
+		assert.Equal(t, "visible", status)
+
+		// The address is free to sign up with again.
+		w = postJSON(t, router, http.MethodPost, "/signup", "", map[string]interface{}{"email": "leaving@example.com", "password": "password123"})
+		assert.Equal(t, http.StatusCreated, w.Code)
+	})
+
+	t.Run("events can be transferred and comments withdrawn", func(t *testing.T) {
+		successorId := createTestUser(t, db.DB, "successor@example.com", "password123")
+		db.DB.Exec("UPDATE users SET role = 'organizer' WHERE id = ?", successorId)
+		models.DefaultDeletionRules.TransferTo = "Successor@example.com"
+		_, token, eventId, commentId := newAccount(t, "handover@example.com")
+
+		// A target in the request is ignored.
+		w := postJSON(t, router, http.MethodDelete, "/me", token, map[string]interface{}{
+			"password":   "password123",
+			"events":     "transfer",
+			"transferTo": "host@example.com",
+			"comments":   "delete",
+		})
+		assert.Equal(t, http.StatusOK, w.Code)
+
+		var ownerId int64
+		db.DB.QueryRow("SELECT user_id FROM events WHERE id = ?", eventId).Scan(&ownerId)
+		assert.Equal(t, successorId, ownerId)
+
+		var status, body string
+		db.DB.QueryRow("SELECT status, body FROM comments WHERE id = ?", commentId).Scan(&status, &body)
+		assert.Equal(t, "deleted", status)
+		assert.Empty(t, body)
+	})
+}
@@ -0,0 +1,80 @@
+package routes
+
+import (
+	"database/sql"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/salads-source/go_http_server/models"
+)
+
+type rejectionInput struct {
+	Reason string `binding:"max=500"`
+}
+
+func approveRegistration(context *gin.Context) {
+	decideRegistration(context, func(event *models.Event, registrationId int64) (*models.Registration, error) {
+		return event.ApproveRegistration(registrationId)
+	})
+}
+
+func rejectRegistration(context *gin.Context) {
+	// The reason is optional, so an empty body is fine.
+	var input rejectionInput
+
+	if context.Request.ContentLength != 0 {
+		err := context.ShouldBindJSON(&input)
+
+		if err != nil {
+			context.JSON(http.StatusBadRequest, gin.H{"message": "Could not parse request data."})
+			return
+		}
+	}
+
+	decideRegistration(context, func(event *models.Event, registrationId int64) (*models.Registration, error) {
+		return event.RejectRegistration(registrationId, input.Reason)
+	})
+}
+
+// decideRegistration checks that the caller organizes the event before
+// handing the registration to decide, and maps its errors to responses.
+func decideRegistration(context *gin.Context, decide func(*models.Event, int64) (*models.Registration, error)) {
+	registrationId, err := strconv.ParseInt(context.Param("registrationId"), 10, 64)
+
+	if err != nil {
+		context.JSON(http.StatusBadRequest, gin.H{"message": "Invalid registration Id"})
+		return
+	}
+
+	event, ok := loadOrganizedEvent(context)
+
+	if !ok {
+		return
+	}
+
+	registration, err := decide(event, registrationId)
+
+	if errors.Is(err, sql.ErrNoRows) {
+		context.JSON(http.StatusNotFound, gin.H{"message": "Registration not found."})
+		return
+	}
+
+	if errors.Is(err, models.ErrNotPending) {
+		context.JSON(http.StatusConflict, gin.H{"message": "Registration has already been reviewed."})
+		return
+	}
+
+	if errors.Is(err, models.ErrEventFull) {
+		context.JSON(http.StatusConflict, gin.H{"message": "Event is full."})
+		return
+	}
+
+	if err != nil {
+		context.JSON(http.StatusInternalServerError, gin.H{"message": "Could not review registration, try again later"})
+		return
+	}
+
+	context.JSON(http.StatusOK, gin.H{"message": "Registration " + registration.Status, "registration": registration})
+}
@@ -0,0 +1,123 @@
+package routes
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"testing"
+
+	"github.com/salads-source/go_http_server/db"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRegistrationApproval(t *testing.T) {
+	_, router := setupTestRouter(t)
+	organizerId := createTestUser(t, db.DB, "approvalorganizer@example.com", "password123")
+	organizerToken := generateTestToken(t, "approvalorganizer@example.com", organizerId)
+
+	eventId := createTestEvent(t, organizerId)
+	db.DB.Exec("UPDATE events SET requires_approval = 1, capacity = 1 WHERE id = ?", eventId)
+	eventPath := "/events/" + strconv.FormatInt(eventId, 10)
+
+	register := func(email string) (string, int64) {
+		userId := createTestUser(t, db.DB, email, "password123")
+		token := generateTestToken(t, email, userId)
+		w := postJSON(t, router, http.MethodPost, eventPath+"/register", token, nil)
+		assert.Equal(t, http.StatusCreated, w.Code)
+
+		var response struct {
+			Status string `json:"status"`
+		}
+		json.Unmarshal(w.Body.Bytes(), &response)
+		assert.Equal(t, "pending", response.Status)
+		return token, userId
+	}
+
+	ownRegistration := func(token string) (int64, string, string) {
+		w := getAs(router, eventPath+"/register", token)
+		assert.Equal(t, http.StatusOK, w.Code)
+
+		var response struct {
+			Registration struct {
+				ID           int64
+				Status       string
+				StatusReason string
+			} `json:"registration"`
+		}
+		json.Unmarshal(w.Body.Bytes(), &response)
+		return response.Registration.ID, response.Registration.Status, response.Registration.StatusReason
+	}
+
+	firstToken, firstId := register("approvalfirst@example.com")
+	secondToken, _ := register("approvalsecond@example.com")
+	thirdToken, _ := register("approvalthird@example.com")
+	firstRegistration, _, _ := ownRegistration(firstToken)
+	secondRegistration, _, _ := ownRegistration(secondToken)
+	thirdRegistration, _, _ := ownRegistration(thirdToken)
+
+	decisionPath := func(registrationId int64, decision string) string {
+		return eventPath + "/registrations/" + strconv.FormatInt(registrationId, 10) + "/" + decision
+	}
+
+	t.Run("pending registrations do not count against capacity", func(t *testing.T) {
+		w := getAs(router, eventPath+"/registrations?status=pending", organizerToken)
+		var response struct {
+			Registrations []struct{ ID int64 } `json:"registrations"`
+		}
+		json.Unmarshal(w.Body.Bytes(), &response)
+		assert.Len(t, response.Registrations, 3)
+	})
+
+	t.Run("pending registrants cannot be checked in", func(t *testing.T) {
+		w := postJSON(t, router, http.MethodPost, eventPath+"/check-in/"+strconv.FormatInt(firstId, 10), organizerToken, nil)
+		assert.Equal(t, http.StatusConflict, w.Code)
+	})
+
+	t.Run("only the organizer reviews registrations", func(t *testing.T) {
+		w := postJSON(t, router, http.MethodPost, decisionPath(firstRegistration, "approve"), firstToken, nil)
+		assert.Equal(t, http.StatusUnauthorized, w.Code)
+
+		w = postJSON(t, router, http.MethodPost, decisionPath(999, "approve"), organizerToken, nil)
+		assert.Equal(t, http.StatusNotFound, w.Code)
+	})
+
+	t.Run("approving", func(t *testing.T) {
+		w := postJSON(t, router, http.MethodPost, decisionPath(firstRegistration, "approve"), organizerToken, nil)
+		assert.Equal(t, http.StatusOK, w.Code)
+
+		_, status, _ := ownRegistration(firstToken)
+		assert.Equal(t, "approved", status)
+
+		w = postJSON(t, router, http.MethodPost, decisionPath(firstRegistration, "reject"), organizerToken, nil)
+		assert.Equal(t, http.StatusConflict, w.Code)
+
+		w = postJSON(t, router, http.MethodPost, eventPath+"/check-in/"+strconv.FormatInt(firstId, 10), organizerToken, nil)
+		assert.Equal(t, http.StatusOK, w.Code)
+	})
+
+	t.Run("approval respects capacity", func(t *testing.T) {
+		w := postJSON(t, router, http.MethodPost, decisionPath(secondRegistration, "approve"), organizerToken, nil)
+		assert.Equal(t, http.StatusConflict, w.Code)
+
+		_, status, _ := ownRegistration(secondToken)
+		assert.Equal(t, "pending", status)
+	})
+
+	t.Run("rejecting with a reason", func(t *testing.T) {
+		w := postJSON(t, router, http.MethodPost, decisionPath(secondRegistration, "reject"), organizerToken, map[string]interface{}{"reason": "Invite only"})
+		assert.Equal(t, http.StatusOK, w.Code)
+
+		_, status, reason := ownRegistration(secondToken)
+		assert.Equal(t, "rejected", status)
+		assert.Equal(t, "Invite only", reason)
+
+		w = postJSON(t, router, http.MethodPost, decisionPath(thirdRegistration, "reject"), organizerToken, nil)
+		assert.Equal(t, http.StatusOK, w.Code)
+	})
+
+	t.Run("status of someone not registered", func(t *testing.T) {
+		userId := createTestUser(t, db.DB, "approvalnobody@example.com", "password123")
+		w := getAs(router, eventPath+"/register", generateTestToken(t, "approvalnobody@example.com", userId))
+		assert.Equal(t, http.StatusNotFound, w.Code)
+	})
+}
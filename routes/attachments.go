@@ -0,0 +1,309 @@
+package routes
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/salads-source/go_http_server/models"
+	"github.com/salads-source/go_http_server/storage"
+	"github.com/salads-source/go_http_server/utils"
+)
+
+// maxAttachmentSize is a variable so tests can lower it.
+var maxAttachmentSize int64 = 10 << 20 // 10 MB
+
+const thumbnailSize = 320
+
+// allowedAttachmentTypes maps the sniffed content types we accept to the
+// extension used for the stored blob.
+var allowedAttachmentTypes = map[string]string{
+	"image/jpeg":      ".jpg",
+	"image/png":       ".png",
+	"image/gif":       ".gif",
+	"application/pdf": ".pdf",
+}
+
+func getAttachments(context *gin.Context) {
+	eventId, err := strconv.ParseInt(context.Param("id"), 10, 64)
+
+	if err != nil {
+		context.JSON(http.StatusBadRequest, gin.H{"message": "Invalid event Id"})
+		return
+	}
+
+	_, ok := loadVisibleEvent(context, eventId)
+
+	if !ok {
+		return
+	}
+
+	attachments, err := models.GetAttachmentsForEvent(eventId)
+
+	if err != nil {
+		context.JSON(http.StatusInternalServerError, gin.H{"message": "Could not fetch attachments, try again later"})
+		return
+	}
+
+	context.JSON(http.StatusOK, gin.H{"attachments": attachments})
+}
+
+func uploadAttachment(context *gin.Context) {
+	event, ok := loadOrganizedEvent(context)
+
+	if !ok {
+		return
+	}
+
+	userId := context.GetInt64("userId")
+
+	// Leave room for the multipart envelope around the file itself.
+	context.Request.Body = http.MaxBytesReader(context.Writer, context.Request.Body, maxAttachmentSize+1<<20)
+
+	kind := context.DefaultPostForm("kind", models.AttachmentKindFile)
+
+	if kind != models.AttachmentKindCover && kind != models.AttachmentKindFile {
+		context.JSON(http.StatusBadRequest, gin.H{"message": "Invalid attachment kind, use cover or attachment."})
+		return
+	}
+
+	fileHeader, err := context.FormFile("file")
+
+	if err != nil {
+		var maxBytesErr *http.MaxBytesError
+		if errors.As(err, &maxBytesErr) {
+			context.JSON(http.StatusRequestEntityTooLarge, gin.H{"message": "File is too large."})
+			return
+		}
+		context.JSON(http.StatusBadRequest, gin.H{"message": "Could not read the uploaded file."})
+		return
+	}
+
+	if fileHeader.Size > maxAttachmentSize {
+		context.JSON(http.StatusRequestEntityTooLarge, gin.H{"message": "File is too large."})
+		return
+	}
+
+	file, err := fileHeader.Open()
+
+	if err != nil {
+		context.JSON(http.StatusBadRequest, gin.H{"message": "Could not read the uploaded file."})
+		return
+	}
+
+	defer file.Close()
+
+	// Trust the file contents, not the client-supplied content type.
+	head := make([]byte, 512)
+	n, _ := io.ReadFull(file, head)
+	contentType := http.DetectContentType(head[:n])
+	extension, ok := allowedAttachmentTypes[contentType]
+	isImage := strings.HasPrefix(contentType, "image/")
+
+	if !ok || (kind == models.AttachmentKindCover && !isImage) {
+		context.JSON(http.StatusUnsupportedMediaType, gin.H{"message": "Unsupported file type."})
+		return
+	}
+
+	name, err := utils.RandomToken(16)
+
+	if err != nil {
+		context.JSON(http.StatusInternalServerError, gin.H{"message": "Could not store attachment, try again later"})
+		return
+	}
+
+	attachment := models.Attachment{
+		EventID:     event.ID,
+		Kind:        kind,
+		FileName:    fileHeader.Filename,
+		ContentType: contentType,
+		Size:        fileHeader.Size,
+		StorageKey:  fmt.Sprintf("events/%d/%s%s", event.ID, name, extension),
+		UserID:      userId,
+	}
+
+	if isImage {
+		file.Seek(0, io.SeekStart)
+		thumbnail, thumbnailType, err := utils.Thumbnail(file, thumbnailSize)
+
+		if errors.Is(err, utils.ErrImageTooLarge) {
+			context.JSON(http.StatusRequestEntityTooLarge, gin.H{"message": "Image dimensions are too large."})
+			return
+		}
+
+		if err != nil {
+			context.JSON(http.StatusUnsupportedMediaType, gin.H{"message": "Could not decode image."})
+			return
+		}
+
+		attachment.ThumbnailContentType = thumbnailType
+		attachment.ThumbnailKey = fmt.Sprintf("events/%d/%s_thumb%s", event.ID, name, allowedAttachmentTypes[thumbnailType])
+
+		err = storage.Store.Put(attachment.ThumbnailKey, bytes.NewReader(thumbnail))
+
+		if err != nil {
+			context.JSON(http.StatusInternalServerError, gin.H{"message": "Could not store attachment, try again later"})
+			return
+		}
+	}
+
+	file.Seek(0, io.SeekStart)
+	err = storage.Store.Put(attachment.StorageKey, file)
+
+	if err == nil {
+		err = attachment.Save()
+	}
+
+	if err != nil {
+		removeAttachmentBlobs([]models.Attachment{attachment})
+		context.JSON(http.StatusInternalServerError, gin.H{"message": "Could not store attachment, try again later"})
+		return
+	}
+
+	if kind == models.AttachmentKindCover {
+		replaceCover(attachment)
+	}
+
+	context.JSON(http.StatusCreated, gin.H{"message": "Attachment uploaded!", "attachment": attachment})
+}
+
+func deleteAttachment(context *gin.Context) {
+	event, ok := loadOrganizedEvent(context)
+
+	if !ok {
+		return
+	}
+
+	attachmentId, err := strconv.ParseInt(context.Param("attachmentId"), 10, 64)
+
+	if err != nil {
+		context.JSON(http.StatusBadRequest, gin.H{"message": "Invalid attachment Id"})
+		return
+	}
+
+	attachment, err := models.GetAttachmentByID(attachmentId)
+
+	if err != nil || attachment.EventID != event.ID {
+		context.JSON(http.StatusNotFound, gin.H{"message": "Attachment not found."})
+		return
+	}
+
+	err = attachment.Delete()
+
+	if err != nil {
+		context.JSON(http.StatusInternalServerError, gin.H{"message": "Could not delete attachment, try again later"})
+		return
+	}
+
+	removeAttachmentBlobs([]models.Attachment{*attachment})
+	context.JSON(http.StatusOK, gin.H{"message": "Attachment deleted successfully"})
+}
+
+func serveAttachment(context *gin.Context) {
+	serveAttachmentBlob(context, false)
+}
+
+func serveThumbnail(context *gin.Context) {
+	serveAttachmentBlob(context, true)
+}
+
+// serveAttachmentBlob streams a stored file. Blob keys are random and never
+// reused, so responses can be cached for a year and revalidated by ETag.
+func serveAttachmentBlob(context *gin.Context, thumbnail bool) {
+	attachmentId, err := strconv.ParseInt(context.Param("id"), 10, 64)
+
+	if err != nil {
+		context.JSON(http.StatusBadRequest, gin.H{"message": "Invalid attachment Id"})
+		return
+	}
+
+	attachment, err := models.GetAttachmentByID(attachmentId)
+
+	if err != nil {
+		context.JSON(http.StatusNotFound, gin.H{"message": "Attachment not found."})
+		return
+	}
+
+	event, ok := loadVisibleEvent(context, attachment.EventID)
+
+	if !ok {
+		return
+	}
+
+	key, contentType := attachment.StorageKey, attachment.ContentType
+
+	if thumbnail {
+		if attachment.ThumbnailKey == "" {
+			context.JSON(http.StatusNotFound, gin.H{"message": "Attachment has no thumbnail."})
+			return
+		}
+		key, contentType = attachment.ThumbnailKey, attachment.ThumbnailContentType
+	}
+
+	blob, err := storage.Store.Open(key)
+
+	if err != nil {
+		context.JSON(http.StatusNotFound, gin.H{"message": "Attachment not found."})
+		return
+	}
+
+	defer blob.Close()
+
+	disposition := "inline"
+	if !strings.HasPrefix(contentType, "image/") {
+		disposition = "attachment"
+	}
+
+	context.Header("Content-Type", contentType)
+	context.Header("Content-Disposition", mime.FormatMediaType(disposition, map[string]string{"filename": attachment.FileName}))
+	// Shared caches must not keep files of private events.
+	cacheScope := "public"
+	if event.Visibility == models.VisibilityPrivate {
+		cacheScope = "private"
+	}
+
+	context.Header("Cache-Control", cacheScope+", max-age=31536000, immutable")
+	context.Header("ETag", strconv.Quote(key))
+	context.Header("X-Content-Type-Options", "nosniff")
+
+	http.ServeContent(context.Writer, context.Request, "", attachment.CreatedAt, blob)
+}
+
+// replaceCover removes every cover of the event other than current.
+func replaceCover(current models.Attachment) {
+	attachments, err := models.GetAttachmentsForEvent(current.EventID)
+
+	if err != nil {
+		return
+	}
+
+	var replaced []models.Attachment
+
+	for _, attachment := range attachments {
+		if attachment.Kind != models.AttachmentKindCover || attachment.ID == current.ID {
+			continue
+		}
+
+		if attachment.Delete() == nil {
+			replaced = append(replaced, attachment)
+		}
+	}
+
+	removeAttachmentBlobs(replaced)
+}
+
+// removeAttachmentBlobs deletes stored files after their records are gone.
+// Failures only leave orphaned blobs behind, so they are not reported.
+func removeAttachmentBlobs(attachments []models.Attachment) {
+	for _, attachment := range attachments {
+		for _, key := range attachment.BlobKeys() {
+			storage.Store.Delete(key)
+		}
+	}
+}
@@ -0,0 +1,265 @@
+package routes
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"hash/crc32"
+	"image"
+	"image/color"
+	"image/png"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/salads-source/go_http_server/db"
+	"github.com/salads-source/go_http_server/storage"
+	"github.com/stretchr/testify/assert"
+)
+
+// multipartBody builds an upload request body with a single file field.
+func multipartBody(t *testing.T, kind, filename string, content []byte) (*bytes.Buffer, string) {
+	body := &bytes.Buffer{}
+	writer := multipart.NewWriter(body)
+	if kind != "" {
+		writer.WriteField("kind", kind)
+	}
+	part, err := writer.CreateFormFile("file", filename)
+	if err != nil {
+		t.Fatalf("Failed to create form file: %v", err)
+	}
+	part.Write(content)
+	writer.Close()
+	return body, writer.FormDataContentType()
+}
+
+func testPNG(t *testing.T, width, height int) []byte {
+	img := image.NewNRGBA(image.Rect(0, 0, width, height))
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			img.Set(x, y, color.NRGBA{R: uint8(x), G: uint8(y), B: 200, A: 255})
+		}
+	}
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		t.Fatalf("Failed to encode test image: %v", err)
+	}
+	return buf.Bytes()
+}
+
+// oversizedPNG is a tiny PNG whose header claims it is width x height, like
+// a decompression bomb.
+func oversizedPNG(t *testing.T, width, height uint32) []byte {
+	content := testPNG(t, 1, 1)
+	// The IHDR chunk follows the 8 byte signature: length, type, then the
+	// width and height, and its CRC covers the type and data.
+	binary.BigEndian.PutUint32(content[16:], width)
+	binary.BigEndian.PutUint32(content[20:], height)
+	binary.BigEndian.PutUint32(content[29:], crc32.ChecksumIEEE(content[12:29]))
+	return content
+}
+
+func TestUploadAttachment(t *testing.T) {
+	_, router := setupTestRouter(t)
+	userId := createTestUser(t, db.DB, "uploader@example.com", "password123")
+	otherUserId := createTestUser(t, db.DB, "notuploader@example.com", "password123")
+	token := generateTestToken(t, "uploader@example.com", userId)
+	otherToken := generateTestToken(t, "notuploader@example.com", otherUserId)
+
+	result, err := db.DB.Exec(
+		"INSERT INTO events(name, description, location, dateTime, user_id) VALUES (?, ?, ?, ?, ?)",
+		"Upload Event", "Description", "Location", time.Now(), userId,
+	)
+	if err != nil {
+		t.Fatalf("Failed to create test event: %v", err)
+	}
+	eventId, _ := result.LastInsertId()
+
+	tests := []struct {
+		name           string
+		kind           string
+		filename       string
+		content        []byte
+		authToken      string
+		expectedStatus int
+		hasThumbnail   bool
+	}{
+		{
+			name:           "cover image with thumbnail",
+			kind:           "cover",
+			filename:       "cover.png",
+			content:        testPNG(t, 800, 400),
+			authToken:      token,
+			expectedStatus: http.StatusCreated,
+			hasThumbnail:   true,
+		},
+		{
+			name:           "pdf agenda",
+			filename:       "agenda.pdf",
+			content:        []byte("%PDF-1.4\n1 0 obj\n<<>>\nendobj\n%%EOF\n"),
+			authToken:      token,
+			expectedStatus: http.StatusCreated,
+		},
+		{
+			name:           "pdf is not a valid cover",
+			kind:           "cover",
+			filename:       "agenda.pdf",
+			content:        []byte("%PDF-1.4\n1 0 obj\n<<>>\nendobj\n%%EOF\n"),
+			authToken:      token,
+			expectedStatus: http.StatusUnsupportedMediaType,
+		},
+		{
+			name:           "disguised text file",
+			filename:       "notes.png",
+			content:        []byte("just some text"),
+			authToken:      token,
+			expectedStatus: http.StatusUnsupportedMediaType,
+		},
+		{
+			name:           "file too large",
+			filename:       "large.pdf",
+			content:        append([]byte("%PDF-1.4\n"), make([]byte, 65<<10)...),
+			authToken:      token,
+			expectedStatus: http.StatusRequestEntityTooLarge,
+		},
+		{
+			name:           "image declaring huge dimensions",
+			kind:           "cover",
+			filename:       "bomb.png",
+			content:        oversizedPNG(t, 50000, 50000),
+			authToken:      token,
+			expectedStatus: http.StatusRequestEntityTooLarge,
+		},
+		{
+			name:           "not the organizer",
+			filename:       "cover.png",
+			content:        testPNG(t, 10, 10),
+			authToken:      otherToken,
+			expectedStatus: http.StatusUnauthorized,
+		},
+		{
+			name:           "missing authorization",
+			filename:       "cover.png",
+			content:        testPNG(t, 10, 10),
+			expectedStatus: http.StatusUnauthorized,
+		},
+	}
+
+	originalMax := maxAttachmentSize
+	maxAttachmentSize = 64 << 10
+	t.Cleanup(func() { maxAttachmentSize = originalMax })
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			body, contentType := multipartBody(t, tt.kind, tt.filename, tt.content)
+			req, _ := http.NewRequest(http.MethodPost, "/events/"+strconv.FormatInt(eventId, 10)+"/attachments", body)
+			req.Header.Set("Content-Type", contentType)
+			if tt.authToken != "" {
+				req.Header.Set("Authorization", tt.authToken)
+			}
+
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			assert.Equal(t, tt.expectedStatus, w.Code)
+
+			if tt.expectedStatus == http.StatusCreated {
+				var response struct {
+					Attachment struct {
+						ID           int64
+						HasThumbnail bool
+					}
+				}
+				json.Unmarshal(w.Body.Bytes(), &response)
+				assert.NotZero(t, response.Attachment.ID)
+				assert.Equal(t, tt.hasThumbnail, response.Attachment.HasThumbnail)
+			}
+		})
+	}
+
+	t.Run("missing event", func(t *testing.T) {
+		body, contentType := multipartBody(t, "", "cover.png", testPNG(t, 10, 10))
+		req, _ := http.NewRequest(http.MethodPost, "/events/999999/attachments", body)
+		req.Header.Set("Content-Type", contentType)
+		req.Header.Set("Authorization", token)
+
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusNotFound, w.Code)
+	})
+}
+
+func TestServeAndCleanUpAttachments(t *testing.T) {
+	_, router := setupTestRouter(t)
+	userId := createTestUser(t, db.DB, "server@example.com", "password123")
+	token := generateTestToken(t, "server@example.com", userId)
+
+	result, err := db.DB.Exec(
+		"INSERT INTO events(name, description, location, dateTime, user_id) VALUES (?, ?, ?, ?, ?)",
+		"Upload Event", "Description", "Location", time.Now(), userId,
+	)
+	if err != nil {
+		t.Fatalf("Failed to create test event: %v", err)
+	}
+	eventId := strconv.FormatInt(func() int64 { id, _ := result.LastInsertId(); return id }(), 10)
+
+	body, contentType := multipartBody(t, "cover", "cover.png", testPNG(t, 640, 480))
+	req, _ := http.NewRequest(http.MethodPost, "/events/"+eventId+"/attachments", body)
+	req.Header.Set("Content-Type", contentType)
+	req.Header.Set("Authorization", token)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusCreated, w.Code)
+
+	var storageKey, thumbnailKey string
+	db.DB.QueryRow("SELECT storage_key, thumbnail_key FROM attachments").Scan(&storageKey, &thumbnailKey)
+
+	req, _ = http.NewRequest(http.MethodGet, "/attachments/1", nil)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "image/png", w.Header().Get("Content-Type"))
+	assert.Contains(t, w.Header().Get("Cache-Control"), "max-age=31536000")
+	etag := w.Header().Get("ETag")
+	assert.NotEmpty(t, etag)
+
+	req, _ = http.NewRequest(http.MethodGet, "/attachments/1", nil)
+	req.Header.Set("If-None-Match", etag)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusNotModified, w.Code)
+
+	req, _ = http.NewRequest(http.MethodGet, "/attachments/1/thumbnail", nil)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+	thumb, _, err := image.Decode(w.Body)
+	assert.NoError(t, err)
+	assert.Equal(t, thumbnailSize, thumb.Bounds().Dx())
+	assert.Equal(t, 240, thumb.Bounds().Dy())
+
+	req, _ = http.NewRequest(http.MethodDelete, "/events/999999/attachments/1", nil)
+	req.Header.Set("Authorization", token)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusNotFound, w.Code)
+	assert.Contains(t, w.Body.String(), "Event not found.")
+
+	req, _ = http.NewRequest(http.MethodDelete, "/events/"+eventId, nil)
+	req.Header.Set("Authorization", token)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	var count int
+	db.DB.QueryRow("SELECT COUNT(*) FROM attachments").Scan(&count)
+	assert.Equal(t, 0, count)
+
+	for _, key := range []string{storageKey, thumbnailKey} {
+		_, err := storage.Store.Open(key)
+		assert.ErrorIs(t, err, storage.ErrNotFound)
+	}
+}
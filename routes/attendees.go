@@ -0,0 +1,105 @@
+package routes
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/salads-source/go_http_server/models"
+)
+
+type attendeeInput struct {
+	Email string `binding:"required"`
+}
+
+func addAttendee(context *gin.Context) {
+	event, ok := loadOrganizedEvent(context)
+
+	if !ok {
+		return
+	}
+
+	var input attendeeInput
+	err := context.ShouldBindJSON(&input)
+
+	if err != nil {
+		context.JSON(http.StatusBadRequest, gin.H{"message": "Could not parse request data."})
+		return
+	}
+
+	registration, created, err := event.AddAttendee(input.Email)
+
+	if errors.Is(err, models.ErrInvalidEmail) {
+		context.JSON(http.StatusBadRequest, gin.H{"message": "Invalid email address."})
+		return
+	}
+
+	if errors.Is(err, models.ErrAlreadyRegistered) {
+		context.JSON(http.StatusConflict, gin.H{"message": "This person is already registered for the event."})
+		return
+	}
+
+	if errors.Is(err, models.ErrEventFull) {
+		context.JSON(http.StatusConflict, gin.H{"message": "Event is full."})
+		return
+	}
+
+	if err != nil {
+		context.JSON(http.StatusInternalServerError, gin.H{"message": "Could not add attendee, try again later"})
+		return
+	}
+
+	context.JSON(http.StatusCreated, gin.H{"message": "Attendee added", "registration": registration, "placeholderCreated": created})
+}
+
+func removeAttendee(context *gin.Context) {
+	event, ok := loadOrganizedEvent(context)
+
+	if !ok {
+		return
+	}
+
+	err := event.RemoveAttendee(context.Param("email"))
+
+	if errors.Is(err, models.ErrNotRegistered) {
+		context.JSON(http.StatusNotFound, gin.H{"message": "This person is not registered for the event."})
+		return
+	}
+
+	if err != nil {
+		context.JSON(http.StatusInternalServerError, gin.H{"message": "Could not remove attendee, try again later"})
+		return
+	}
+
+	context.JSON(http.StatusOK, gin.H{"message": "Attendee removed"})
+}
+
+func importAttendees(context *gin.Context) {
+	event, ok := loadOrganizedEvent(context)
+
+	if !ok {
+		return
+	}
+
+	mode, err := models.ParseImportMode(context.Query("mode"))
+
+	if err != nil {
+		context.JSON(http.StatusBadRequest, gin.H{"message": "Unsupported import mode, use atomic or best_effort."})
+		return
+	}
+
+	body := http.MaxBytesReader(context.Writer, context.Request.Body, maxImportSize)
+	result, err := event.ImportAttendees(body, mode)
+
+	if err != nil {
+		context.JSON(http.StatusBadRequest, gin.H{"message": "Could not read import data: " + err.Error()})
+		return
+	}
+
+	if mode == models.ImportModeAtomic && result.Imported == 0 && len(result.Errors) > 0 {
+		context.JSON(http.StatusUnprocessableEntity, gin.H{"message": "Import failed, no attendees were added.", "result": result})
+		return
+	}
+
+	context.JSON(http.StatusCreated, gin.H{"message": "Attendees imported!", "result": result})
+}
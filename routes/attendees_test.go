@@ -0,0 +1,176 @@
+package routes
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/salads-source/go_http_server/db"
+	"github.com/salads-source/go_http_server/mail"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAttendeeManagement(t *testing.T) {
+	_, router := setupTestRouter(t)
+	organizerId := createTestUser(t, db.DB, "attendeeorganizer@example.com", "password123")
+	memberId := createTestUser(t, db.DB, "attendeemember@example.com", "password123")
+	organizerToken := generateTestToken(t, "attendeeorganizer@example.com", organizerId)
+	memberToken := generateTestToken(t, "attendeemember@example.com", memberId)
+
+	eventId := createTestEvent(t, organizerId)
+	db.DB.Exec("UPDATE events SET capacity = 4, requires_approval = 1 WHERE id = ?", eventId)
+	eventPath := "/events/" + strconv.FormatInt(eventId, 10)
+
+	importCSV := func(mode, body string) (int, struct {
+		Total               int
+		Imported            int
+		PlaceholdersCreated int
+		Errors              []struct{ Row int }
+	}) {
+		req, _ := http.NewRequest(http.MethodPost, eventPath+"/attendees/import?mode="+mode, strings.NewReader(body))
+		req.Header.Set("Content-Type", "text/csv")
+		req.Header.Set("Authorization", organizerToken)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+
+		var response struct {
+			Result struct {
+				Total               int
+				Imported            int
+				PlaceholdersCreated int
+				Errors              []struct{ Row int }
+			} `json:"result"`
+		}
+		json.Unmarshal(w.Body.Bytes(), &response)
+		return w.Code, response.Result
+	}
+
+	t.Run("only the organizer manages attendees", func(t *testing.T) {
+		w := postJSON(t, router, http.MethodPost, eventPath+"/attendees", memberToken, map[string]interface{}{"email": "attendeemember@example.com"})
+		assert.Equal(t, http.StatusUnauthorized, w.Code)
+	})
+
+	t.Run("adding an existing user", func(t *testing.T) {
+		w := postJSON(t, router, http.MethodPost, eventPath+"/attendees", organizerToken, map[string]interface{}{"email": "AttendeeMember@example.com"})
+		assert.Equal(t, http.StatusCreated, w.Code)
+
+		var response struct {
+			Registration struct {
+				UserID int64
+				Status string
+			} `json:"registration"`
+			PlaceholderCreated bool `json:"placeholderCreated"`
+		}
+		json.Unmarshal(w.Body.Bytes(), &response)
+		assert.Equal(t, memberId, response.Registration.UserID)
+		assert.Equal(t, "approved", response.Registration.Status)
+		assert.False(t, response.PlaceholderCreated)
+
+		w = postJSON(t, router, http.MethodPost, eventPath+"/attendees", organizerToken, map[string]interface{}{"email": "attendeemember@example.com"})
+		assert.Equal(t, http.StatusConflict, w.Code)
+
+		w = postJSON(t, router, http.MethodPost, eventPath+"/register", memberToken, nil)
+		assert.Equal(t, http.StatusConflict, w.Code)
+	})
+
+	t.Run("invalid emails are rejected", func(t *testing.T) {
+		w := postJSON(t, router, http.MethodPost, eventPath+"/attendees", organizerToken, map[string]interface{}{"email": "not an email"})
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+	})
+
+	t.Run("atomic import adds nothing when a row fails", func(t *testing.T) {
+		code, result := importCSV("atomic", "email\nnew1@example.com\nbroken\n")
+		assert.Equal(t, http.StatusUnprocessableEntity, code)
+		assert.Equal(t, 0, result.Imported)
+		assert.Equal(t, 2, result.Errors[0].Row)
+
+		code, result = importCSV("atomic", "name,email\nOne,new1@example.com\nMember,attendeemember@example.com\n")
+		assert.Equal(t, http.StatusUnprocessableEntity, code)
+		assert.Equal(t, 0, result.Imported)
+
+		var users int
+		db.DB.QueryRow("SELECT COUNT(*) FROM users WHERE email = 'new1@example.com'").Scan(&users)
+		assert.Equal(t, 0, users)
+	})
+
+	t.Run("best effort import creates placeholders and respects capacity", func(t *testing.T) {
+		code, result := importCSV("best_effort", "email\nnew1@example.com\nnew2@example.com\nattendeeorganizer@example.com\nnew3@example.com\n")
+		assert.Equal(t, http.StatusCreated, code)
+		assert.Equal(t, 4, result.Total)
+		assert.Equal(t, 3, result.Imported)
+		assert.Equal(t, 2, result.PlaceholdersCreated)
+		assert.Len(t, result.Errors, 1)
+		assert.Equal(t, 4, result.Errors[0].Row)
+	})
+
+	t.Run("placeholders cannot log in and are claimed by verifying the email", func(t *testing.T) {
+		mailPath := filepath.Join(t.TempDir(), "mail.txt")
+		mail.Default = mail.NewFileMailer(mailPath)
+
+		w := postJSON(t, router, http.MethodPost, "/login", "", map[string]interface{}{"email": "new1@example.com", "password": "password123"})
+		assert.Equal(t, http.StatusUnauthorized, w.Code)
+
+		w = postJSON(t, router, http.MethodPost, "/signup", "", map[string]interface{}{"email": "New1@example.com", "password": "password123"})
+		assert.Equal(t, http.StatusCreated, w.Code)
+
+		w = postJSON(t, router, http.MethodPost, "/login", "", map[string]interface{}{"email": "New1@example.com", "password": "password123"})
+		assert.Equal(t, http.StatusCreated, w.Code)
+
+		var login struct {
+			Token string `json:"token"`
+		}
+		json.Unmarshal(w.Body.Bytes(), &login)
+
+		// Signing up alone does not hand over the placeholder's places, nor
+		// does being added to another event before verifying.
+		assert.Equal(t, http.StatusNotFound, getAs(router, eventPath+"/register", login.Token).Code)
+
+		otherPath := "/events/" + strconv.FormatInt(createTestEvent(t, organizerId), 10)
+		w = postJSON(t, router, http.MethodPost, otherPath+"/attendees", organizerToken, map[string]interface{}{"email": "new1@example.com"})
+		assert.Equal(t, http.StatusCreated, w.Code)
+		assert.Equal(t, http.StatusNotFound, getAs(router, otherPath+"/register", login.Token).Code)
+
+		tokenPattern := regexp.MustCompile(`(?m)^[0-9a-f]{64}\r?$`)
+		var token string
+		assert.Eventually(t, func() bool {
+			tokens := tokenPattern.FindAllString(readMail(mailPath), -1)
+			if len(tokens) == 0 {
+				return false
+			}
+			token = tokens[0][:64]
+			return true
+		}, 5*time.Second, 10*time.Millisecond)
+
+		w = postJSON(t, router, http.MethodPost, "/verify-email", "", map[string]interface{}{"token": token})
+		assert.Equal(t, http.StatusOK, w.Code)
+
+		assert.Equal(t, http.StatusOK, getAs(router, eventPath+"/register", login.Token).Code)
+		assert.Equal(t, http.StatusOK, getAs(router, otherPath+"/register", login.Token).Code)
+
+		var placeholders int
+		db.DB.QueryRow("SELECT COUNT(*) FROM users WHERE placeholder = 1 AND email LIKE '%new1@example.com'").Scan(&placeholders)
+		assert.Zero(t, placeholders)
+
+		// Verified accounts are added directly.
+		w = postJSON(t, router, http.MethodPost, "/events/"+strconv.FormatInt(createTestEvent(t, organizerId), 10)+"/attendees", organizerToken, map[string]interface{}{"email": "new1@example.com"})
+		assert.Equal(t, http.StatusCreated, w.Code)
+		assert.Contains(t, w.Body.String(), `"placeholderCreated":false`)
+	})
+
+	t.Run("removing attendees", func(t *testing.T) {
+		w := postJSON(t, router, http.MethodDelete, eventPath+"/attendees/new2@example.com", organizerToken, nil)
+		assert.Equal(t, http.StatusOK, w.Code)
+
+		w = postJSON(t, router, http.MethodDelete, eventPath+"/attendees/new2@example.com", organizerToken, nil)
+		assert.Equal(t, http.StatusNotFound, w.Code)
+
+		w = postJSON(t, router, http.MethodPost, eventPath+"/attendees", organizerToken, map[string]interface{}{"email": "new3@example.com"})
+		assert.Equal(t, http.StatusCreated, w.Code)
+	})
+}
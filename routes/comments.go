@@ -0,0 +1,217 @@
+package routes
+
+import (
+	"database/sql"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/salads-source/go_http_server/models"
+)
+
+type commentInput struct {
+	Body     string `binding:"required,max=5000"`
+	ParentID *int64
+}
+
+func getComments(context *gin.Context) {
+	eventId, err := strconv.ParseInt(context.Param("id"), 10, 64)
+
+	if err != nil {
+		context.JSON(http.StatusBadRequest, gin.H{"message": "Invalid event Id"})
+		return
+	}
+
+	page, perPage, err := parsePagination(context)
+
+	if err != nil {
+		context.JSON(http.StatusBadRequest, gin.H{"message": err.Error()})
+		return
+	}
+
+	_, ok := loadVisibleEvent(context, eventId)
+
+	if !ok {
+		return
+	}
+
+	comments, total, err := models.GetCommentThreads(eventId, perPage, (page-1)*perPage)
+
+	if err != nil {
+		context.JSON(http.StatusInternalServerError, gin.H{"message": "Could not fetch comments, try again later"})
+		return
+	}
+
+	context.JSON(http.StatusOK, gin.H{"comments": comments, "page": page, "per_page": perPage, "total": total})
+}
+
+func createComment(context *gin.Context) {
+	eventId, err := strconv.ParseInt(context.Param("id"), 10, 64)
+
+	if err != nil {
+		context.JSON(http.StatusBadRequest, gin.H{"message": "Invalid event Id"})
+		return
+	}
+
+	var input commentInput
+	err = context.ShouldBindJSON(&input)
+
+	if err != nil || strings.TrimSpace(input.Body) == "" {
+		context.JSON(http.StatusBadRequest, gin.H{"message": "Could not parse request data."})
+		return
+	}
+
+	userId := context.GetInt64("userId")
+	event, ok := loadVisibleEvent(context, eventId)
+
+	if !ok {
+		return
+	}
+
+	if event.CommentsRegisteredOnly && !canManageEvent(context, event) {
+		registered, err := models.IsRegistered(eventId, userId)
+
+		if err != nil {
+			context.JSON(http.StatusInternalServerError, gin.H{"message": "Could not save comment, try again later"})
+			return
+		}
+
+		if !registered {
+			context.JSON(http.StatusForbidden, gin.H{"message": "Only registered attendees can comment on this event."})
+			return
+		}
+	}
+
+	comment := models.Comment{
+		EventID:  eventId,
+		UserID:   userId,
+		ParentID: input.ParentID,
+		Body:     strings.TrimSpace(input.Body),
+	}
+
+	err = comment.Save()
+
+	if errors.Is(err, models.ErrInvalidParentComment) {
+		context.JSON(http.StatusBadRequest, gin.H{"message": "Cannot reply to that comment."})
+		return
+	}
+
+	if err != nil {
+		context.JSON(http.StatusInternalServerError, gin.H{"message": "Could not save comment, try again later"})
+		return
+	}
+
+	context.JSON(http.StatusCreated, gin.H{"message": "Comment created!", "comment": comment})
+}
+
+func updateComment(context *gin.Context) {
+	comment, ok := loadEventComment(context)
+
+	if !ok {
+		return
+	}
+
+	if comment.UserID != context.GetInt64("userId") {
+		context.JSON(http.StatusUnauthorized, gin.H{"message": "Not authorized to update comment."})
+		return
+	}
+
+	if comment.Status != models.CommentStatusVisible {
+		context.JSON(http.StatusConflict, gin.H{"message": "Comment has been deleted."})
+		return
+	}
+
+	var input commentInput
+	err := context.ShouldBindJSON(&input)
+
+	if err != nil || strings.TrimSpace(input.Body) == "" {
+		context.JSON(http.StatusBadRequest, gin.H{"message": "Could not parse request data."})
+		return
+	}
+
+	err = comment.UpdateBody(strings.TrimSpace(input.Body))
+
+	if err != nil {
+		context.JSON(http.StatusInternalServerError, gin.H{"message": "Could not update comment."})
+		return
+	}
+
+	context.JSON(http.StatusOK, gin.H{"message": "Comment updated successfully!", "comment": comment})
+}
+
+// deleteComment lets the author withdraw their comment and the event
+// organizer remove any comment on their event.
+func deleteComment(context *gin.Context) {
+	comment, ok := loadEventComment(context)
+
+	if !ok {
+		return
+	}
+
+	userId := context.GetInt64("userId")
+	status := models.CommentStatusDeleted
+
+	if comment.UserID != userId {
+		event, err := models.GetEventByID(comment.EventID)
+
+		if errors.Is(err, sql.ErrNoRows) {
+			context.JSON(http.StatusNotFound, gin.H{"message": "Event not found."})
+			return
+		}
+
+		if err != nil {
+			context.JSON(http.StatusInternalServerError, gin.H{"message": "Could not fetch event, try again later"})
+			return
+		}
+
+		if !canManageEvent(context, event) {
+			context.JSON(http.StatusUnauthorized, gin.H{"message": "Not authorized to delete comment."})
+			return
+		}
+
+		status = models.CommentStatusRemoved
+	}
+
+	if comment.Status != models.CommentStatusVisible {
+		context.JSON(http.StatusOK, gin.H{"message": "Comment deleted successfully"})
+		return
+	}
+
+	err := comment.Withdraw(status)
+
+	if err != nil {
+		context.JSON(http.StatusInternalServerError, gin.H{"message": "Could not delete comment, try again later"})
+		return
+	}
+
+	context.JSON(http.StatusOK, gin.H{"message": "Comment deleted successfully"})
+}
+
+// loadEventComment resolves :id and :commentId, writing an error response and
+// returning false when the comment does not exist on that event.
+func loadEventComment(context *gin.Context) (*models.Comment, bool) {
+	eventId, err := strconv.ParseInt(context.Param("id"), 10, 64)
+
+	if err != nil {
+		context.JSON(http.StatusBadRequest, gin.H{"message": "Invalid event Id"})
+		return nil, false
+	}
+
+	commentId, err := strconv.ParseInt(context.Param("commentId"), 10, 64)
+
+	if err != nil {
+		context.JSON(http.StatusBadRequest, gin.H{"message": "Invalid comment Id"})
+		return nil, false
+	}
+
+	comment, err := models.GetCommentByID(commentId)
+
+	if err != nil || comment.EventID != eventId {
+		context.JSON(http.StatusNotFound, gin.H{"message": "Comment not found."})
+		return nil, false
+	}
+
+	return comment, true
+}
@@ -0,0 +1,185 @@
+package routes
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/salads-source/go_http_server/db"
+	"github.com/stretchr/testify/assert"
+)
+
+type commentResponse struct {
+	ID       int64
+	ParentID *int64
+	Body     string
+	Status   string
+	Replies  []commentResponse
+}
+
+func TestCommentThreads(t *testing.T) {
+	_, router := setupTestRouter(t)
+	organizerId := createTestUser(t, db.DB, "commentorganizer@example.com", "password123")
+	authorId := createTestUser(t, db.DB, "commentauthor@example.com", "password123")
+	otherId := createTestUser(t, db.DB, "commentother@example.com", "password123")
+	organizerToken := generateTestToken(t, "commentorganizer@example.com", organizerId)
+	authorToken := generateTestToken(t, "commentauthor@example.com", authorId)
+	otherToken := generateTestToken(t, "commentother@example.com", otherId)
+
+	eventId := createTestEvent(t, organizerId)
+	path := "/events/" + strconv.FormatInt(eventId, 10) + "/comments"
+
+	w := postJSON(t, router, http.MethodPost, path, authorToken, map[string]interface{}{"body": "Is there parking?"})
+	assert.Equal(t, http.StatusCreated, w.Code)
+	var created struct{ Comment commentResponse }
+	json.Unmarshal(w.Body.Bytes(), &created)
+	rootId := created.Comment.ID
+
+	w = postJSON(t, router, http.MethodPost, path, organizerToken, map[string]interface{}{"body": "Yes, behind the venue.", "parentId": rootId})
+	assert.Equal(t, http.StatusCreated, w.Code)
+	json.Unmarshal(w.Body.Bytes(), &created)
+	replyId := created.Comment.ID
+
+	w = postJSON(t, router, http.MethodPost, path, authorToken, map[string]interface{}{"body": "Thanks!", "parentId": replyId})
+	assert.Equal(t, http.StatusCreated, w.Code)
+
+	w = postJSON(t, router, http.MethodPost, path, otherToken, map[string]interface{}{"body": "Second question"})
+	assert.Equal(t, http.StatusCreated, w.Code)
+
+	t.Run("reply to unknown parent", func(t *testing.T) {
+		w := postJSON(t, router, http.MethodPost, path, otherToken, map[string]interface{}{"body": "Hello", "parentId": 999})
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+	})
+
+	t.Run("empty body", func(t *testing.T) {
+		w := postJSON(t, router, http.MethodPost, path, otherToken, map[string]interface{}{"body": "   "})
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+	})
+
+	t.Run("threads are nested and paginated", func(t *testing.T) {
+		req, _ := http.NewRequest(http.MethodGet, path+"?per_page=1", nil)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusOK, w.Code)
+
+		var response struct {
+			Comments []commentResponse `json:"comments"`
+			Total    int               `json:"total"`
+		}
+		json.Unmarshal(w.Body.Bytes(), &response)
+		assert.Equal(t, 2, response.Total)
+		assert.Len(t, response.Comments, 1)
+		assert.Equal(t, rootId, response.Comments[0].ID)
+		assert.Len(t, response.Comments[0].Replies, 1)
+		assert.Len(t, response.Comments[0].Replies[0].Replies, 1)
+
+		req, _ = http.NewRequest(http.MethodGet, path+"?per_page=1&page=2", nil)
+		w = httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		json.Unmarshal(w.Body.Bytes(), &response)
+		assert.Len(t, response.Comments, 1)
+		assert.Equal(t, "Second question", response.Comments[0].Body)
+	})
+
+	t.Run("comment count is returned with the event", func(t *testing.T) {
+		req, _ := http.NewRequest(http.MethodGet, "/events/"+strconv.FormatInt(eventId, 10), nil)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+
+		var event map[string]interface{}
+		json.Unmarshal(w.Body.Bytes(), &event)
+		assert.Equal(t, float64(4), event["CommentCount"])
+	})
+
+	commentPath := path + "/" + strconv.FormatInt(rootId, 10)
+
+	t.Run("only the author can edit", func(t *testing.T) {
+		w := postJSON(t, router, http.MethodPut, commentPath, otherToken, map[string]interface{}{"body": "Edited"})
+		assert.Equal(t, http.StatusUnauthorized, w.Code)
+
+		w = postJSON(t, router, http.MethodPut, commentPath, authorToken, map[string]interface{}{"body": "Is there free parking?"})
+		assert.Equal(t, http.StatusOK, w.Code)
+	})
+
+	t.Run("strangers cannot delete", func(t *testing.T) {
+		w := postJSON(t, router, http.MethodDelete, commentPath, otherToken, nil)
+		assert.Equal(t, http.StatusUnauthorized, w.Code)
+	})
+
+	t.Run("organizer can remove", func(t *testing.T) {
+		w := postJSON(t, router, http.MethodDelete, commentPath, organizerToken, nil)
+		assert.Equal(t, http.StatusOK, w.Code)
+
+		var status, body string
+		db.DB.QueryRow("SELECT status, body FROM comments WHERE id = ?", rootId).Scan(&status, &body)
+		assert.Equal(t, "removed", status)
+
+		req, _ := http.NewRequest(http.MethodGet, path, nil)
+		w = httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		var response struct {
+			Comments []commentResponse `json:"comments"`
+		}
+		json.Unmarshal(w.Body.Bytes(), &response)
+		assert.Equal(t, "", response.Comments[0].Body)
+		assert.Len(t, response.Comments[0].Replies, 1)
+	})
+
+	t.Run("organizer deleting own reply withdraws it", func(t *testing.T) {
+		w := postJSON(t, router, http.MethodDelete, path+"/"+strconv.FormatInt(replyId, 10), organizerToken, nil)
+		assert.Equal(t, http.StatusOK, w.Code)
+
+		var status string
+		db.DB.QueryRow("SELECT status FROM comments WHERE id = ?", replyId).Scan(&status)
+		assert.Equal(t, "deleted", status)
+	})
+
+	t.Run("comments left on a missing event", func(t *testing.T) {
+		result, err := db.DB.Exec("INSERT INTO comments(event_id, user_id, body, created_at) VALUES (999999, ?, 'Orphaned', ?)",
+			authorId, time.Now().UTC())
+		if err != nil {
+			t.Fatalf("Failed to create test comment: %v", err)
+		}
+		orphanId, _ := result.LastInsertId()
+
+		w := postJSON(t, router, http.MethodDelete, "/events/999999/comments/"+strconv.FormatInt(orphanId, 10), organizerToken, nil)
+		assert.Equal(t, http.StatusNotFound, w.Code)
+		assert.Contains(t, w.Body.String(), "Event not found.")
+	})
+}
+
+func TestCommentsRegisteredOnly(t *testing.T) {
+	_, router := setupTestRouter(t)
+	organizerId := createTestUser(t, db.DB, "restrictedorganizer@example.com", "password123")
+	attendeeId := createTestUser(t, db.DB, "restrictedattendee@example.com", "password123")
+	outsiderId := createTestUser(t, db.DB, "restrictedoutsider@example.com", "password123")
+	adminId := createTestUser(t, db.DB, "restrictedadmin@example.com", "password123")
+	db.DB.Exec("UPDATE users SET role = 'admin' WHERE id = ?", adminId)
+
+	eventId := createTestEvent(t, organizerId)
+	db.DB.Exec("UPDATE events SET comments_registered_only = 1 WHERE id = ?", eventId)
+	db.DB.Exec("INSERT INTO registrations(event_id, user_id) VALUES (?, ?)", eventId, attendeeId)
+	path := "/events/" + strconv.FormatInt(eventId, 10) + "/comments"
+
+	tests := []struct {
+		name           string
+		email          string
+		userId         int64
+		expectedStatus int
+	}{
+		{name: "registered attendee", email: "restrictedattendee@example.com", userId: attendeeId, expectedStatus: http.StatusCreated},
+		{name: "organizer", email: "restrictedorganizer@example.com", userId: organizerId, expectedStatus: http.StatusCreated},
+		{name: "admin", email: "restrictedadmin@example.com", userId: adminId, expectedStatus: http.StatusCreated},
+		{name: "not registered", email: "restrictedoutsider@example.com", userId: outsiderId, expectedStatus: http.StatusForbidden},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := postJSON(t, router, http.MethodPost, path, generateTestToken(t, tt.email, tt.userId), map[string]interface{}{"body": "Hello"})
+			assert.Equal(t, tt.expectedStatus, w.Code)
+		})
+	}
+}
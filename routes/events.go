@@ -9,7 +9,19 @@ import (
 )
 
 func getEvents(context *gin.Context) {
-	events, err := models.GetAllEvents()
+	filter, err := parseEventFilter(context)
+
+	if err != nil {
+		context.JSON(http.StatusBadRequest, gin.H{"message": err.Error()})
+		return
+	}
+
+	filter.ViewerID = context.GetInt64("userId")
+	events, err := models.GetAllEvents(filter)
+
+	if err == nil {
+		err = attachOrganizers(events)
+	}
 
 	if err != nil {
 		context.JSON(http.StatusInternalServerError, gin.H{"message": "Could not fetch events, try again later"})
@@ -19,6 +31,36 @@ func getEvents(context *gin.Context) {
 	context.JSON(http.StatusOK, events)
 }
 
+// getMyEvents lists the events the caller organizes, whatever their
+// visibility. The usual event filters apply, except user_id.
+func getMyEvents(context *gin.Context) {
+	filter, err := parseEventFilter(context)
+
+	if err != nil {
+		context.JSON(http.StatusBadRequest, gin.H{"message": err.Error()})
+		return
+	}
+
+	filter.UserID = context.GetInt64("userId")
+	filter.ViewerID = filter.UserID
+	events, err := models.GetAllEvents(filter)
+
+	if err == nil {
+		err = attachOrganizers(events)
+	}
+
+	if err != nil {
+		context.JSON(http.StatusInternalServerError, gin.H{"message": "Could not fetch events, try again later"})
+		return
+	}
+
+	if events == nil {
+		events = []models.Event{}
+	}
+
+	context.JSON(http.StatusOK, gin.H{"message": "Events fetched successfully", "events": events})
+}
+
 func getEvent(context *gin.Context) {
 	eventId, err := strconv.ParseInt(context.Param("id"), 10, 64)
 
@@ -27,7 +69,13 @@ func getEvent(context *gin.Context) {
 		return
 	}
 
-	event, err := models.GetEventByID(eventId)
+	event, ok := loadVisibleEvent(context, eventId)
+
+	if !ok {
+		return
+	}
+
+	err = models.AttachOrganizers(event)
 
 	if err != nil {
 		context.JSON(http.StatusInternalServerError, gin.H{"message": "Could not fetch event, try again later"})
@@ -47,49 +95,77 @@ func createEvent(context *gin.Context) {
 		return
 	}
 
+	err = event.NormalizeSchedule()
+
+	if err != nil {
+		context.JSON(http.StatusBadRequest, gin.H{"message": err.Error()})
+		return
+	}
+
 	userId := context.GetInt64("userId")
 	event.UserID = userId
 
 	err = event.Save()
 
+	if status := bookingErrorStatus(err); status != 0 {
+		context.JSON(status, gin.H{"message": err.Error()})
+		return
+	}
+
 	if err != nil {
 		context.JSON(http.StatusInternalServerError, gin.H{"message": "Could not save event, try again later"})
 		return
 	}
 
+	// The event is saved, so a missing organizer profile is not worth
+	// failing the request over.
+	models.AttachOrganizers(&event)
+
 	context.JSON(http.StatusCreated, gin.H{"message": "Event created!", "event": event})
 }
 
 func updateEvent(context *gin.Context) {
-	eventId, err := strconv.ParseInt(context.Param("id"), 10, 64)
-	if err != nil {
-		context.JSON(http.StatusBadRequest, gin.H{"message": "Could not parse event id."})
+	event, ok := loadOrganizedEvent(context)
+
+	if !ok {
 		return
 	}
 
 	userId := context.GetInt64("userId")
-	event, err := models.GetEventByID(eventId)
+
+	// Start from the event as it is, so fields the request leaves out keep
+	// their values rather than resetting, and clients that predate a
+	// setting don't turn it off. The end is left out too so that a new
+	// start time can be sent on its own; the event then keeps its length.
+	updatedEvent := *event
+	updatedEvent.EndDateTime = nil
+	updatedEvent.DurationMinutes = 0
+	err := context.ShouldBindJSON(&updatedEvent)
 
 	if err != nil {
-		context.JSON(http.StatusInternalServerError, gin.H{"message": "Could not fetch the event."})
+		context.JSON(http.StatusBadRequest, gin.H{"message": "Could not parse request data."})
 		return
 	}
 
-	if event.UserID != userId {
-		context.JSON(http.StatusUnauthorized, gin.H{"message": "Not authorized to update event."})
-		return
+	if updatedEvent.EndDateTime == nil && updatedEvent.DurationMinutes == 0 {
+		updatedEvent.DurationMinutes = event.DurationMinutes
 	}
 
-	var updatedEvent models.Event
-	err = context.ShouldBindJSON(&updatedEvent)
+	err = updatedEvent.NormalizeSchedule()
 
 	if err != nil {
-		context.JSON(http.StatusBadRequest, gin.H{"message": "Could not parse request data."})
+		context.JSON(http.StatusBadRequest, gin.H{"message": err.Error()})
+		return
+	}
+
+	updatedEvent.ID = event.ID
+	err = updatedEvent.Update(userId)
+
+	if status := bookingErrorStatus(err); status != 0 {
+		context.JSON(status, gin.H{"message": err.Error()})
 		return
 	}
 
-	updatedEvent.ID = eventId
-	err = updatedEvent.Update()
 	if err != nil {
 		context.JSON(http.StatusInternalServerError, gin.H{"message": "Could not update event."})
 		return
@@ -98,32 +174,39 @@ func updateEvent(context *gin.Context) {
 }
 
 func deleteEvent(context *gin.Context) {
-	eventId, err := strconv.ParseInt(context.Param("id"), 10, 64)
+	event, ok := loadOrganizedEvent(context)
 
-	if err != nil {
-		context.JSON(http.StatusBadRequest, gin.H{"message": "Invalid event Id"})
+	if !ok {
 		return
 	}
 
 	userId := context.GetInt64("userId")
-	event, err := models.GetEventByID(eventId)
+	attachments, err := models.GetAttachmentsForEvent(event.ID)
 
 	if err != nil {
-		context.JSON(http.StatusInternalServerError, gin.H{"message": "Could not fetch event, try again later"})
-		return
-	}
-
-	if event.UserID != userId {
-		context.JSON(http.StatusUnauthorized, gin.H{"message": "Not authorized to update event."})
+		context.JSON(http.StatusInternalServerError, gin.H{"message": "Could not delete event, try again later"})
 		return
 	}
 
-	err = event.Delete()
+	err = event.Delete(userId)
 
 	if err != nil {
 		context.JSON(http.StatusInternalServerError, gin.H{"message": "Could not delete event, try again later"})
 		return
 	}
 
+	removeAttachmentBlobs(attachments)
+
 	context.JSON(http.StatusOK, gin.H{"message": "event deleted successfully"})
 }
+
+// attachOrganizers fills in the organizer profiles of a listing.
+func attachOrganizers(events []models.Event) error {
+	pointers := make([]*models.Event, len(events))
+
+	for i := range events {
+		pointers[i] = &events[i]
+	}
+
+	return models.AttachOrganizers(pointers...)
+}
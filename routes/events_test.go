@@ -90,7 +90,7 @@ func TestGetEvent(t *testing.T) {
 		{
 			name:           "get event by id - not found",
 			eventID:        "999",
-			expectedStatus: http.StatusInternalServerError,
+			expectedStatus: http.StatusNotFound,
 			setupEvent:     false,
 		},
 	}
@@ -314,7 +314,7 @@ func TestUpdateEvent(t *testing.T) {
 				"dateTime":    time.Now().Format(time.RFC3339),
 			},
 			authToken:      token,
-			expectedStatus: http.StatusInternalServerError,
+			expectedStatus: http.StatusNotFound,
 			setupEvent:     false,
 		},
 	}
@@ -402,7 +402,7 @@ func TestDeleteEvent(t *testing.T) {
 			name:           "event not found",
 			eventID:        "999",
 			authToken:      token,
-			expectedStatus: http.StatusInternalServerError,
+			expectedStatus: http.StatusNotFound,
 			setupEvent:     false,
 		},
 	}
@@ -444,3 +444,124 @@ func TestDeleteEvent(t *testing.T) {
 	}
 }
 
+
+func TestUpdateEventKeepsOmittedSettings(t *testing.T) {
+	_, router := setupTestRouter(t)
+	userId := createTestUser(t, db.DB, "keepsettings@example.com", "password123")
+	token := generateTestToken(t, "keepsettings@example.com", userId)
+
+	w := postJSON(t, router, http.MethodPost, "/venues", token, map[string]interface{}{
+		"name":    "Town Hall",
+		"address": "2 Market Square",
+		"rooms":   []map[string]interface{}{{"name": "Main Room", "capacity": 80}},
+	})
+	assert.Equal(t, http.StatusCreated, w.Code)
+	var venue struct {
+		Venue struct {
+			ID    int64
+			Rooms []struct{ ID int64 }
+		} `json:"venue"`
+	}
+	json.Unmarshal(w.Body.Bytes(), &venue)
+
+	start := time.Now().Add(48 * time.Hour).UTC().Truncate(time.Second)
+	w = postJSON(t, router, http.MethodPost, "/events", token, map[string]interface{}{
+		"name":                      "Original Event",
+		"description":               "Description",
+		"dateTime":                  start,
+		"durationMinutes":           90,
+		"venueId":                   venue.Venue.ID,
+		"roomId":                    venue.Venue.Rooms[0].ID,
+		"capacity":                  40,
+		"maxGuests":                 2,
+		"cancellationCutoffMinutes": 60,
+		"requiresApproval":          true,
+		"allowTransfers":            true,
+		"visibility":                "unlisted",
+		"commentsRegisteredOnly":    true,
+		"feedbackCheckedInOnly":     true,
+	})
+	assert.Equal(t, http.StatusCreated, w.Code)
+	var created struct {
+		Event struct{ ID int64 } `json:"event"`
+	}
+	json.Unmarshal(w.Body.Bytes(), &created)
+	eventPath := "/events/" + strconv.FormatInt(created.Event.ID, 10)
+
+	w = postJSON(t, router, http.MethodPut, eventPath, token, map[string]interface{}{"name": "Renamed Event"})
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	w = getAs(router, eventPath, token)
+	assert.Equal(t, http.StatusOK, w.Code)
+	var event struct {
+		Name                      string
+		Description               string
+		DateTime                  time.Time
+		DurationMinutes           int
+		VenueID                   *int64
+		RoomID                    *int64
+		Capacity                  int
+		MaxGuests                 int
+		CancellationCutoffMinutes int
+		RequiresApproval          bool
+		AllowTransfers            bool
+		Visibility                string
+		CommentsRegisteredOnly    bool
+		FeedbackCheckedInOnly     bool
+	}
+	json.Unmarshal(w.Body.Bytes(), &event)
+	assert.Equal(t, "Renamed Event", event.Name)
+	assert.Equal(t, "Description", event.Description)
+	assert.True(t, start.Equal(event.DateTime))
+	assert.Equal(t, 90, event.DurationMinutes)
+	if assert.NotNil(t, event.VenueID) && assert.NotNil(t, event.RoomID) {
+		assert.Equal(t, venue.Venue.ID, *event.VenueID)
+		assert.Equal(t, venue.Venue.Rooms[0].ID, *event.RoomID)
+	}
+	assert.Equal(t, 40, event.Capacity)
+	assert.Equal(t, 2, event.MaxGuests)
+	assert.Equal(t, 60, event.CancellationCutoffMinutes)
+	assert.True(t, event.RequiresApproval)
+	assert.True(t, event.AllowTransfers)
+	assert.Equal(t, "unlisted", event.Visibility)
+	assert.True(t, event.CommentsRegisteredOnly)
+	assert.True(t, event.FeedbackCheckedInOnly)
+
+	t.Run("a new start keeps the length", func(t *testing.T) {
+		w := postJSON(t, router, http.MethodPut, eventPath, token, map[string]interface{}{"dateTime": start.Add(time.Hour)})
+		assert.Equal(t, http.StatusOK, w.Code)
+
+		var moved struct {
+			DateTime         time.Time
+			DurationMinutes  int
+			RequiresApproval bool
+		}
+		json.Unmarshal(getAs(router, eventPath, token).Body.Bytes(), &moved)
+		assert.True(t, start.Add(time.Hour).Equal(moved.DateTime))
+		assert.Equal(t, 90, moved.DurationMinutes)
+		assert.True(t, moved.RequiresApproval)
+	})
+}
+
+func TestDeleteEventRemovesRegistrations(t *testing.T) {
+	_, router := setupTestRouter(t)
+	organizerId := createTestUser(t, db.DB, "deleteorganizer@example.com", "password123")
+	attendeeId := createTestUser(t, db.DB, "deleteattendee@example.com", "password123")
+	token := generateTestToken(t, "deleteorganizer@example.com", organizerId)
+	eventId := createTestEvent(t, organizerId)
+
+	result, _ := db.DB.Exec("INSERT INTO registrations(event_id, user_id) VALUES (?, ?)", eventId, attendeeId)
+	registrationId, _ := result.LastInsertId()
+	db.DB.Exec("INSERT INTO registration_guests(registration_id, name, created_at) VALUES (?, 'Guest', ?)", registrationId, time.Now().UTC())
+	db.DB.Exec(`INSERT INTO registration_transfers(registration_id, event_id, from_user_id, to_email, status, created_at)
+	VALUES (?, ?, ?, 'friend@example.com', 'pending', ?)`, registrationId, eventId, attendeeId, time.Now().UTC())
+
+	w := postJSON(t, router, http.MethodDelete, "/events/"+strconv.FormatInt(eventId, 10), token, nil)
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	var leftovers int
+	db.DB.QueryRow(`SELECT (SELECT COUNT(*) FROM registrations WHERE event_id = ?)
+	+ (SELECT COUNT(*) FROM registration_guests WHERE registration_id = ?)
+	+ (SELECT COUNT(*) FROM registration_transfers WHERE event_id = ?)`, eventId, registrationId, eventId).Scan(&leftovers)
+	assert.Zero(t, leftovers)
+}
@@ -0,0 +1,86 @@
+package routes
+
+import (
+	"bufio"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/salads-source/go_http_server/models"
+	"github.com/salads-source/go_http_server/utils"
+)
+
+func exportEvents(context *gin.Context) {
+	filter, err := parseEventFilter(context)
+
+	if err != nil {
+		context.JSON(http.StatusBadRequest, gin.H{"message": err.Error()})
+		return
+	}
+
+	filter.ViewerID = context.GetInt64("userId")
+	access := models.EmailAccess{ViewerID: filter.ViewerID}
+
+	streamExport(context, "events", nil, func(write func(models.EventExportRow) error) error {
+		return models.StreamEvents(filter, access, write)
+	})
+}
+
+func exportRegistrations(context *gin.Context) {
+	filter, err := parseRegistrationFilter(context)
+
+	if err != nil {
+		context.JSON(http.StatusBadRequest, gin.H{"message": err.Error()})
+		return
+	}
+
+	filter.ViewerID = context.GetInt64("userId")
+	access := models.EmailAccess{ViewerID: filter.ViewerID}
+
+	streamExport(context, "registrations", nil, func(write func(models.RegistrationExportRow) error) error {
+		return models.StreamRegistrations(filter, access, write)
+	})
+}
+
+// streamExport writes rows straight to the response as stream produces them.
+// Once the first byte is sent the status can no longer change, so a failure
+// part-way through only truncates the output and is recorded on the context.
+// A header that is not nil replaces the one the rows give.
+func streamExport[T utils.CSVRecord](context *gin.Context, name string, header []string, stream func(func(T) error) error) {
+	format, err := utils.ParseExportFormat(context.Query("format"))
+
+	if err != nil {
+		context.JSON(http.StatusBadRequest, gin.H{"message": "Unsupported export format, use csv, ndjson or parquet."})
+		return
+	}
+
+	filename := fmt.Sprintf("%s-%s.%s", name, time.Now().UTC().Format("20060102T150405Z"), format)
+	context.Header("Content-Type", format.ContentType())
+	context.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="%s"`, filename))
+	context.Status(http.StatusOK)
+
+	buffered := bufio.NewWriter(context.Writer)
+	writer, err := utils.NewRecordWriter[T](buffered, format)
+
+	if err == nil && header != nil {
+		err = utils.WriteHeader(writer, header)
+	}
+
+	if err == nil {
+		err = stream(writer.Write)
+	}
+
+	if err == nil {
+		err = writer.Close()
+	}
+
+	if err == nil {
+		err = buffered.Flush()
+	}
+
+	if err != nil {
+		context.Error(err)
+		context.Abort()
+	}
+}
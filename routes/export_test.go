@@ -0,0 +1,171 @@
+package routes
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/parquet-go/parquet-go"
+	"github.com/salads-source/go_http_server/db"
+	"github.com/salads-source/go_http_server/models"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestExportEvents(t *testing.T) {
+	_, router := setupTestRouter(t)
+	ownerId := createTestUser(t, db.DB, "exportowner@example.com", "password123")
+	otherId := createTestUser(t, db.DB, "exportother@example.com", "password123")
+	ownerToken := generateTestToken(t, "exportowner@example.com", ownerId)
+
+	start := time.Date(2025, 3, 1, 18, 0, 0, 0, time.UTC)
+	for i, userId := range []int64{ownerId, otherId, ownerId} {
+		_, err := db.DB.Exec(
+			"INSERT INTO events(name, description, location, dateTime, user_id) VALUES (?, ?, ?, ?, ?)",
+			"Export Event", "Description", "Hall A", start.AddDate(0, 0, i), userId,
+		)
+		if err != nil {
+			t.Fatalf("Failed to create test event: %v", err)
+		}
+	}
+
+	t.Run("csv with organizer emails only for own events", func(t *testing.T) {
+		req, _ := http.NewRequest(http.MethodGet, "/events/export?format=csv", nil)
+		req.Header.Set("Authorization", ownerToken)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, "text/csv", w.Header().Get("Content-Type"))
+		assert.Contains(t, w.Header().Get("Content-Disposition"), "attachment")
+
+		records, err := csv.NewReader(w.Body).ReadAll()
+		assert.NoError(t, err)
+		assert.Len(t, records, 4)
+		assert.Equal(t, "organizer_email", records[0][6])
+		assert.Equal(t, "exportowner@example.com", records[1][6])
+		assert.Equal(t, "", records[2][6])
+	})
+
+	t.Run("ndjson with date filter", func(t *testing.T) {
+		req, _ := http.NewRequest(http.MethodGet, "/events/export?format=ndjson&from=2025-03-02T00:00:00Z", nil)
+		req.Header.Set("Authorization", ownerToken)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+
+		lines := 0
+		scanner := bufio.NewScanner(w.Body)
+		for scanner.Scan() {
+			var row models.EventExportRow
+			assert.NoError(t, json.Unmarshal(scanner.Bytes(), &row))
+			lines++
+		}
+		assert.Equal(t, 2, lines)
+	})
+
+	t.Run("parquet", func(t *testing.T) {
+		req, _ := http.NewRequest(http.MethodGet, "/events/export?format=parquet", nil)
+		req.Header.Set("Authorization", ownerToken)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+
+		body := w.Body.Bytes()
+		rows, err := parquet.Read[models.EventExportRow](bytes.NewReader(body), int64(len(body)))
+		assert.NoError(t, err)
+		assert.Len(t, rows, 3)
+		assert.True(t, rows[0].DateTime.Equal(start))
+	})
+
+	t.Run("unknown format", func(t *testing.T) {
+		req, _ := http.NewRequest(http.MethodGet, "/events/export?format=xlsx", nil)
+		req.Header.Set("Authorization", ownerToken)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+	})
+
+	t.Run("missing authorization", func(t *testing.T) {
+		req, _ := http.NewRequest(http.MethodGet, "/events/export", nil)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusUnauthorized, w.Code)
+	})
+}
+
+func TestExportRegistrations(t *testing.T) {
+	_, router := setupTestRouter(t)
+	organizerId := createTestUser(t, db.DB, "exportorganizer@example.com", "password123")
+	attendeeId := createTestUser(t, db.DB, "exportattendee@example.com", "password123")
+	strangerId := createTestUser(t, db.DB, "exportstranger@example.com", "password123")
+
+	result, err := db.DB.Exec(
+		"INSERT INTO events(name, description, location, dateTime, user_id) VALUES (?, ?, ?, ?, ?)",
+		"Export Event", "Description", "Hall A", time.Now(), organizerId,
+	)
+	if err != nil {
+		t.Fatalf("Failed to create test event: %v", err)
+	}
+	eventId, _ := result.LastInsertId()
+
+	_, err = db.DB.Exec("INSERT INTO registrations(event_id, user_id) VALUES (?, ?)", eventId, attendeeId)
+	if err != nil {
+		t.Fatalf("Failed to create test registration: %v", err)
+	}
+
+	tests := []struct {
+		name          string
+		userId        int64
+		email         string
+		expectedEmail string
+		expectedRows  int
+	}{
+		{
+			name:          "organizer sees attendee email",
+			userId:        organizerId,
+			email:         "exportorganizer@example.com",
+			expectedEmail: "exportattendee@example.com",
+			expectedRows:  1,
+		},
+		{
+			name:          "attendee sees own email",
+			userId:        attendeeId,
+			email:         "exportattendee@example.com",
+			expectedEmail: "exportattendee@example.com",
+			expectedRows:  1,
+		},
+		{
+			name:         "stranger does not see other registrations",
+			userId:       strangerId,
+			email:        "exportstranger@example.com",
+			expectedRows: 0,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req, _ := http.NewRequest(http.MethodGet, "/registrations/export?format=csv", nil)
+			req.Header.Set("Authorization", generateTestToken(t, tt.email, tt.userId))
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			assert.Equal(t, http.StatusOK, w.Code)
+
+			records, err := csv.NewReader(w.Body).ReadAll()
+			assert.NoError(t, err)
+			assert.Len(t, records, tt.expectedRows+1)
+			if tt.expectedRows > 0 {
+				assert.Equal(t, tt.expectedEmail, records[1][5])
+			}
+		})
+	}
+}
@@ -0,0 +1,152 @@
+package routes
+
+import (
+	"database/sql"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/salads-source/go_http_server/models"
+)
+
+func submitFeedback(context *gin.Context) {
+	eventId, err := strconv.ParseInt(context.Param("id"), 10, 64)
+
+	if err != nil {
+		context.JSON(http.StatusBadRequest, gin.H{"message": "Invalid event Id"})
+		return
+	}
+
+	var feedback models.Feedback
+	err = context.ShouldBindJSON(&feedback)
+
+	if err != nil {
+		context.JSON(http.StatusBadRequest, gin.H{"message": "Could not parse request data, rating must be between 1 and 5."})
+		return
+	}
+
+	userId := context.GetInt64("userId")
+	event, err := models.GetEventByID(eventId)
+
+	if errors.Is(err, sql.ErrNoRows) {
+		context.JSON(http.StatusNotFound, gin.H{"message": "Event not found."})
+		return
+	}
+
+	if err != nil {
+		context.JSON(http.StatusInternalServerError, gin.H{"message": "Could not fetch event, try again later"})
+		return
+	}
+
+	if event.DateTime.After(time.Now()) {
+		context.JSON(http.StatusForbidden, gin.H{"message": "Feedback opens once the event has taken place."})
+		return
+	}
+
+	registration, err := models.GetRegistration(eventId, userId)
+
+	if errors.Is(err, models.ErrNotRegistered) || (err == nil && registration.Status != models.RegistrationStatusApproved) {
+		context.JSON(http.StatusForbidden, gin.H{"message": "Only attendees can leave feedback."})
+		return
+	}
+
+	if err != nil {
+		context.JSON(http.StatusInternalServerError, gin.H{"message": "Could not save feedback, try again later"})
+		return
+	}
+
+	if event.FeedbackCheckedInOnly && registration.CheckedInAt == nil {
+		context.JSON(http.StatusForbidden, gin.H{"message": "Only attendees who checked in can leave feedback."})
+		return
+	}
+
+	feedback.EventID = eventId
+	feedback.UserID = userId
+	feedback.Review = strings.TrimSpace(feedback.Review)
+
+	err = feedback.Save()
+
+	if errors.Is(err, models.ErrFeedbackExists) {
+		context.JSON(http.StatusConflict, gin.H{"message": "You already left feedback for this event."})
+		return
+	}
+
+	if err != nil {
+		context.JSON(http.StatusInternalServerError, gin.H{"message": "Could not save feedback, try again later"})
+		return
+	}
+
+	context.JSON(http.StatusCreated, gin.H{"message": "Thanks for your feedback!", "feedback": feedback})
+}
+
+func getEventFeedback(context *gin.Context) {
+	event, ok := loadOrganizedEvent(context)
+
+	if !ok {
+		return
+	}
+
+	summary, err := models.GetEventFeedbackSummary(event.ID)
+
+	if err != nil {
+		context.JSON(http.StatusInternalServerError, gin.H{"message": "Could not fetch feedback, try again later"})
+		return
+	}
+
+	feedback, err := models.GetEventFeedback(event.ID)
+
+	if err != nil {
+		context.JSON(http.StatusInternalServerError, gin.H{"message": "Could not fetch feedback, try again later"})
+		return
+	}
+
+	context.JSON(http.StatusOK, gin.H{"summary": summary, "feedback": feedback})
+}
+
+func getOrganizerFeedback(context *gin.Context) {
+	overall, events, err := models.GetOrganizerFeedbackSummary(context.GetInt64("userId"))
+
+	if err != nil {
+		context.JSON(http.StatusInternalServerError, gin.H{"message": "Could not fetch feedback, try again later"})
+		return
+	}
+
+	context.JSON(http.StatusOK, gin.H{"summary": overall, "events": events})
+}
+
+func checkInAttendee(context *gin.Context) {
+	event, ok := loadOrganizedEvent(context)
+
+	if !ok {
+		return
+	}
+
+	attendeeId, err := strconv.ParseInt(context.Param("userId"), 10, 64)
+
+	if err != nil {
+		context.JSON(http.StatusBadRequest, gin.H{"message": "Invalid user Id"})
+		return
+	}
+
+	registration, err := event.CheckIn(attendeeId)
+
+	if errors.Is(err, models.ErrNotRegistered) {
+		context.JSON(http.StatusNotFound, gin.H{"message": "User is not registered for this event."})
+		return
+	}
+
+	if errors.Is(err, models.ErrNotApproved) {
+		context.JSON(http.StatusConflict, gin.H{"message": "Registration has not been approved."})
+		return
+	}
+
+	if err != nil {
+		context.JSON(http.StatusInternalServerError, gin.H{"message": "Could not check in attendee, try again later"})
+		return
+	}
+
+	context.JSON(http.StatusOK, gin.H{"message": "Attendee checked in", "registration": registration})
+}
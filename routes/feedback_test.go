@@ -0,0 +1,203 @@
+package routes
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/salads-source/go_http_server/db"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSubmitFeedback(t *testing.T) {
+	_, router := setupTestRouter(t)
+	organizerId := createTestUser(t, db.DB, "feedbackorganizer@example.com", "password123")
+	attendeeId := createTestUser(t, db.DB, "feedbackattendee@example.com", "password123")
+	outsiderId := createTestUser(t, db.DB, "feedbackoutsider@example.com", "password123")
+	attendeeToken := generateTestToken(t, "feedbackattendee@example.com", attendeeId)
+	outsiderToken := generateTestToken(t, "feedbackoutsider@example.com", outsiderId)
+	organizerToken := generateTestToken(t, "feedbackorganizer@example.com", organizerId)
+
+	pastEventId := createTestEvent(t, organizerId)
+	db.DB.Exec("UPDATE events SET dateTime = ? WHERE id = ?", time.Now().Add(-24*time.Hour), pastEventId)
+	futureEventId := createTestEvent(t, organizerId)
+	db.DB.Exec("UPDATE events SET dateTime = ? WHERE id = ?", time.Now().Add(24*time.Hour), futureEventId)
+	checkedInEventId := createTestEvent(t, organizerId)
+	db.DB.Exec("UPDATE events SET dateTime = ?, feedback_checked_in_only = 1 WHERE id = ?", time.Now().Add(-time.Hour), checkedInEventId)
+
+	for _, eventId := range []int64{pastEventId, futureEventId, checkedInEventId} {
+		db.DB.Exec("INSERT INTO registrations(event_id, user_id) VALUES (?, ?)", eventId, attendeeId)
+	}
+
+	feedbackPath := func(eventId int64) string {
+		return "/events/" + strconv.FormatInt(eventId, 10) + "/feedback"
+	}
+
+	tests := []struct {
+		name           string
+		eventId        int64
+		authToken      string
+		payload        map[string]interface{}
+		expectedStatus int
+	}{
+		{
+			name:           "attendee rates a past event",
+			eventId:        pastEventId,
+			authToken:      attendeeToken,
+			payload:        map[string]interface{}{"rating": 4, "review": "Great talks"},
+			expectedStatus: http.StatusCreated,
+		},
+		{
+			name:           "only once per event",
+			eventId:        pastEventId,
+			authToken:      attendeeToken,
+			payload:        map[string]interface{}{"rating": 5},
+			expectedStatus: http.StatusConflict,
+		},
+		{
+			name:           "event has not happened yet",
+			eventId:        futureEventId,
+			authToken:      attendeeToken,
+			payload:        map[string]interface{}{"rating": 5},
+			expectedStatus: http.StatusForbidden,
+		},
+		{
+			name:           "not registered",
+			eventId:        pastEventId,
+			authToken:      outsiderToken,
+			payload:        map[string]interface{}{"rating": 5},
+			expectedStatus: http.StatusForbidden,
+		},
+		{
+			name:           "rating out of range",
+			eventId:        pastEventId,
+			authToken:      outsiderToken,
+			payload:        map[string]interface{}{"rating": 6},
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name:           "check-in required",
+			eventId:        checkedInEventId,
+			authToken:      attendeeToken,
+			payload:        map[string]interface{}{"rating": 3},
+			expectedStatus: http.StatusForbidden,
+		},
+		{
+			name:           "missing event",
+			eventId:        999999,
+			authToken:      attendeeToken,
+			payload:        map[string]interface{}{"rating": 3},
+			expectedStatus: http.StatusNotFound,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := postJSON(t, router, http.MethodPost, feedbackPath(tt.eventId), tt.authToken, tt.payload)
+			assert.Equal(t, tt.expectedStatus, w.Code)
+		})
+	}
+
+	t.Run("feedback allowed after check-in", func(t *testing.T) {
+		checkInPath := "/events/" + strconv.FormatInt(checkedInEventId, 10) + "/check-in/" + strconv.FormatInt(attendeeId, 10)
+
+		w := postJSON(t, router, http.MethodPost, checkInPath, attendeeToken, nil)
+		assert.Equal(t, http.StatusUnauthorized, w.Code)
+
+		w = postJSON(t, router, http.MethodPost, checkInPath, organizerToken, nil)
+		assert.Equal(t, http.StatusOK, w.Code)
+
+		w = postJSON(t, router, http.MethodPost, "/events/999999/check-in/"+strconv.FormatInt(attendeeId, 10), organizerToken, nil)
+		assert.Equal(t, http.StatusNotFound, w.Code)
+
+		w = postJSON(t, router, http.MethodPost, feedbackPath(checkedInEventId), attendeeToken, map[string]interface{}{"rating": 2})
+		assert.Equal(t, http.StatusCreated, w.Code)
+	})
+}
+
+func TestFeedbackSummaries(t *testing.T) {
+	_, router := setupTestRouter(t)
+	organizerId := createTestUser(t, db.DB, "summaryorganizer@example.com", "password123")
+	organizerToken := generateTestToken(t, "summaryorganizer@example.com", organizerId)
+	otherToken := generateTestToken(t, "summaryother@example.com", organizerId+100)
+
+	firstEventId := createTestEvent(t, organizerId)
+	secondEventId := createTestEvent(t, organizerId)
+	createTestEvent(t, organizerId)
+
+	ratings := []struct {
+		eventId int64
+		rating  int
+	}{
+		{firstEventId, 5}, {firstEventId, 4}, {firstEventId, 5}, {secondEventId, 1},
+	}
+	for i, r := range ratings {
+		_, err := db.DB.Exec("INSERT INTO feedback(event_id, user_id, rating, created_at) VALUES (?, ?, ?, ?)", r.eventId, i+10, r.rating, time.Now())
+		if err != nil {
+			t.Fatalf("Failed to create test feedback: %v", err)
+		}
+	}
+
+	t.Run("per event", func(t *testing.T) {
+		req, _ := http.NewRequest(http.MethodGet, "/events/"+strconv.FormatInt(firstEventId, 10)+"/feedback", nil)
+		req.Header.Set("Authorization", organizerToken)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusOK, w.Code)
+
+		var response struct {
+			Summary struct {
+				Count        int
+				Average      float64
+				Distribution map[string]int
+			} `json:"summary"`
+			Feedback []interface{} `json:"feedback"`
+		}
+		json.Unmarshal(w.Body.Bytes(), &response)
+		assert.Equal(t, 3, response.Summary.Count)
+		assert.InDelta(t, 14.0/3.0, response.Summary.Average, 0.001)
+		assert.Equal(t, 2, response.Summary.Distribution["5"])
+		assert.Equal(t, 0, response.Summary.Distribution["1"])
+		assert.Len(t, response.Feedback, 3)
+	})
+
+	t.Run("only the organizer", func(t *testing.T) {
+		req, _ := http.NewRequest(http.MethodGet, "/events/"+strconv.FormatInt(firstEventId, 10)+"/feedback", nil)
+		req.Header.Set("Authorization", otherToken)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusUnauthorized, w.Code)
+	})
+
+	t.Run("missing event", func(t *testing.T) {
+		assert.Equal(t, http.StatusNotFound, getAs(router, "/events/999999/feedback", organizerToken).Code)
+	})
+
+	t.Run("across all events", func(t *testing.T) {
+		req, _ := http.NewRequest(http.MethodGet, "/me/events/feedback", nil)
+		req.Header.Set("Authorization", organizerToken)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusOK, w.Code)
+
+		var response struct {
+			Summary struct {
+				Count   int
+				Average float64
+			} `json:"summary"`
+			Events []struct {
+				EventID int64
+				Count   int
+			} `json:"events"`
+		}
+		json.Unmarshal(w.Body.Bytes(), &response)
+		assert.Equal(t, 4, response.Summary.Count)
+		assert.InDelta(t, 15.0/4.0, response.Summary.Average, 0.001)
+		assert.Len(t, response.Events, 3)
+		assert.Equal(t, 1, response.Events[1].Count)
+		assert.Equal(t, 0, response.Events[2].Count)
+	})
+}
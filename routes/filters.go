@@ -0,0 +1,126 @@
+package routes
+
+import (
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/salads-source/go_http_server/models"
+)
+
+// parseEventFilter reads the event filters shared by listings and exports:
+// from and to (RFC 3339), user_id and location.
+func parseEventFilter(context *gin.Context) (models.EventFilter, error) {
+	var filter models.EventFilter
+	var err error
+
+	filter.From, err = parseTimeQuery(context, "from")
+
+	if err != nil {
+		return filter, err
+	}
+
+	filter.To, err = parseTimeQuery(context, "to")
+
+	if err != nil {
+		return filter, err
+	}
+
+	filter.UserID, err = parseIdQuery(context, "user_id")
+
+	if err != nil {
+		return filter, err
+	}
+
+	filter.Location = context.Query("location")
+	return filter, nil
+}
+
+// parseRegistrationFilter reads the registration filters event_id, user_id
+// and status.
+func parseRegistrationFilter(context *gin.Context) (models.RegistrationFilter, error) {
+	var filter models.RegistrationFilter
+	var err error
+
+	filter.EventID, err = parseIdQuery(context, "event_id")
+
+	if err != nil {
+		return filter, err
+	}
+
+	filter.UserID, err = parseIdQuery(context, "user_id")
+
+	if err != nil {
+		return filter, err
+	}
+
+	filter.Status = context.Query("status")
+
+	if filter.Status != "" && !models.IsRegistrationStatus(filter.Status) {
+		return filter, fmt.Errorf("Invalid status")
+	}
+
+	return filter, nil
+}
+
+func parseTimeQuery(context *gin.Context, name string) (time.Time, error) {
+	value := context.Query(name)
+
+	if value == "" {
+		return time.Time{}, nil
+	}
+
+	parsed, err := time.Parse(time.RFC3339, value)
+
+	if err != nil {
+		return time.Time{}, fmt.Errorf("Invalid %s, expected an RFC 3339 timestamp", name)
+	}
+
+	return parsed, nil
+}
+
+func parseIdQuery(context *gin.Context, name string) (int64, error) {
+	value := context.Query(name)
+
+	if value == "" {
+		return 0, nil
+	}
+
+	id, err := strconv.ParseInt(value, 10, 64)
+
+	if err != nil {
+		return 0, fmt.Errorf("Invalid %s", name)
+	}
+
+	return id, nil
+}
+
+const (
+	defaultPerPage = 20
+	maxPerPage     = 100
+)
+
+// parsePagination reads page (from 1) and per_page, returning the limit and
+// offset to query with.
+func parsePagination(context *gin.Context) (page, perPage int, err error) {
+	page, perPage = 1, defaultPerPage
+
+	if value := context.Query("page"); value != "" {
+		page, err = strconv.Atoi(value)
+
+		if err != nil || page < 1 {
+			return 0, 0, fmt.Errorf("Invalid page")
+		}
+	}
+
+	if value := context.Query("per_page"); value != "" {
+		perPage, err = strconv.Atoi(value)
+
+		if err != nil || perPage < 1 || perPage > maxPerPage {
+			return 0, 0, fmt.Errorf("Invalid per_page, expected 1 to %d", maxPerPage)
+		}
+	}
+
+	return page, perPage, nil
+}
@@ -0,0 +1,117 @@
+package routes
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/salads-source/go_http_server/models"
+)
+
+// loadOwnRegistration fetches the caller's registration for the event in the
+// request and checks it can still be changed. It writes the error response
+// and returns false on failure.
+func loadOwnRegistration(context *gin.Context) (*models.Event, *models.Registration, bool) {
+	eventId, err := strconv.ParseInt(context.Param("id"), 10, 64)
+
+	if err != nil {
+		context.JSON(http.StatusBadRequest, gin.H{"message": "Invalid event Id"})
+		return nil, nil, false
+	}
+
+	event, ok := loadVisibleEvent(context, eventId)
+
+	if !ok {
+		return nil, nil, false
+	}
+
+	registration, err := models.GetRegistration(eventId, context.GetInt64("userId"))
+
+	if errors.Is(err, models.ErrNotRegistered) {
+		context.JSON(http.StatusNotFound, gin.H{"message": "You are not registered for this event."})
+		return nil, nil, false
+	}
+
+	if err != nil {
+		context.JSON(http.StatusInternalServerError, gin.H{"message": "Could not fetch registration, try again later"})
+		return nil, nil, false
+	}
+
+	if event.RegistrationsLocked(time.Now()) {
+		context.JSON(http.StatusForbidden, gin.H{"message": "Your registration can no longer be changed."})
+		return nil, nil, false
+	}
+
+	return event, registration, true
+}
+
+func addGuest(context *gin.Context) {
+	event, registration, ok := loadOwnRegistration(context)
+
+	if !ok {
+		return
+	}
+
+	if registration.Status == models.RegistrationStatusRejected {
+		context.JSON(http.StatusConflict, gin.H{"message": "Your registration was rejected."})
+		return
+	}
+
+	var guest models.Guest
+	err := context.ShouldBindJSON(&guest)
+
+	if err != nil {
+		context.JSON(http.StatusBadRequest, gin.H{"message": "Could not parse request data."})
+		return
+	}
+
+	err = event.AddGuest(*registration, &guest)
+
+	if errors.Is(err, models.ErrTooManyGuests) {
+		context.JSON(http.StatusBadRequest, gin.H{"message": "You can bring at most " + strconv.Itoa(event.MaxGuests) + " guests."})
+		return
+	}
+
+	if errors.Is(err, models.ErrEventFull) {
+		context.JSON(http.StatusConflict, gin.H{"message": "Event is full."})
+		return
+	}
+
+	if err != nil {
+		context.JSON(http.StatusInternalServerError, gin.H{"message": "Could not add guest, try again later"})
+		return
+	}
+
+	context.JSON(http.StatusCreated, gin.H{"message": "Guest added", "guest": guest})
+}
+
+func removeGuest(context *gin.Context) {
+	_, registration, ok := loadOwnRegistration(context)
+
+	if !ok {
+		return
+	}
+
+	guestId, err := strconv.ParseInt(context.Param("guestId"), 10, 64)
+
+	if err != nil {
+		context.JSON(http.StatusBadRequest, gin.H{"message": "Invalid guest Id"})
+		return
+	}
+
+	err = registration.RemoveGuest(guestId)
+
+	if errors.Is(err, models.ErrGuestNotFound) {
+		context.JSON(http.StatusNotFound, gin.H{"message": "Guest not found."})
+		return
+	}
+
+	if err != nil {
+		context.JSON(http.StatusInternalServerError, gin.H{"message": "Could not remove guest, try again later"})
+		return
+	}
+
+	context.JSON(http.StatusOK, gin.H{"message": "Guest removed"})
+}
@@ -0,0 +1,112 @@
+package routes
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/salads-source/go_http_server/db"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRegistrationGuests(t *testing.T) {
+	_, router := setupTestRouter(t)
+	organizerId := createTestUser(t, db.DB, "guestorganizer@example.com", "password123")
+	registrantId := createTestUser(t, db.DB, "guestregistrant@example.com", "password123")
+	otherId := createTestUser(t, db.DB, "guestother@example.com", "password123")
+	organizerToken := generateTestToken(t, "guestorganizer@example.com", organizerId)
+	registrantToken := generateTestToken(t, "guestregistrant@example.com", registrantId)
+	otherToken := generateTestToken(t, "guestother@example.com", otherId)
+
+	eventId := createTestEvent(t, organizerId)
+	db.DB.Exec("UPDATE events SET dateTime = ?, capacity = 4, max_guests = 2, cancellation_cutoff_minutes = 60 WHERE id = ?",
+		time.Now().Add(24*time.Hour), eventId)
+	eventPath := "/events/" + strconv.FormatInt(eventId, 10)
+
+	t.Run("guest limit per registrant", func(t *testing.T) {
+		guests := []map[string]interface{}{{"name": "Ann"}, {"name": "Bob"}, {"name": "Cat"}}
+		w := postJSON(t, router, http.MethodPost, eventPath+"/register", registrantToken, map[string]interface{}{"guests": guests})
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+
+		w = postJSON(t, router, http.MethodPost, eventPath+"/register", registrantToken, map[string]interface{}{"guests": []map[string]interface{}{{"name": ""}}})
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+	})
+
+	t.Run("register with guests", func(t *testing.T) {
+		guests := []map[string]interface{}{{"name": "Ann", "email": "ann@example.com"}, {"name": "Bob"}}
+		w := postJSON(t, router, http.MethodPost, eventPath+"/register", registrantToken, map[string]interface{}{"guests": guests})
+		assert.Equal(t, http.StatusCreated, w.Code)
+	})
+
+	t.Run("guests count against capacity", func(t *testing.T) {
+		w := postJSON(t, router, http.MethodPost, eventPath+"/register", otherToken, map[string]interface{}{"guests": []map[string]interface{}{{"name": "Dan"}}})
+		assert.Equal(t, http.StatusConflict, w.Code)
+
+		w = postJSON(t, router, http.MethodPost, eventPath+"/register", otherToken, nil)
+		assert.Equal(t, http.StatusCreated, w.Code)
+	})
+
+	var guestIds []int64
+
+	t.Run("attendee list shows guests under the registrant", func(t *testing.T) {
+		w := getAs(router, eventPath+"/registrations", organizerToken)
+		var response struct {
+			Registrations []struct {
+				UserID int64
+				Guests []struct {
+					ID   int64
+					Name string
+				}
+			} `json:"registrations"`
+		}
+		json.Unmarshal(w.Body.Bytes(), &response)
+		assert.Len(t, response.Registrations, 2)
+		assert.Equal(t, registrantId, response.Registrations[0].UserID)
+		assert.Len(t, response.Registrations[0].Guests, 2)
+		assert.Equal(t, "Ann", response.Registrations[0].Guests[0].Name)
+		assert.Empty(t, response.Registrations[1].Guests)
+
+		for _, guest := range response.Registrations[0].Guests {
+			guestIds = append(guestIds, guest.ID)
+		}
+	})
+
+	t.Run("registrant removes and adds guests", func(t *testing.T) {
+		guestPath := eventPath + "/register/guests/" + strconv.FormatInt(guestIds[0], 10)
+
+		w := postJSON(t, router, http.MethodDelete, guestPath, otherToken, nil)
+		assert.Equal(t, http.StatusNotFound, w.Code)
+
+		w = postJSON(t, router, http.MethodDelete, guestPath, registrantToken, nil)
+		assert.Equal(t, http.StatusOK, w.Code)
+
+		w = postJSON(t, router, http.MethodPost, eventPath+"/register/guests", registrantToken, map[string]interface{}{"name": "Eve"})
+		assert.Equal(t, http.StatusCreated, w.Code)
+
+		w = postJSON(t, router, http.MethodPost, eventPath+"/register/guests", registrantToken, map[string]interface{}{"name": "Fay"})
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+	})
+
+	t.Run("changes close at the cutoff", func(t *testing.T) {
+		db.DB.Exec("UPDATE events SET dateTime = ? WHERE id = ?", time.Now().Add(30*time.Minute), eventId)
+
+		w := postJSON(t, router, http.MethodDelete, eventPath+"/register/guests/"+strconv.FormatInt(guestIds[1], 10), registrantToken, nil)
+		assert.Equal(t, http.StatusForbidden, w.Code)
+
+		w = postJSON(t, router, http.MethodDelete, eventPath+"/register", registrantToken, nil)
+		assert.Equal(t, http.StatusForbidden, w.Code)
+	})
+
+	t.Run("cancelling removes guests", func(t *testing.T) {
+		db.DB.Exec("UPDATE events SET dateTime = ? WHERE id = ?", time.Now().Add(24*time.Hour), eventId)
+
+		w := postJSON(t, router, http.MethodDelete, eventPath+"/register", registrantToken, nil)
+		assert.Equal(t, http.StatusOK, w.Code)
+
+		var guests int
+		db.DB.QueryRow("SELECT COUNT(*) FROM registration_guests").Scan(&guests)
+		assert.Equal(t, 0, guests)
+	})
+}
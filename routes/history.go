@@ -0,0 +1,179 @@
+package routes
+
+import (
+	"database/sql"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/salads-source/go_http_server/models"
+)
+
+// checkHistoryAccess reports whether the caller may read an event's history.
+// Anyone who can see a live event can see its history; once the event is
+// deleted only its last organizer or an admin can. It writes the error
+// response and returns false on failure.
+func checkHistoryAccess(context *gin.Context) (int64, bool) {
+	eventId, err := strconv.ParseInt(context.Param("id"), 10, 64)
+
+	if err != nil {
+		context.JSON(http.StatusBadRequest, gin.H{"message": "Invalid event Id"})
+		return 0, false
+	}
+
+	_, err = models.GetEventByID(eventId)
+
+	if err == nil {
+		_, ok := loadVisibleEvent(context, eventId)
+		return eventId, ok
+	}
+
+	if !errors.Is(err, sql.ErrNoRows) {
+		context.JSON(http.StatusInternalServerError, gin.H{"message": "Could not fetch event history."})
+		return 0, false
+	}
+
+	latest, err := models.GetLatestEventVersion(eventId)
+
+	if errors.Is(err, sql.ErrNoRows) || (err == nil && !canManageEvent(context, &models.Event{UserID: latest.Snapshot.UserID})) {
+		context.JSON(http.StatusNotFound, gin.H{"message": "Event not found."})
+		return 0, false
+	}
+
+	if err != nil {
+		context.JSON(http.StatusInternalServerError, gin.H{"message": "Could not fetch event history."})
+		return 0, false
+	}
+
+	return eventId, true
+}
+
+// loadEventVersion fetches the version named by the given value, writing the
+// error response and returning false when it is invalid or unknown.
+func loadEventVersion(context *gin.Context, eventId int64, value string) (*models.EventVersion, bool) {
+	number, err := strconv.Atoi(value)
+
+	if err != nil || number < 1 {
+		context.JSON(http.StatusBadRequest, gin.H{"message": "Invalid version."})
+		return nil, false
+	}
+
+	version, err := models.GetEventVersion(eventId, number)
+
+	if errors.Is(err, sql.ErrNoRows) {
+		context.JSON(http.StatusNotFound, gin.H{"message": "Version not found."})
+		return nil, false
+	}
+
+	if err != nil {
+		context.JSON(http.StatusInternalServerError, gin.H{"message": "Could not fetch event history."})
+		return nil, false
+	}
+
+	return version, true
+}
+
+func getEventHistory(context *gin.Context) {
+	eventId, ok := checkHistoryAccess(context)
+
+	if !ok {
+		return
+	}
+
+	versions, err := models.GetEventHistory(eventId)
+
+	if err != nil {
+		context.JSON(http.StatusInternalServerError, gin.H{"message": "Could not fetch event history."})
+		return
+	}
+
+	pointers := make([]*models.EventVersion, len(versions))
+
+	for i := range versions {
+		pointers[i] = &versions[i]
+	}
+
+	err = models.AttachSnapshotOrganizers(pointers...)
+
+	if err != nil {
+		context.JSON(http.StatusInternalServerError, gin.H{"message": "Could not fetch event history."})
+		return
+	}
+
+	context.JSON(http.StatusOK, versions)
+}
+
+func getEventVersion(context *gin.Context) {
+	eventId, ok := checkHistoryAccess(context)
+
+	if !ok {
+		return
+	}
+
+	version, ok := loadEventVersion(context, eventId, context.Param("version"))
+
+	if !ok {
+		return
+	}
+
+	err := models.AttachSnapshotOrganizers(version)
+
+	if err != nil {
+		context.JSON(http.StatusInternalServerError, gin.H{"message": "Could not fetch event history."})
+		return
+	}
+
+	context.JSON(http.StatusOK, version)
+}
+
+// diffEventVersions compares the versions given by the from and to query
+// parameters. to defaults to the latest version.
+func diffEventVersions(context *gin.Context) {
+	eventId, ok := checkHistoryAccess(context)
+
+	if !ok {
+		return
+	}
+
+	from, ok := loadEventVersion(context, eventId, context.Query("from"))
+
+	if !ok {
+		return
+	}
+
+	var to *models.EventVersion
+
+	if value := context.Query("to"); value != "" {
+		to, ok = loadEventVersion(context, eventId, value)
+
+		if !ok {
+			return
+		}
+	} else {
+		latest, err := models.GetLatestEventVersion(eventId)
+
+		if err != nil {
+			context.JSON(http.StatusInternalServerError, gin.H{"message": "Could not fetch event history."})
+			return
+		}
+
+		to = latest
+	}
+
+	err := models.AttachSnapshotOrganizers(from, to)
+
+	if err != nil {
+		context.JSON(http.StatusInternalServerError, gin.H{"message": "Could not fetch event history."})
+		return
+	}
+
+	changes, err := models.DiffEventVersions(*from, *to)
+
+	if err != nil {
+		context.JSON(http.StatusInternalServerError, gin.H{"message": "Could not compare versions."})
+		return
+	}
+
+	context.JSON(http.StatusOK, gin.H{"from": from.Version, "to": to.Version, "changes": changes})
+}
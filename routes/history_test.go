@@ -0,0 +1,141 @@
+package routes
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/salads-source/go_http_server/db"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEventHistory(t *testing.T) {
+	_, router := setupTestRouter(t)
+	organizerId := createTestUser(t, db.DB, "historyorganizer@example.com", "password123")
+	strangerId := createTestUser(t, db.DB, "historystranger@example.com", "password123")
+	organizerToken := generateTestToken(t, "historyorganizer@example.com", organizerId)
+	strangerToken := generateTestToken(t, "historystranger@example.com", strangerId)
+
+	dateTime := time.Now().Add(24 * time.Hour).UTC().Truncate(time.Second)
+	w := postJSON(t, router, http.MethodPost, "/events", organizerToken, map[string]interface{}{
+		"name":        "Go Meetup",
+		"description": "Monthly meetup",
+		"location":    "Library",
+		"dateTime":    dateTime.Format(time.RFC3339),
+	})
+	assert.Equal(t, http.StatusCreated, w.Code)
+
+	var created struct {
+		Event struct{ ID int64 } `json:"event"`
+	}
+	json.Unmarshal(w.Body.Bytes(), &created)
+	path := "/events/" + strconv.FormatInt(created.Event.ID, 10)
+
+	w = postJSON(t, router, http.MethodPut, path, organizerToken, map[string]interface{}{
+		"name":        "Go Meetup",
+		"description": "Monthly meetup",
+		"location":    "Town Hall",
+		"dateTime":    dateTime.Add(time.Hour).Format(time.RFC3339),
+	})
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	t.Run("lists every version", func(t *testing.T) {
+		w := getAs(router, path+"/history", "")
+		assert.Equal(t, http.StatusOK, w.Code)
+
+		var versions []struct {
+			Version  int
+			Action   string
+			ActorID  int64
+			Snapshot struct{ Location string }
+		}
+		json.Unmarshal(w.Body.Bytes(), &versions)
+		assert.Len(t, versions, 2)
+		assert.Equal(t, "create", versions[0].Action)
+		assert.Equal(t, "Library", versions[0].Snapshot.Location)
+		assert.Equal(t, "update", versions[1].Action)
+		assert.Equal(t, organizerId, versions[1].ActorID)
+	})
+
+	t.Run("snapshots show the organizer profile, not the user id", func(t *testing.T) {
+		w := getAs(router, path+"/history/1", "")
+		assert.Equal(t, http.StatusOK, w.Code)
+
+		var version struct {
+			Snapshot map[string]json.RawMessage
+		}
+		json.Unmarshal(w.Body.Bytes(), &version)
+		assert.NotContains(t, version.Snapshot, "UserID")
+
+		var organizer struct{ ID int64 }
+		json.Unmarshal(version.Snapshot["Organizer"], &organizer)
+		assert.Equal(t, organizerId, organizer.ID)
+	})
+
+	tests := []struct {
+		name           string
+		path           string
+		expectedStatus int
+	}{
+		{"single version", path + "/history/1", http.StatusOK},
+		{"unknown version", path + "/history/9", http.StatusNotFound},
+		{"invalid version", path + "/history/first", http.StatusBadRequest},
+		{"diff needs from", path + "/history/diff", http.StatusBadRequest},
+		{"unknown event", "/events/999/history", http.StatusNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.expectedStatus, getAs(router, tt.path, "").Code)
+		})
+	}
+
+	t.Run("diff lists changed fields", func(t *testing.T) {
+		w := getAs(router, path+"/history/diff?from=1&to=2", "")
+		assert.Equal(t, http.StatusOK, w.Code)
+
+		var response struct {
+			Changes []struct {
+				Field string
+				From  interface{}
+				To    interface{}
+			} `json:"changes"`
+		}
+		json.Unmarshal(w.Body.Bytes(), &response)
+		assert.Len(t, response.Changes, 2)
+		assert.Equal(t, "Location", response.Changes[0].Field)
+		assert.Equal(t, "Library", response.Changes[0].From)
+		assert.Equal(t, "Town Hall", response.Changes[0].To)
+		assert.Equal(t, "DateTime", response.Changes[1].Field)
+	})
+
+	t.Run("history of private events is hidden", func(t *testing.T) {
+		db.DB.Exec("UPDATE events SET visibility = 'private' WHERE id = ?", created.Event.ID)
+		assert.Equal(t, http.StatusNotFound, getAs(router, path+"/history", strangerToken).Code)
+		assert.Equal(t, http.StatusOK, getAs(router, path+"/history", organizerToken).Code)
+	})
+
+	t.Run("deleted events keep their history for the organizer", func(t *testing.T) {
+		w := postJSON(t, router, http.MethodDelete, path, organizerToken, nil)
+		assert.Equal(t, http.StatusOK, w.Code)
+
+		assert.Equal(t, http.StatusNotFound, getAs(router, path+"/history", strangerToken).Code)
+
+		adminId := createTestUser(t, db.DB, "historyadmin@example.com", "password123")
+		db.DB.Exec("UPDATE users SET role = 'admin' WHERE id = ?", adminId)
+		assert.Equal(t, http.StatusOK, getAs(router, path+"/history", generateTestToken(t, "historyadmin@example.com", adminId)).Code)
+
+		w = getAs(router, path+"/history/3", organizerToken)
+		assert.Equal(t, http.StatusOK, w.Code)
+
+		var version struct {
+			Action   string
+			Snapshot struct{ Location string }
+		}
+		json.Unmarshal(w.Body.Bytes(), &version)
+		assert.Equal(t, "delete", version.Action)
+		assert.Equal(t, "Town Hall", version.Snapshot.Location)
+	})
+}
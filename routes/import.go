@@ -0,0 +1,64 @@
+package routes
+
+import (
+	"mime"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/salads-source/go_http_server/models"
+)
+
+const maxImportSize = 10 << 20 // 10 MB
+
+func importEvents(context *gin.Context) {
+	formatValue := context.Query("format")
+
+	if formatValue == "" {
+		formatValue, _, _ = mime.ParseMediaType(context.GetHeader("Content-Type"))
+	}
+
+	format, err := models.ParseImportFormat(formatValue)
+
+	if err != nil {
+		context.JSON(http.StatusBadRequest, gin.H{"message": "Unsupported import format, use csv or ndjson."})
+		return
+	}
+
+	mode, err := models.ParseImportMode(context.Query("mode"))
+
+	if err != nil {
+		context.JSON(http.StatusBadRequest, gin.H{"message": "Unsupported import mode, use atomic or best_effort."})
+		return
+	}
+
+	dryRun := false
+
+	if value := context.Query("dry_run"); value != "" {
+		dryRun, err = strconv.ParseBool(value)
+
+		if err != nil {
+			context.JSON(http.StatusBadRequest, gin.H{"message": "Invalid dry_run value."})
+			return
+		}
+	}
+
+	userId := context.GetInt64("userId")
+	body := http.MaxBytesReader(context.Writer, context.Request.Body, maxImportSize)
+
+	result, err := models.ImportEvents(body, format, mode, dryRun, userId)
+
+	if err != nil {
+		context.JSON(http.StatusBadRequest, gin.H{"message": "Could not read import data: " + err.Error()})
+		return
+	}
+
+	switch {
+	case dryRun:
+		context.JSON(http.StatusOK, gin.H{"message": "Dry run completed, nothing was imported.", "result": result})
+	case mode == models.ImportModeAtomic && result.Imported == 0 && len(result.Errors) > 0:
+		context.JSON(http.StatusUnprocessableEntity, gin.H{"message": "Import failed, no events were imported.", "result": result})
+	default:
+		context.JSON(http.StatusCreated, gin.H{"message": "Events imported!", "result": result})
+	}
+}
@@ -0,0 +1,148 @@
+package routes
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/salads-source/go_http_server/db"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestImportEvents(t *testing.T) {
+	_, router := setupTestRouter(t)
+	userId := createTestUser(t, db.DB, "importer@example.com", "password123")
+	token := generateTestToken(t, "importer@example.com", userId)
+
+	validCSV := "name,description,location,dateTime\n" +
+		"Meetup,Monthly meetup,Room 1,2025-01-01T18:00:00Z\n" +
+		"Workshop,Hands-on session,Room 2,2025-01-08T18:00:00Z\n"
+
+	mixedCSV := "name,description,location,dateTime\n" +
+		"Meetup,Monthly meetup,Room 1,2025-01-01T18:00:00Z\n" +
+		",Missing name,Room 2,2025-01-08T18:00:00Z\n" +
+		"Bad date,Broken,Room 3,next tuesday\n"
+
+	validNDJSON := `{"name":"Meetup","description":"Monthly meetup","location":"Room 1","dateTime":"2025-01-01T18:00:00Z"}` + "\n\n" +
+		`{"name":"Workshop","description":"Hands-on session","location":"Room 2","dateTime":"2025-01-08T18:00:00Z"}` + "\n"
+
+	tests := []struct {
+		name           string
+		query          string
+		contentType    string
+		body           string
+		authToken      string
+		expectedStatus int
+		expectedTotal  int
+		expectedErrors int
+		expectedSaved  int
+	}{
+		{
+			name:           "csv atomic import",
+			query:          "?format=csv",
+			body:           validCSV,
+			authToken:      token,
+			expectedStatus: http.StatusCreated,
+			expectedTotal:  2,
+			expectedSaved:  2,
+		},
+		{
+			name:           "ndjson detected from content type",
+			contentType:    "application/x-ndjson",
+			body:           validNDJSON,
+			authToken:      token,
+			expectedStatus: http.StatusCreated,
+			expectedTotal:  2,
+			expectedSaved:  2,
+		},
+		{
+			name:           "dry run reports row errors without saving",
+			query:          "?format=csv&dry_run=true",
+			body:           mixedCSV,
+			authToken:      token,
+			expectedStatus: http.StatusOK,
+			expectedTotal:  3,
+			expectedErrors: 2,
+		},
+		{
+			name:           "atomic import with invalid rows saves nothing",
+			query:          "?format=csv&mode=atomic",
+			body:           mixedCSV,
+			authToken:      token,
+			expectedStatus: http.StatusUnprocessableEntity,
+			expectedTotal:  3,
+			expectedErrors: 2,
+		},
+		{
+			name:           "best effort import saves valid rows",
+			query:          "?format=csv&mode=best_effort",
+			body:           mixedCSV,
+			authToken:      token,
+			expectedStatus: http.StatusCreated,
+			expectedTotal:  3,
+			expectedErrors: 2,
+			expectedSaved:  1,
+		},
+		{
+			name:           "csv missing a column",
+			query:          "?format=csv",
+			body:           "name,description\nMeetup,Monthly meetup\n",
+			authToken:      token,
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name:           "unknown format",
+			query:          "?format=xml",
+			body:           validCSV,
+			authToken:      token,
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name:           "missing authorization",
+			query:          "?format=csv",
+			body:           validCSV,
+			expectedStatus: http.StatusUnauthorized,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var before int
+			db.DB.QueryRow("SELECT COUNT(*) FROM events").Scan(&before)
+
+			req, _ := http.NewRequest(http.MethodPost, "/events/import"+tt.query, strings.NewReader(tt.body))
+			if tt.contentType != "" {
+				req.Header.Set("Content-Type", tt.contentType)
+			}
+			if tt.authToken != "" {
+				req.Header.Set("Authorization", tt.authToken)
+			}
+
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			assert.Equal(t, tt.expectedStatus, w.Code)
+
+			var after int
+			db.DB.QueryRow("SELECT COUNT(*) FROM events").Scan(&after)
+			assert.Equal(t, tt.expectedSaved, after-before)
+
+			if tt.expectedTotal > 0 {
+				var response struct {
+					Result struct {
+						Total  int
+						Errors []struct {
+							Row     int
+							Message string
+						}
+					}
+				}
+				json.Unmarshal(w.Body.Bytes(), &response)
+				assert.Equal(t, tt.expectedTotal, response.Result.Total)
+				assert.Len(t, response.Result.Errors, tt.expectedErrors)
+			}
+		})
+	}
+}
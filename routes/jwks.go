@@ -0,0 +1,15 @@
+package routes
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/salads-source/go_http_server/utils"
+)
+
+// getJWKS publishes the public keys access tokens are signed with, in the
+// standard JWK Set format rather than the usual message envelope.
+func getJWKS(context *gin.Context) {
+	context.Header("Cache-Control", "public, max-age=300")
+	context.JSON(http.StatusOK, gin.H{"keys": utils.Keys.JWKS()})
+}
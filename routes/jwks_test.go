@@ -0,0 +1,154 @@
+package routes
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/salads-source/go_http_server/db"
+	"github.com/salads-source/go_http_server/utils"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTokenSigningKeys(t *testing.T) {
+	_, router := setupTestRouter(t)
+	userId := createTestUser(t, db.DB, "keys@example.com", "password123")
+
+	oldKey, _ := utils.NewEd25519Key("2026-01")
+	newKey, _ := utils.NewEd25519Key("2026-02")
+
+	useKeys := func(signing *utils.SigningKey, others ...*utils.SigningKey) {
+		keys, err := utils.NewKeySet(signing, others...)
+		if err != nil {
+			t.Fatalf("Failed to create key set: %v", err)
+		}
+		utils.Keys = keys
+	}
+
+	signed := func(key *utils.SigningKey, method jwt.SigningMethod, change func(jwt.MapClaims)) string {
+		now := time.Now()
+		claims := jwt.MapClaims{
+			"jti":    "custom-token",
+			"iss":    utils.TokenIssuer,
+			"aud":    utils.TokenAudience,
+			"userId": userId,
+			"iat":    now.Unix(),
+			"nbf":    now.Unix(),
+			"exp":    now.Add(time.Minute).Unix(),
+		}
+		change(claims)
+
+		token := jwt.NewWithClaims(method, claims)
+		token.Header["kid"] = key.ID
+		var signingKey interface{} = key.Private
+		if method == jwt.SigningMethodHS256 {
+			signingKey = []byte("supersecret")
+		}
+		signedToken, err := token.SignedString(signingKey)
+		if err != nil {
+			t.Fatalf("Failed to sign token: %v", err)
+		}
+		return signedToken
+	}
+
+	t.Run("the JWKS lists every key", func(t *testing.T) {
+		useKeys(newKey, oldKey)
+
+		w := getAs(router, "/.well-known/jwks.json", "")
+		assert.Equal(t, http.StatusOK, w.Code)
+
+		var response struct {
+			Keys []struct {
+				Kty string
+				Kid string
+				Alg string
+				Crv string
+				X   string
+			} `json:"keys"`
+		}
+		json.Unmarshal(w.Body.Bytes(), &response)
+		assert.Len(t, response.Keys, 2)
+		assert.Equal(t, "2026-01", response.Keys[0].Kid)
+		assert.Equal(t, "OKP", response.Keys[0].Kty)
+		assert.Equal(t, "EdDSA", response.Keys[0].Alg)
+		assert.Equal(t, "Ed25519", response.Keys[0].Crv)
+		assert.NotEmpty(t, response.Keys[0].X)
+	})
+
+	t.Run("tokens signed with a rotated key stay valid while it is listed", func(t *testing.T) {
+		useKeys(oldKey)
+		token := generateTestToken(t, "keys@example.com", userId)
+
+		useKeys(newKey, oldKey)
+		assert.Equal(t, http.StatusOK, getAs(router, "/me/sessions", token).Code)
+
+		useKeys(newKey)
+		assert.Equal(t, http.StatusUnauthorized, getAs(router, "/me/sessions", token).Code)
+	})
+
+	t.Run("tokens must match the issuer, audience and validity window", func(t *testing.T) {
+		useKeys(newKey)
+
+		valid := signed(newKey, jwt.SigningMethodEdDSA, func(jwt.MapClaims) {})
+		assert.Equal(t, http.StatusOK, getAs(router, "/me/sessions", valid).Code)
+
+		for name, change := range map[string]func(jwt.MapClaims){
+			"issuer":     func(c jwt.MapClaims) { c["iss"] = "someone-else" },
+			"audience":   func(c jwt.MapClaims) { c["aud"] = "another-service" },
+			"not before": func(c jwt.MapClaims) { c["nbf"] = time.Now().Add(time.Hour).Unix() },
+			"no nbf":     func(c jwt.MapClaims) { delete(c, "nbf") },
+			"expired":    func(c jwt.MapClaims) { c["exp"] = time.Now().Add(-time.Hour).Unix() },
+		} {
+			token := signed(newKey, jwt.SigningMethodEdDSA, change)
+			assert.Equal(t, http.StatusUnauthorized, getAs(router, "/me/sessions", token).Code, name)
+		}
+	})
+
+	t.Run("the old shared secret is no longer accepted", func(t *testing.T) {
+		useKeys(newKey)
+
+		token := signed(newKey, jwt.SigningMethodHS256, func(jwt.MapClaims) {})
+		assert.Equal(t, http.StatusUnauthorized, getAs(router, "/me/sessions", token).Code)
+	})
+
+	t.Run("keys are loaded from PEM files", func(t *testing.T) {
+		dir := t.TempDir()
+
+		rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+		if err != nil {
+			t.Fatalf("Failed to generate RSA key: %v", err)
+		}
+		rsaPEM := pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(rsaKey)})
+		os.WriteFile(filepath.Join(dir, "2026-03.pem"), rsaPEM, 0o600)
+
+		public, _ := x509.MarshalPKIXPublicKey(oldKey.Public)
+		os.WriteFile(filepath.Join(dir, "2026-01.pem"), pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: public}), 0o600)
+
+		oldToken := func() string {
+			useKeys(oldKey)
+			return generateTestToken(t, "keys@example.com", userId)
+		}()
+
+		keys, err := utils.LoadKeySet(dir, "")
+		assert.NoError(t, err)
+		utils.Keys = keys
+
+		token := generateTestToken(t, "keys@example.com", userId)
+		parsed, _, _ := jwt.NewParser().ParseUnverified(token, jwt.MapClaims{})
+		assert.Equal(t, "RS256", parsed.Method.Alg())
+		assert.Equal(t, "2026-03", parsed.Header["kid"])
+		assert.Equal(t, http.StatusOK, getAs(router, "/me/sessions", token).Code)
+		assert.Equal(t, http.StatusOK, getAs(router, "/me/sessions", oldToken).Code)
+
+		_, err = utils.LoadKeySet(dir, "2026-01")
+		assert.Error(t, err)
+	})
+}
@@ -0,0 +1,96 @@
+package routes
+
+import (
+	"errors"
+	"log"
+	"math"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/salads-source/go_http_server/mail"
+	"github.com/salads-source/go_http_server/models"
+)
+
+type unlockAccountInput struct {
+	Token string `binding:"required"`
+}
+
+// rejectThrottledLogin answers a login attempt made before its wait is
+// over. The password is not checked, so guessing during the wait gains
+// nothing. The answer is the same whether or not the account exists.
+func rejectThrottledLogin(context *gin.Context, throttle models.LoginThrottle) {
+	message := "Too many failed login attempts, try again later."
+
+	if throttle.Locked {
+		message = "Too many failed login attempts, the account is locked. If it is yours, check your email for a way to unlock it."
+	}
+
+	retryAfter := int(math.Ceil(throttle.RetryAfter.Seconds()))
+	context.Header("Retry-After", strconv.Itoa(retryAfter))
+	context.JSON(http.StatusTooManyRequests, gin.H{"message": message, "retryAfter": retryAfter})
+}
+
+// recordFailedLogin settles attempt as a failure. If it locks the account,
+// the owner is emailed a token to unlock it in the background.
+func recordFailedLogin(attempt *models.LoginAttempt) error {
+	token, err := models.FinishLoginAttempt(attempt, models.LoginOutcomeFailure)
+
+	if err != nil || token == "" {
+		return err
+	}
+
+	mailer := mail.Default
+	userId := *attempt.UserID
+
+	inBackground(func() {
+		err := mailer.Send(mail.Message{
+			To:      attempt.Email,
+			Subject: "Your account has been locked",
+			Body: "There were too many failed attempts to log in to your account, so it is locked for the next " +
+				strconv.Itoa(int(models.AccountLockoutDuration.Minutes())) + " minutes. If it was you, use this token to unlock it now:\n\n" +
+				token + "\n\nIf it was not you, consider changing your password.",
+		})
+
+		if err != nil {
+			log.Printf("could not send unlock email to user %d: %v", userId, err)
+		}
+	})
+
+	return nil
+}
+
+func unlockAccount(context *gin.Context) {
+	var input unlockAccountInput
+	err := context.ShouldBindJSON(&input)
+
+	if err != nil {
+		context.JSON(http.StatusBadRequest, gin.H{"message": "Could not parse request"})
+		return
+	}
+
+	err = models.UnlockAccount(input.Token)
+
+	if errors.Is(err, models.ErrInvalidUnlockToken) {
+		context.JSON(http.StatusBadRequest, gin.H{"message": "The unlock token is invalid or has expired."})
+		return
+	}
+
+	if err != nil {
+		context.JSON(http.StatusInternalServerError, gin.H{"message": "Could not unlock account, try again later"})
+		return
+	}
+
+	context.JSON(http.StatusOK, gin.H{"message": "Account unlocked, you can log in again."})
+}
+
+func getMyLogins(context *gin.Context) {
+	attempts, err := models.GetLoginHistory(context.GetInt64("userId"))
+
+	if err != nil {
+		context.JSON(http.StatusInternalServerError, gin.H{"message": "Could not fetch login history, try again later"})
+		return
+	}
+
+	context.JSON(http.StatusOK, gin.H{"message": "Login history fetched successfully", "logins": attempts})
+}
@@ -0,0 +1,168 @@
+package routes
+
+import (
+	"encoding/json"
+	"net/http"
+	"path/filepath"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/salads-source/go_http_server/db"
+	"github.com/salads-source/go_http_server/mail"
+	"github.com/salads-source/go_http_server/models"
+	"github.com/stretchr/testify/assert"
+)
+
+func loginAs(t *testing.T, router http.Handler, email, password string) int {
+	w := postJSON(t, router, http.MethodPost, "/login", "", map[string]interface{}{"email": email, "password": password})
+	return w.Code
+}
+
+// addLoginFailures records failed attempts as if they were made at the given
+// time from ipAddress.
+func addLoginFailures(t *testing.T, email, ipAddress string, count int, at time.Time) {
+	for i := 0; i < count; i++ {
+		_, err := db.DB.Exec("INSERT INTO login_attempts(email, ip_address, outcome, created_at) VALUES (?, ?, 'failure', ?)",
+			email, ipAddress, at.UTC())
+		if err != nil {
+			t.Fatalf("Failed to add login attempt: %v", err)
+		}
+	}
+}
+
+func TestLoginThrottling(t *testing.T) {
+	_, router := setupTestRouter(t)
+
+	t.Run("repeated failures slow down further attempts", func(t *testing.T) {
+		createTestUser(t, db.DB, "guessed@example.com", "password123")
+		addLoginFailures(t, "guessed@example.com", "198.51.100.7", 2, time.Now().Add(-time.Hour))
+
+		assert.Equal(t, http.StatusUnauthorized, loginAs(t, router, "guessed@example.com", "wrong"))
+
+		// Even the right password has to wait.
+		w := postJSON(t, router, http.MethodPost, "/login", "", map[string]interface{}{"email": "Guessed@example.com", "password": "password123"})
+		assert.Equal(t, http.StatusTooManyRequests, w.Code)
+		assert.NotEmpty(t, w.Header().Get("Retry-After"))
+	})
+
+	t.Run("unknown emails are throttled the same way", func(t *testing.T) {
+		createTestUser(t, db.DB, "known@example.com", "password123")
+		addLoginFailures(t, "known@example.com", "198.51.100.7", 3, time.Now())
+		addLoginFailures(t, "unknown@example.com", "198.51.100.7", 3, time.Now())
+
+		known := postJSON(t, router, http.MethodPost, "/login", "", map[string]interface{}{"email": "known@example.com", "password": "wrong"})
+		unknown := postJSON(t, router, http.MethodPost, "/login", "", map[string]interface{}{"email": "unknown@example.com", "password": "wrong"})
+		assert.Equal(t, http.StatusTooManyRequests, known.Code)
+		assert.Equal(t, known.Code, unknown.Code)
+		assert.Equal(t, known.Body.String(), unknown.Body.String())
+	})
+
+	t.Run("a successful login resets the count", func(t *testing.T) {
+		createTestUser(t, db.DB, "forgetful@example.com", "password123")
+		addLoginFailures(t, "forgetful@example.com", "198.51.100.7", 2, time.Now().Add(-time.Hour))
+
+		assert.Equal(t, http.StatusCreated, loginAs(t, router, "forgetful@example.com", "password123"))
+		assert.Equal(t, http.StatusUnauthorized, loginAs(t, router, "forgetful@example.com", "wrong"))
+		assert.Equal(t, http.StatusCreated, loginAs(t, router, "forgetful@example.com", "password123"))
+	})
+
+	t.Run("attempts still being checked count as failures", func(t *testing.T) {
+		createTestUser(t, db.DB, "parallel@example.com", "password123")
+		for i := 0; i < 3; i++ {
+			_, err := db.DB.Exec("INSERT INTO login_attempts(email, ip_address, outcome, created_at) VALUES (?, ?, 'pending', ?)",
+				"parallel@example.com", "198.51.100.8", time.Now().UTC())
+			if err != nil {
+				t.Fatalf("Failed to add login attempt: %v", err)
+			}
+		}
+
+		assert.Equal(t, http.StatusTooManyRequests, loginAs(t, router, "parallel@example.com", "password123"))
+	})
+
+	t.Run("many failures from one address throttle every account", func(t *testing.T) {
+		createTestUser(t, db.DB, "neighbour@example.com", "password123")
+		// Test requests have no remote address, so their client IP is empty.
+		addLoginFailures(t, "someone-else@example.com", "", 20, time.Now())
+
+		assert.Equal(t, http.StatusTooManyRequests, loginAs(t, router, "neighbour@example.com", "password123"))
+	})
+}
+
+func TestAccountLockout(t *testing.T) {
+	_, router := setupTestRouter(t)
+	createTestUser(t, db.DB, "locked@example.com", "password123")
+	mailPath := filepath.Join(t.TempDir(), "mail.txt")
+	mail.Default = mail.NewFileMailer(mailPath)
+
+	// Long enough ago that the backoff is over, so the next failure is the
+	// one that locks the account.
+	addLoginFailures(t, "locked@example.com", "198.51.100.7", 9, time.Now().Add(-2*time.Hour))
+	assert.Equal(t, http.StatusUnauthorized, loginAs(t, router, "locked@example.com", "wrong"))
+
+	w := postJSON(t, router, http.MethodPost, "/login", "", map[string]interface{}{"email": "locked@example.com", "password": "password123"})
+	assert.Equal(t, http.StatusTooManyRequests, w.Code)
+	assert.Contains(t, w.Body.String(), "locked")
+
+	tokenPattern := regexp.MustCompile(`(?m)^[0-9a-f]{64}\r?$`)
+	var token string
+	assert.Eventually(t, func() bool {
+		tokens := tokenPattern.FindAllString(readMail(mailPath), -1)
+		if len(tokens) == 0 {
+			return false
+		}
+		token = tokens[0][:64]
+		return true
+	}, 5*time.Second, 10*time.Millisecond)
+
+	w = postJSON(t, router, http.MethodPost, "/login/unlock", "", map[string]interface{}{"token": "not-a-token"})
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	w = postJSON(t, router, http.MethodPost, "/login/unlock", "", map[string]interface{}{"token": token})
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, http.StatusCreated, loginAs(t, router, "locked@example.com", "password123"))
+
+	w = postJSON(t, router, http.MethodPost, "/login/unlock", "", map[string]interface{}{"token": token})
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+}
+
+func TestLoginHistory(t *testing.T) {
+	_, router := setupTestRouter(t)
+	userId := createTestUser(t, db.DB, "history@example.com", "password123")
+	token := generateTestToken(t, "history@example.com", userId)
+
+	assert.Equal(t, http.StatusUnauthorized, loginAs(t, router, "History@example.com", "wrong"))
+	assert.Equal(t, http.StatusCreated, loginAs(t, router, "history@example.com", "password123"))
+	assert.Equal(t, http.StatusUnauthorized, loginAs(t, router, "stranger@example.com", "wrong"))
+
+	w := getAs(router, "/me/logins", token)
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	var response struct {
+		Logins []struct {
+			Email   string
+			Outcome string
+		} `json:"logins"`
+	}
+	json.Unmarshal(w.Body.Bytes(), &response)
+
+	if assert.Len(t, response.Logins, 2) {
+		assert.Equal(t, "success", response.Logins[0].Outcome)
+		assert.Equal(t, "failure", response.Logins[1].Outcome)
+		assert.Equal(t, "history@example.com", response.Logins[1].Email)
+	}
+}
+
+func TestPruneLoginAttempts(t *testing.T) {
+	setupTestRouter(t)
+	addLoginFailures(t, "old@example.com", "198.51.100.7", 2, time.Now().Add(-100*24*time.Hour))
+	addLoginFailures(t, "recent@example.com", "198.51.100.7", 1, time.Now())
+
+	pruned, err := models.PruneLoginAttempts()
+	assert.NoError(t, err)
+	assert.Equal(t, int64(2), pruned)
+
+	var remaining int
+	db.DB.QueryRow("SELECT COUNT(*) FROM login_attempts").Scan(&remaining)
+	assert.Equal(t, 1, remaining)
+}
@@ -0,0 +1,66 @@
+package routes
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/salads-source/go_http_server/db"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLogout(t *testing.T) {
+	_, router := setupTestRouter(t)
+	userId := createTestUser(t, db.DB, "logout@example.com", "password123")
+
+	t.Run("logged out tokens are rejected", func(t *testing.T) {
+		token := generateTestToken(t, "logout@example.com", userId)
+		other := generateTestToken(t, "logout@example.com", userId)
+
+		w := postJSON(t, router, http.MethodPost, "/logout", token, nil)
+		assert.Equal(t, http.StatusOK, w.Code)
+
+		assert.Equal(t, http.StatusUnauthorized, getAs(router, "/me/sessions", token).Code)
+		assert.Equal(t, http.StatusOK, getAs(router, "/me/sessions", other).Code)
+
+		w = postJSON(t, router, http.MethodPost, "/logout", token, nil)
+		assert.Equal(t, http.StatusUnauthorized, w.Code)
+	})
+
+	t.Run("logging out signs out the session", func(t *testing.T) {
+		w := postJSON(t, router, http.MethodPost, "/login", "", map[string]interface{}{"email": "logout@example.com", "password": "password123"})
+		assert.Equal(t, http.StatusCreated, w.Code)
+
+		var session sessionResponse
+		json.Unmarshal(w.Body.Bytes(), &session)
+
+		w = postJSON(t, router, http.MethodPost, "/logout", session.Token, nil)
+		assert.Equal(t, http.StatusOK, w.Code)
+
+		w = postJSON(t, router, http.MethodPost, "/refresh", "", map[string]interface{}{"refreshToken": session.RefreshToken})
+		assert.Equal(t, http.StatusUnauthorized, w.Code)
+	})
+
+	t.Run("changing the password revokes earlier tokens", func(t *testing.T) {
+		token := generateTestToken(t, "logout@example.com", userId)
+
+		w := postJSON(t, router, http.MethodPut, "/me/password", token, map[string]interface{}{"currentPassword": "wrong", "newPassword": "password456"})
+		assert.Equal(t, http.StatusUnauthorized, w.Code)
+		assert.Equal(t, http.StatusOK, getAs(router, "/me/sessions", token).Code)
+
+		w = postJSON(t, router, http.MethodPut, "/me/password", token, map[string]interface{}{"currentPassword": "password123", "newPassword": "password456"})
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, http.StatusUnauthorized, getAs(router, "/me/sessions", token).Code)
+
+		w = postJSON(t, router, http.MethodPost, "/login", "", map[string]interface{}{"email": "logout@example.com", "password": "password456"})
+		assert.Equal(t, http.StatusCreated, w.Code)
+
+		var session sessionResponse
+		json.Unmarshal(w.Body.Bytes(), &session)
+		assert.Equal(t, http.StatusOK, getAs(router, "/me/sessions", session.Token).Code)
+	})
+
+	t.Run("tokens of deleted users are rejected", func(t *testing.T) {
+		assert.Equal(t, http.StatusUnauthorized, getAs(router, "/me/sessions", generateTestToken(t, "ghost@example.com", 9999)).Code)
+	})
+}
@@ -0,0 +1,85 @@
+package routes
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/salads-source/go_http_server/mail"
+	"github.com/salads-source/go_http_server/models"
+)
+
+type forgotPasswordInput struct {
+	Email string `binding:"required"`
+}
+
+type resetPasswordInput struct {
+	Token       string `binding:"required"`
+	NewPassword string `binding:"required"`
+}
+
+// forgotPassword emails a reset token. It answers the same way whether or
+// not the account exists, and sends the email in the background so the
+// response time does not tell either.
+func forgotPassword(context *gin.Context) {
+	var input forgotPasswordInput
+	err := context.ShouldBindJSON(&input)
+
+	if err != nil {
+		context.JSON(http.StatusBadRequest, gin.H{"message": "Could not parse request"})
+		return
+	}
+
+	mailer := mail.Default
+
+	inBackground(func() {
+		user, token, err := models.CreatePasswordReset(input.Email)
+
+		if err != nil {
+			return
+		}
+
+		err = mailer.Send(mail.Message{
+			To:      user.Email,
+			Subject: "Reset your password",
+			Body: "Someone asked to reset the password of your account. If it was you, use this token to choose a new password:\n\n" +
+				token + "\n\nIt expires in " + strconv.Itoa(int(models.PasswordResetLifetime.Minutes())) + " minutes. If it was not you, you can ignore this email.",
+		})
+
+		if err != nil {
+			log.Printf("could not send password reset email to user %d: %v", user.ID, err)
+		}
+	})
+
+	context.JSON(http.StatusAccepted, gin.H{"message": "If an account exists for that email, a reset token has been sent to it."})
+}
+
+func resetPassword(context *gin.Context) {
+	var input resetPasswordInput
+	err := context.ShouldBindJSON(&input)
+
+	if err != nil {
+		context.JSON(http.StatusBadRequest, gin.H{"message": "Could not parse request"})
+		return
+	}
+
+	err = models.ResetPassword(input.Token, input.NewPassword)
+
+	if errors.Is(err, models.ErrInvalidResetToken) {
+		context.JSON(http.StatusBadRequest, gin.H{"message": "The reset token is invalid or has expired."})
+		return
+	}
+
+	if rejectWeakPassword(context, err) {
+		return
+	}
+
+	if err != nil {
+		context.JSON(http.StatusInternalServerError, gin.H{"message": "Could not reset password, try again later"})
+		return
+	}
+
+	context.JSON(http.StatusOK, gin.H{"message": "Password reset, please log in again."})
+}
@@ -0,0 +1,121 @@
+package routes
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/salads-source/go_http_server/db"
+	"github.com/salads-source/go_http_server/models"
+	"github.com/stretchr/testify/assert"
+)
+
+type policyResponse struct {
+	Message    string `json:"message"`
+	Violations []struct {
+		Code    string
+		Message string
+	} `json:"violations"`
+}
+
+func violationCodes(t *testing.T, body []byte) []string {
+	var response policyResponse
+	json.Unmarshal(body, &response)
+
+	var codes []string
+	for _, violation := range response.Violations {
+		codes = append(codes, violation.Code)
+	}
+	return codes
+}
+
+// writeBreachedFile writes a sorted hash file with the given passwords and
+// some filler around them.
+func writeBreachedFile(t *testing.T, passwords ...string) string {
+	var lines []string
+	for i := 0; i < 200; i++ {
+		passwords = append(passwords, fmt.Sprintf("filler-%d", i))
+	}
+	for i, password := range passwords {
+		sum := sha1.Sum([]byte(password))
+		lines = append(lines, fmt.Sprintf("%s:%d", strings.ToUpper(hex.EncodeToString(sum[:])), i+1))
+	}
+	sort.Strings(lines)
+
+	path := filepath.Join(t.TempDir(), "breached.txt")
+	if err := os.WriteFile(path, []byte(strings.Join(lines, "\r\n")+"\r\n"), 0o644); err != nil {
+		t.Fatalf("Failed to write breached passwords: %v", err)
+	}
+	return path
+}
+
+func TestPasswordPolicy(t *testing.T) {
+	_, router := setupTestRouter(t)
+
+	bannedPath := filepath.Join(t.TempDir(), "banned.txt")
+	os.WriteFile(bannedPath, []byte("letmein123\n Qwertyuiop \n"), 0o644)
+
+	breached := []string{"hunter2hunter2", "correcthorse", "trustno1trustno1"}
+	policy, err := models.LoadPasswordPolicy("10", bannedPath, writeBreachedFile(t, breached...))
+	if err != nil {
+		t.Fatalf("Failed to load password policy: %v", err)
+	}
+
+	original := models.Passwords
+	models.Passwords = policy
+	t.Cleanup(func() {
+		models.Passwords = original
+		policy.Breached.Close()
+	})
+
+	signup := func(email, password string) ([]string, int) {
+		w := postJSON(t, router, http.MethodPost, "/signup", "", map[string]interface{}{"email": email, "password": password})
+		return violationCodes(t, w.Body.Bytes()), w.Code
+	}
+
+	t.Run("signup reports every violation", func(t *testing.T) {
+		codes, status := signup("brief@example.com", "short")
+		assert.Equal(t, http.StatusBadRequest, status)
+		assert.Equal(t, []string{"too_short"}, codes)
+
+		codes, status = signup("qwertyuiop@example.com", "QWERTYUIOP")
+		assert.Equal(t, http.StatusBadRequest, status)
+		assert.Equal(t, []string{"banned", "contains_email"}, codes)
+
+		codes, _ = signup("long@example.com", strings.Repeat("x", 73))
+		assert.Equal(t, []string{"too_long"}, codes)
+
+		codes, _ = signup("banned@example.com", "letmein123")
+		assert.Equal(t, []string{"banned"}, codes)
+	})
+
+	t.Run("breached passwords are rejected", func(t *testing.T) {
+		for _, password := range breached {
+			codes, status := signup("breached@example.com", password)
+			assert.Equal(t, http.StatusBadRequest, status, password)
+			assert.Equal(t, []string{"breached"}, codes, password)
+		}
+
+		_, status := signup("breached@example.com", "a-fresh-passphrase")
+		assert.Equal(t, http.StatusCreated, status)
+	})
+
+	t.Run("password changes follow the policy", func(t *testing.T) {
+		userId := createTestUser(t, db.DB, "changer@example.com", "password123")
+		token := generateTestToken(t, "changer@example.com", userId)
+
+		w := postJSON(t, router, http.MethodPut, "/me/password", token, map[string]interface{}{"currentPassword": "password123", "newPassword": "changer-2024"})
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+		assert.Equal(t, []string{"contains_email"}, violationCodes(t, w.Body.Bytes()))
+
+		// The old password still works.
+		assert.Equal(t, http.StatusOK, getAs(router, "/me", token).Code)
+	})
+}
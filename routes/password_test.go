@@ -0,0 +1,111 @@
+package routes
+
+import (
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/salads-source/go_http_server/db"
+	"github.com/salads-source/go_http_server/mail"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPasswordReset(t *testing.T) {
+	_, router := setupTestRouter(t)
+	userId := createTestUser(t, db.DB, "reset@example.com", "password123")
+	mailPath := filepath.Join(t.TempDir(), "mail.txt")
+	mail.Default = mail.NewFileMailer(mailPath)
+
+	tokenPattern := regexp.MustCompile(`(?m)^[0-9a-f]{64}\r?$`)
+
+	// requestReset asks for a reset and waits for the email with the new
+	// token to arrive. Earlier requests are moved back in time so they don't
+	// throttle this one.
+	requestReset := func(t *testing.T) string {
+		db.DB.Exec("UPDATE password_resets SET created_at = ?", time.Now().Add(-2*time.Minute).UTC())
+		before := len(readMail(mailPath))
+
+		w := postJSON(t, router, http.MethodPost, "/password/forgot", "", map[string]interface{}{"email": "Reset@example.com"})
+		assert.Equal(t, http.StatusAccepted, w.Code)
+
+		var token string
+		assert.Eventually(t, func() bool {
+			tokens := tokenPattern.FindAllString(readMail(mailPath)[before:], -1)
+			if len(tokens) == 0 {
+				return false
+			}
+			token = tokens[0][:64]
+			return true
+		}, 5*time.Second, 10*time.Millisecond)
+		return token
+	}
+
+	t.Run("unknown emails get the same answer", func(t *testing.T) {
+		known := postJSON(t, router, http.MethodPost, "/password/forgot", "", map[string]interface{}{"email": "reset@example.com"})
+		unknown := postJSON(t, router, http.MethodPost, "/password/forgot", "", map[string]interface{}{"email": "nobody@example.com"})
+		assert.Equal(t, known.Code, unknown.Code)
+		assert.Equal(t, known.Body.String(), unknown.Body.String())
+
+		time.Sleep(100 * time.Millisecond)
+		assert.NotContains(t, readMail(mailPath), "nobody@example.com")
+	})
+
+	t.Run("requests are throttled per account", func(t *testing.T) {
+		forgot := func() {
+			before := readMail(mailPath)
+			w := postJSON(t, router, http.MethodPost, "/password/forgot", "", map[string]interface{}{"email": "reset@example.com"})
+			assert.Equal(t, http.StatusAccepted, w.Code)
+			time.Sleep(100 * time.Millisecond)
+			assert.Equal(t, before, readMail(mailPath))
+		}
+
+		// One a minute.
+		requestReset(t)
+		forgot()
+
+		// And no more than five a day, however far apart.
+		db.DB.Exec("DELETE FROM password_resets")
+		for i := 0; i < 5; i++ {
+			db.DB.Exec("INSERT INTO password_resets(user_id, token_hash, created_at, expires_at) VALUES (?, ?, ?, ?)",
+				userId, "old-"+strconv.Itoa(i), time.Now().Add(-time.Duration(i+1)*time.Hour).UTC(), time.Now().UTC())
+		}
+		forgot()
+
+		db.DB.Exec("DELETE FROM password_resets")
+	})
+
+	t.Run("a reset token works once and signs out everywhere", func(t *testing.T) {
+		oldToken := generateTestToken(t, "reset@example.com", userId)
+		token := requestReset(t)
+
+		w := postJSON(t, router, http.MethodPost, "/password/reset", "", map[string]interface{}{"token": "not-a-token", "newPassword": "password456"})
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+
+		w = postJSON(t, router, http.MethodPost, "/password/reset", "", map[string]interface{}{"token": token, "newPassword": "password456"})
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, http.StatusUnauthorized, getAs(router, "/me/sessions", oldToken).Code)
+
+		w = postJSON(t, router, http.MethodPost, "/login", "", map[string]interface{}{"email": "reset@example.com", "password": "password456"})
+		assert.Equal(t, http.StatusCreated, w.Code)
+
+		w = postJSON(t, router, http.MethodPost, "/password/reset", "", map[string]interface{}{"token": token, "newPassword": "password789"})
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+	})
+
+	t.Run("expired tokens are rejected", func(t *testing.T) {
+		token := requestReset(t)
+		db.DB.Exec("UPDATE password_resets SET expires_at = ?", time.Now().Add(-time.Minute).UTC())
+
+		w := postJSON(t, router, http.MethodPost, "/password/reset", "", map[string]interface{}{"token": token, "newPassword": "password789"})
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+	})
+}
+
+func readMail(path string) string {
+	data, _ := os.ReadFile(path)
+	return string(data)
+}
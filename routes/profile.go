@@ -0,0 +1,46 @@
+package routes
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/salads-source/go_http_server/models"
+)
+
+func getMe(context *gin.Context) {
+	profile, err := models.GetProfile(context.GetInt64("userId"))
+
+	if err != nil {
+		context.JSON(http.StatusInternalServerError, gin.H{"message": "Could not fetch profile, try again later"})
+		return
+	}
+
+	context.JSON(http.StatusOK, gin.H{"message": "Profile fetched successfully", "profile": profile})
+}
+
+// updateMe changes the caller's profile. The email address and password
+// have their own flows and cannot be changed here.
+func updateMe(context *gin.Context) {
+	var update models.ProfileUpdate
+	err := context.ShouldBindJSON(&update)
+
+	if err != nil {
+		context.JSON(http.StatusBadRequest, gin.H{"message": "Could not parse request data."})
+		return
+	}
+
+	profile, err := models.UpdateProfile(context.GetInt64("userId"), update)
+
+	if errors.Is(err, models.ErrInvalidTimeZone) || errors.Is(err, models.ErrInvalidLocale) || errors.Is(err, models.ErrInvalidAvatarURL) {
+		context.JSON(http.StatusBadRequest, gin.H{"message": err.Error()})
+		return
+	}
+
+	if err != nil {
+		context.JSON(http.StatusInternalServerError, gin.H{"message": "Could not update profile, try again later"})
+		return
+	}
+
+	context.JSON(http.StatusOK, gin.H{"message": "Profile updated", "profile": profile})
+}
@@ -0,0 +1,104 @@
+package routes
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"testing"
+
+	"github.com/salads-source/go_http_server/db"
+	"github.com/salads-source/go_http_server/models"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestProfiles(t *testing.T) {
+	_, router := setupTestRouter(t)
+	userId := createTestUser(t, db.DB, "profile@example.com", "password123")
+	token := generateTestToken(t, "profile@example.com", userId)
+
+	type profileResponse struct {
+		Profile struct {
+			ID          int64
+			Email       string
+			DisplayName string
+			Bio         string
+			AvatarURL   string
+			TimeZone    string
+			Locale      string
+			Role        string
+		} `json:"profile"`
+	}
+
+	t.Run("GET /me shows the caller's profile", func(t *testing.T) {
+		w := getAs(router, "/me", token)
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.NotContains(t, w.Body.String(), "Password")
+		assert.NotContains(t, w.Body.String(), "$2a$")
+
+		var response profileResponse
+		json.Unmarshal(w.Body.Bytes(), &response)
+		assert.Equal(t, userId, response.Profile.ID)
+		assert.Equal(t, "profile@example.com", response.Profile.Email)
+		assert.Equal(t, "attendee", response.Profile.Role)
+	})
+
+	t.Run("PATCH /me updates only the given fields", func(t *testing.T) {
+		w := postJSON(t, router, http.MethodPatch, "/me", token, map[string]interface{}{
+			"displayName": "Pat Profile",
+			"bio":         "Runs the Go meetup.",
+			"avatarURL":   "https://example.com/pat.png",
+			"timeZone":    "Europe/Paris",
+			"locale":      "pt-BR",
+		})
+		assert.Equal(t, http.StatusOK, w.Code)
+
+		w = postJSON(t, router, http.MethodPatch, "/me", token, map[string]interface{}{"bio": "Runs two meetups."})
+		assert.Equal(t, http.StatusOK, w.Code)
+
+		var response profileResponse
+		json.Unmarshal(w.Body.Bytes(), &response)
+		assert.Equal(t, "Pat Profile", response.Profile.DisplayName)
+		assert.Equal(t, "Runs two meetups.", response.Profile.Bio)
+		assert.Equal(t, "https://example.com/pat.png", response.Profile.AvatarURL)
+		assert.Equal(t, "Europe/Paris", response.Profile.TimeZone)
+		assert.Equal(t, "pt-BR", response.Profile.Locale)
+	})
+
+	t.Run("invalid profile fields are rejected", func(t *testing.T) {
+		for _, payload := range []map[string]interface{}{
+			{"timeZone": "Mars/Olympus"},
+			{"timeZone": "Local"},
+			{"locale": "english please"},
+			{"avatarURL": "javascript:alert(1)"},
+		} {
+			w := postJSON(t, router, http.MethodPatch, "/me", token, payload)
+			assert.Equal(t, http.StatusBadRequest, w.Code, payload)
+		}
+	})
+
+	t.Run("events show the organizer's public profile", func(t *testing.T) {
+		eventId := createTestEvent(t, userId)
+
+		w := getAs(router, "/events/"+strconv.FormatInt(eventId, 10), "")
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.NotContains(t, w.Body.String(), "UserID")
+		assert.NotContains(t, w.Body.String(), "profile@example.com")
+		assert.NotContains(t, w.Body.String(), "Europe/Paris")
+
+		var event models.Event
+		json.Unmarshal(w.Body.Bytes(), &event)
+		assert.Equal(t, userId, event.Organizer.ID)
+		assert.Equal(t, "Pat Profile", event.Organizer.DisplayName)
+
+		w = getAs(router, "/events", "")
+		var events []models.Event
+		json.Unmarshal(w.Body.Bytes(), &events)
+		assert.NotEmpty(t, events)
+		assert.Equal(t, "Pat Profile", events[0].Organizer.DisplayName)
+	})
+
+	t.Run("users never serialize their password", func(t *testing.T) {
+		data, _ := json.Marshal(models.User{ID: 1, Email: "a@example.com", Password: "secret-hash"})
+		assert.NotContains(t, string(data), "secret-hash")
+	})
+}
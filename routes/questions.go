@@ -0,0 +1,130 @@
+package routes
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/salads-source/go_http_server/models"
+	"github.com/salads-source/go_http_server/utils"
+)
+
+type questionsInput struct {
+	Questions []models.Question `binding:"dive"`
+}
+
+func getQuestions(context *gin.Context) {
+	eventId, err := strconv.ParseInt(context.Param("id"), 10, 64)
+
+	if err != nil {
+		context.JSON(http.StatusBadRequest, gin.H{"message": "Invalid event Id"})
+		return
+	}
+
+	_, ok := loadVisibleEvent(context, eventId)
+
+	if !ok {
+		return
+	}
+
+	questions, err := models.GetQuestions(eventId)
+
+	if err != nil {
+		context.JSON(http.StatusInternalServerError, gin.H{"message": "Could not fetch questions, try again later"})
+		return
+	}
+
+	context.JSON(http.StatusOK, gin.H{"questions": questions})
+}
+
+// setQuestions replaces the registration form of an event.
+func setQuestions(context *gin.Context) {
+	event, ok := loadOrganizedEvent(context)
+
+	if !ok {
+		return
+	}
+
+	var input questionsInput
+	err := context.ShouldBindJSON(&input)
+
+	if err != nil {
+		context.JSON(http.StatusBadRequest, gin.H{"message": "Could not parse request data."})
+		return
+	}
+
+	if input.Questions == nil {
+		input.Questions = []models.Question{}
+	}
+
+	err = models.ValidateQuestions(input.Questions)
+
+	if err != nil {
+		context.JSON(http.StatusBadRequest, gin.H{"message": err.Error()})
+		return
+	}
+
+	err = event.SetQuestions(input.Questions)
+
+	if err != nil {
+		context.JSON(http.StatusInternalServerError, gin.H{"message": "Could not save questions, try again later"})
+		return
+	}
+
+	context.JSON(http.StatusOK, gin.H{"message": "Questions saved successfully", "questions": input.Questions})
+}
+
+func getAnswers(context *gin.Context) {
+	event, ok := loadOrganizedEvent(context)
+
+	if !ok {
+		return
+	}
+
+	questions, err := models.GetQuestions(event.ID)
+
+	if err != nil {
+		context.JSON(http.StatusInternalServerError, gin.H{"message": "Could not fetch answers, try again later"})
+		return
+	}
+
+	answers := []models.AnswerExportRow{}
+	err = models.StreamAnswers(event.ID, questions, func(row models.AnswerExportRow) error {
+		answers = append(answers, row)
+		return nil
+	})
+
+	if err != nil {
+		context.JSON(http.StatusInternalServerError, gin.H{"message": "Could not fetch answers, try again later"})
+		return
+	}
+
+	context.JSON(http.StatusOK, gin.H{"questions": questions, "answers": answers})
+}
+
+// exportAnswers streams the answers to an event's registration form. The
+// columns depend on the form, so parquet, which needs a fixed schema, is not
+// offered.
+func exportAnswers(context *gin.Context) {
+	event, ok := loadOrganizedEvent(context)
+
+	if !ok {
+		return
+	}
+
+	if format, err := utils.ParseExportFormat(context.Query("format")); err == nil && format == utils.ExportFormatParquet {
+		context.JSON(http.StatusBadRequest, gin.H{"message": "Unsupported export format, use csv or ndjson."})
+		return
+	}
+
+	questions, err := models.GetQuestions(event.ID)
+
+	if err != nil {
+		context.JSON(http.StatusInternalServerError, gin.H{"message": "Could not export answers, try again later"})
+		return
+	}
+
+	streamExport(context, "answers", models.AnswerExportHeader(questions), func(write func(models.AnswerExportRow) error) error {
+		return models.StreamAnswers(event.ID, questions, write)
+	})
+}
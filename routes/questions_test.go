@@ -0,0 +1,119 @@
+package routes
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/salads-source/go_http_server/db"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRegistrationQuestions(t *testing.T) {
+	_, router := setupTestRouter(t)
+	organizerId := createTestUser(t, db.DB, "formorganizer@example.com", "password123")
+	organizerToken := generateTestToken(t, "formorganizer@example.com", organizerId)
+
+	eventId := createTestEvent(t, organizerId)
+	eventPath := "/events/" + strconv.FormatInt(eventId, 10)
+
+	questions := []map[string]interface{}{
+		{"key": "company", "label": "Company", "type": "text"},
+		{"key": "shirt", "label": "T-shirt size", "type": "choice", "required": true, "options": []string{"S", "M", "L"}},
+		{"key": "diet", "label": "Dietary needs", "type": "multi_choice", "options": []string{"vegetarian", "vegan", "other"}},
+		{"key": "diet_other", "label": "Please specify", "type": "text", "required": true, "showIf": map[string]interface{}{"key": "diet", "values": []string{"other"}}},
+		{"key": "guests", "label": "Guests", "type": "number", "min": 0, "max": 3},
+	}
+
+	t.Run("defining the form", func(t *testing.T) {
+		invalid := []map[string]interface{}{
+			{"key": "shirt", "label": "T-shirt size", "type": "choice"},
+		}
+		w := postJSON(t, router, http.MethodPut, eventPath+"/questions", organizerToken, map[string]interface{}{"questions": invalid})
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+
+		conditional := []map[string]interface{}{
+			{"key": "later", "label": "Later", "type": "text", "showIf": map[string]interface{}{"key": "missing", "values": []string{"x"}}},
+		}
+		w = postJSON(t, router, http.MethodPut, eventPath+"/questions", organizerToken, map[string]interface{}{"questions": conditional})
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+
+		w = postJSON(t, router, http.MethodPut, eventPath+"/questions", generateTestToken(t, "other@example.com", organizerId+1), map[string]interface{}{"questions": questions})
+		assert.Equal(t, http.StatusUnauthorized, w.Code)
+
+		w = postJSON(t, router, http.MethodPut, eventPath+"/questions", organizerToken, map[string]interface{}{"questions": questions})
+		assert.Equal(t, http.StatusOK, w.Code)
+
+		w = getAs(router, eventPath+"/questions", "")
+		var response struct {
+			Questions []struct{ Key string } `json:"questions"`
+		}
+		json.Unmarshal(w.Body.Bytes(), &response)
+		assert.Len(t, response.Questions, 5)
+	})
+
+	t.Run("exports have the form's columns before anyone answers", func(t *testing.T) {
+		w := getAs(router, eventPath+"/answers/export?format=csv", organizerToken)
+		assert.Equal(t, http.StatusOK, w.Code)
+
+		records, err := csv.NewReader(strings.NewReader(w.Body.String())).ReadAll()
+		assert.NoError(t, err)
+		assert.Equal(t, [][]string{{"registration_id", "user_id", "user_email", "company", "shirt", "diet", "diet_other", "guests"}}, records)
+	})
+
+	tests := []struct {
+		name           string
+		answers        map[string]interface{}
+		expectedStatus int
+	}{
+		{"required answer missing", map[string]interface{}{"company": "Acme"}, http.StatusBadRequest},
+		{"not an option", map[string]interface{}{"shirt": "XL"}, http.StatusBadRequest},
+		{"number out of range", map[string]interface{}{"shirt": "M", "guests": 5}, http.StatusBadRequest},
+		{"unknown question", map[string]interface{}{"shirt": "M", "age": 30}, http.StatusBadRequest},
+		{"conditional question becomes required", map[string]interface{}{"shirt": "M", "diet": []string{"other"}}, http.StatusBadRequest},
+		{"valid answers", map[string]interface{}{"shirt": "M", "company": "Acme", "diet": []string{"vegan", "other"}, "diet_other": "No nuts", "guests": 1}, http.StatusCreated},
+		{"hidden answers are dropped", map[string]interface{}{"shirt": "L", "diet_other": "Ignored"}, http.StatusCreated},
+	}
+
+	for i, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			email := "formattendee" + strconv.Itoa(i) + "@example.com"
+			userId := createTestUser(t, db.DB, email, "password123")
+			w := postJSON(t, router, http.MethodPost, eventPath+"/register", generateTestToken(t, email, userId), map[string]interface{}{"answers": tt.answers})
+			assert.Equal(t, tt.expectedStatus, w.Code)
+		})
+	}
+
+	t.Run("organizer views answers", func(t *testing.T) {
+		w := getAs(router, eventPath+"/answers", organizerToken)
+		assert.Equal(t, http.StatusOK, w.Code)
+
+		var response struct {
+			Answers []struct {
+				UserEmail string                 `json:"user_email"`
+				Answers   map[string]interface{} `json:"answers"`
+			} `json:"answers"`
+		}
+		json.Unmarshal(w.Body.Bytes(), &response)
+		assert.Len(t, response.Answers, 2)
+		assert.Equal(t, "No nuts", response.Answers[0].Answers["diet_other"])
+		assert.NotContains(t, response.Answers[1].Answers, "diet_other")
+
+		assert.Equal(t, http.StatusUnauthorized, getAs(router, eventPath+"/answers", generateTestToken(t, "other@example.com", organizerId+1)).Code)
+	})
+
+	t.Run("organizer exports answers", func(t *testing.T) {
+		w := getAs(router, eventPath+"/answers/export?format=csv", organizerToken)
+		assert.Equal(t, http.StatusOK, w.Code)
+
+		records, err := csv.NewReader(strings.NewReader(w.Body.String())).ReadAll()
+		assert.NoError(t, err)
+		assert.Equal(t, []string{"registration_id", "user_id", "user_email", "company", "shirt", "diet", "diet_other", "guests"}, records[0])
+		assert.Equal(t, []string{"Acme", "M", "vegan; other", "No nuts", "1"}, records[1][3:])
+
+		assert.Equal(t, http.StatusBadRequest, getAs(router, eventPath+"/answers/export?format=parquet", organizerToken).Code)
+	})
+}
@@ -1,17 +1,75 @@
 package routes
 
 import (
+	"database/sql"
+	"errors"
+	"io"
 	"net/http"
 	"strconv"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/salads-source/go_http_server/models"
 )
 
 func getRegistrations(context *gin.Context) {
-	var registrations []models.Registration
+	filter, err := parseRegistrationFilter(context)
 
-	registrations, err := models.GetAllRegistrations()
+	if err != nil {
+		context.JSON(http.StatusBadRequest, gin.H{"message": err.Error()})
+		return
+	}
+
+	filter.ViewerID = context.GetInt64("userId")
+	registrations, err := models.GetAllRegistrations(filter)
+
+	if err != nil {
+		context.JSON(http.StatusInternalServerError, gin.H{"message": "Could not fetch registrations"})
+		return
+	}
+
+	context.JSON(http.StatusOK, gin.H{"message": "Registrations fetched successfully", "registrations": registrations})
+}
+
+func getMyRegistrations(context *gin.Context) {
+	registrations, err := models.GetUserRegistrations(context.GetInt64("userId"))
+
+	if err == nil {
+		events := make([]*models.Event, len(registrations))
+
+		for i := range registrations {
+			events[i] = &registrations[i].Event
+		}
+
+		err = models.AttachOrganizers(events...)
+	}
+
+	if err != nil {
+		context.JSON(http.StatusInternalServerError, gin.H{"message": "Could not fetch registrations"})
+		return
+	}
+
+	context.JSON(http.StatusOK, gin.H{"message": "Registrations fetched successfully", "registrations": registrations})
+}
+
+// getEventRegistrations lists the attendees of an event for its organizer,
+// optionally narrowed by status.
+func getEventRegistrations(context *gin.Context) {
+	filter, err := parseRegistrationFilter(context)
+
+	if err != nil {
+		context.JSON(http.StatusBadRequest, gin.H{"message": err.Error()})
+		return
+	}
+
+	event, ok := loadOrganizedEvent(context)
+
+	if !ok {
+		return
+	}
+
+	filter.EventID = event.ID
+	registrations, err := models.GetAllRegistrations(filter)
 
 	if err != nil {
 		context.JSON(http.StatusInternalServerError, gin.H{"message": "Could not fetch registrations"})
@@ -31,14 +89,42 @@ func getRegistration(context *gin.Context) {
 
 	registration, err := models.GetRegistrationById(registrationId)
 
+	if errors.Is(err, sql.ErrNoRows) {
+		context.JSON(http.StatusNotFound, gin.H{"message": "Registration not found."})
+		return
+	}
+
 	if err != nil {
 		context.JSON(http.StatusInternalServerError, gin.H{"message": "Could not fetch registration, try again later"})
 		return
 	}
 
+	userId := context.GetInt64("userId")
+
+	if registration.UserID != userId {
+		event, err := models.GetEventByID(registration.EventID)
+
+		if err != nil && !errors.Is(err, sql.ErrNoRows) {
+			context.JSON(http.StatusInternalServerError, gin.H{"message": "Could not fetch registration, try again later"})
+			return
+		}
+
+		// Other people's registrations look the same as missing ones, so
+		// the endpoint doesn't reveal who attends what.
+		if event == nil || !canManageEvent(context, event) {
+			context.JSON(http.StatusNotFound, gin.H{"message": "Registration not found."})
+			return
+		}
+	}
+
 	context.JSON(http.StatusOK, registration)
 }
 
+type registrationInput struct {
+	Answers models.Answers
+	Guests  []models.Guest `binding:"dive"`
+}
+
 func registerForEvent(context *gin.Context) {
 	userId := context.GetInt64("userId")
 	eventId, err := strconv.ParseInt(context.Param("id"), 10, 64)
@@ -48,21 +134,121 @@ func registerForEvent(context *gin.Context) {
 		return
 	}
 
-	event, err := models.GetEventByID(eventId)
+	allowConflicts := false
+
+	if value := context.Query("allow_conflicts"); value != "" {
+		allowConflicts, err = strconv.ParseBool(value)
+
+		if err != nil {
+			context.JSON(http.StatusBadRequest, gin.H{"message": "allow_conflicts must be true or false"})
+			return
+		}
+	}
+
+	// The body is optional for events without a registration form.
+	var input registrationInput
+
+	if context.Request.ContentLength != 0 {
+		err = context.ShouldBindJSON(&input)
+
+		if err != nil && !errors.Is(err, io.EOF) {
+			context.JSON(http.StatusBadRequest, gin.H{"message": "Could not parse request data."})
+			return
+		}
+	}
+
+	event, ok := loadVisibleEvent(context, eventId)
+
+	if !ok {
+		return
+	}
+
+	questions, err := models.GetQuestions(eventId)
 
 	if err != nil {
-		context.JSON(http.StatusInternalServerError, gin.H{"message": "Could not fetch event"})
+		context.JSON(http.StatusInternalServerError, gin.H{"message": "Could not register user for event"})
+		return
+	}
+
+	answers, err := models.ValidateAnswers(questions, input.Answers)
+
+	if err != nil {
+		context.JSON(http.StatusBadRequest, gin.H{"message": err.Error()})
 		return
 	}
 
-	err = event.Register(userId)
+	conflicts, err := models.GetRegistrationConflicts(userId, *event)
 
 	if err != nil {
 		context.JSON(http.StatusInternalServerError, gin.H{"message": "Could not register user for event"})
 		return
 	}
 
-	context.JSON(http.StatusCreated, gin.H{"message": "User registered for event successfully"})
+	// Overlapping registrations are refused unless the caller opts in, in
+	// which case the clashes are returned as a warning.
+	if len(conflicts) > 0 && !allowConflicts {
+		context.JSON(http.StatusConflict, gin.H{"message": "Event overlaps events you are already registered for.", "conflicts": conflicts})
+		return
+	}
+
+	status, err := event.Register(userId, answers, input.Guests)
+
+	if errors.Is(err, models.ErrTooManyGuests) {
+		context.JSON(http.StatusBadRequest, gin.H{"message": "You can bring at most " + strconv.Itoa(event.MaxGuests) + " guests."})
+		return
+	}
+
+	if errors.Is(err, models.ErrEventFull) {
+		context.JSON(http.StatusConflict, gin.H{"message": "Event is full."})
+		return
+	}
+
+	if errors.Is(err, models.ErrAlreadyRegistered) {
+		context.JSON(http.StatusConflict, gin.H{"message": "You are already registered for this event."})
+		return
+	}
+
+	if err != nil {
+		context.JSON(http.StatusInternalServerError, gin.H{"message": "Could not register user for event"})
+		return
+	}
+
+	response := gin.H{"message": "User registered for event successfully", "status": status}
+
+	if status == models.RegistrationStatusPending {
+		response["message"] = "Registration submitted for approval"
+	}
+
+	if len(conflicts) > 0 {
+		response["conflicts"] = conflicts
+	}
+
+	context.JSON(http.StatusCreated, response)
+}
+
+// getOwnRegistration lets registrants follow the status of their
+// registration.
+func getOwnRegistration(context *gin.Context) {
+	eventId, err := strconv.ParseInt(context.Param("id"), 10, 64)
+
+	if err != nil {
+		context.JSON(http.StatusBadRequest, gin.H{"message": "Invalid event Id"})
+		return
+	}
+
+	registration, err := models.GetRegistration(eventId, context.GetInt64("userId"))
+
+	if errors.Is(err, models.ErrNotRegistered) {
+		context.JSON(http.StatusNotFound, gin.H{"message": "You are not registered for this event."})
+		return
+	}
+
+	if err != nil {
+		context.JSON(http.StatusInternalServerError, gin.H{"message": "Could not fetch registration, try again later"})
+		return
+	}
+
+	context.JSON(http.StatusOK, gin.H{"message": "Registration fetched successfully", "registration": registration})
 }
 
 func cancelRegistration(context *gin.Context) {
@@ -74,8 +260,22 @@ func cancelRegistration(context *gin.Context) {
 		return
 	}
 
-	var event models.Event
-	event.ID = eventId
+	event, err := models.GetEventByID(eventId)
+
+	if errors.Is(err, sql.ErrNoRows) {
+		context.JSON(http.StatusNotFound, gin.H{"message": "Event not found."})
+		return
+	}
+
+	if err != nil {
+		context.JSON(http.StatusInternalServerError, gin.H{"message": "Could not fetch event, try again later"})
+		return
+	}
+
+	if event.RegistrationsLocked(time.Now()) {
+		context.JSON(http.StatusForbidden, gin.H{"message": "Registrations can no longer be cancelled."})
+		return
+	}
 
 	err = event.CancelRegistration(userId)
 
@@ -14,6 +14,8 @@ import (
 
 func TestGetRegistrations(t *testing.T) {
 	_, router := setupTestRouter(t)
+	userId := createTestUser(t, db.DB, "reguser@example.com", "password123")
+	token := generateTestToken(t, "reguser@example.com", userId)
 
 	tests := []struct {
 		name           string
@@ -35,7 +37,6 @@ func TestGetRegistrations(t *testing.T) {
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
 			if tt.setupRegs {
-				userId := createTestUser(t, db.DB, "reguser@example.com", "password123")
 				eventResult, err := db.DB.Exec(
 					"INSERT INTO events(name, description, location, dateTime, user_id) VALUES (?, ?, ?, ?, ?)",
 					"Test Event", "Test Description", "Test Location", time.Now(), userId,
@@ -55,6 +56,7 @@ func TestGetRegistrations(t *testing.T) {
 			}
 
 			req, _ := http.NewRequest(http.MethodGet, "/registrations", nil)
+			req.Header.Set("Authorization", token)
 			w := httptest.NewRecorder()
 			router.ServeHTTP(w, req)
 
@@ -84,6 +86,7 @@ func TestGetRegistrations(t *testing.T) {
 func TestGetRegistration(t *testing.T) {
 	_, router := setupTestRouter(t)
 	userId := createTestUser(t, db.DB, "reguser@example.com", "password123")
+	token := generateTestToken(t, "reguser@example.com", userId)
 
 	tests := []struct {
 		name           string
@@ -106,7 +109,7 @@ func TestGetRegistration(t *testing.T) {
 		{
 			name:           "get registration by id - not found",
 			registrationID: "999",
-			expectedStatus: http.StatusInternalServerError,
+			expectedStatus: http.StatusNotFound,
 			setupReg:       false,
 		},
 	}
@@ -133,6 +136,7 @@ func TestGetRegistration(t *testing.T) {
 			}
 
 			req, _ := http.NewRequest(http.MethodGet, "/registrations/"+tt.registrationID, nil)
+			req.Header.Set("Authorization", token)
 			w := httptest.NewRecorder()
 			router.ServeHTTP(w, req)
 
@@ -194,7 +198,7 @@ func TestRegisterForEvent(t *testing.T) {
 			name:           "event not found",
 			eventID:        "999",
 			authToken:      token,
-			expectedStatus: http.StatusInternalServerError,
+			expectedStatus: http.StatusNotFound,
 			setupEvent:     false,
 		},
 	}
@@ -290,6 +294,14 @@ func TestCancelRegistration(t *testing.T) {
 			setupEvent:     true,
 			setupReg:       false,
 		},
+		{
+			name:           "event not found",
+			eventID:        "999",
+			authToken:      token,
+			expectedStatus: http.StatusNotFound,
+			setupEvent:     false,
+			setupReg:       false,
+		},
 	}
 
 	for _, tt := range tests {
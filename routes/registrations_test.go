@@ -0,0 +1,100 @@
+package routes
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/salads-source/go_http_server/db"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRegistrationAccess(t *testing.T) {
+	_, router := setupTestRouter(t)
+	organizerId := createTestUser(t, db.DB, "scopeorganizer@example.com", "password123")
+	attendeeId := createTestUser(t, db.DB, "scopeattendee@example.com", "password123")
+	strangerId := createTestUser(t, db.DB, "scopestranger@example.com", "password123")
+	organizerToken := generateTestToken(t, "scopeorganizer@example.com", organizerId)
+	attendeeToken := generateTestToken(t, "scopeattendee@example.com", attendeeId)
+	strangerToken := generateTestToken(t, "scopestranger@example.com", strangerId)
+
+	laterId := createTestEvent(t, organizerId)
+	soonerId := createTestEvent(t, organizerId)
+	db.DB.Exec("UPDATE events SET dateTime = ? WHERE id = ?", time.Now().Add(48*time.Hour), laterId)
+	db.DB.Exec("UPDATE events SET dateTime = ?, visibility = 'private' WHERE id = ?", time.Now().Add(24*time.Hour), soonerId)
+
+	for _, eventId := range []int64{laterId, soonerId} {
+		db.DB.Exec("INSERT INTO registrations(event_id, user_id) VALUES (?, ?)", eventId, attendeeId)
+	}
+
+	var registrationId int64
+	db.DB.QueryRow("SELECT id FROM registrations WHERE event_id = ?", laterId).Scan(&registrationId)
+	registrationPath := "/registrations/" + strconv.FormatInt(registrationId, 10)
+
+	countRegistrations := func(path, token string) int {
+		w := getAs(router, path, token)
+		assert.Equal(t, http.StatusOK, w.Code)
+
+		var response struct {
+			Registrations []struct{ ID int64 } `json:"registrations"`
+		}
+		json.Unmarshal(w.Body.Bytes(), &response)
+		return len(response.Registrations)
+	}
+
+	t.Run("listings require authentication", func(t *testing.T) {
+		assert.Equal(t, http.StatusUnauthorized, getAs(router, "/registrations", "").Code)
+		assert.Equal(t, http.StatusUnauthorized, getAs(router, registrationPath, "").Code)
+	})
+
+	t.Run("listings are limited to the caller", func(t *testing.T) {
+		assert.Equal(t, 2, countRegistrations("/registrations", attendeeToken))
+		assert.Equal(t, 2, countRegistrations("/registrations", organizerToken))
+		assert.Equal(t, 0, countRegistrations("/registrations", strangerToken))
+	})
+
+	t.Run("single registrations", func(t *testing.T) {
+		assert.Equal(t, http.StatusOK, getAs(router, registrationPath, attendeeToken).Code)
+		assert.Equal(t, http.StatusOK, getAs(router, registrationPath, organizerToken).Code)
+		assert.Equal(t, http.StatusNotFound, getAs(router, registrationPath, strangerToken).Code)
+	})
+
+	t.Run("event registrations are for the organizer", func(t *testing.T) {
+		path := "/events/" + strconv.FormatInt(laterId, 10) + "/registrations"
+		assert.Equal(t, 1, countRegistrations(path, organizerToken))
+		assert.Equal(t, http.StatusUnauthorized, getAs(router, path, attendeeToken).Code)
+	})
+
+	t.Run("my registrations embed the event", func(t *testing.T) {
+		w := getAs(router, "/me/registrations", attendeeToken)
+		assert.Equal(t, http.StatusOK, w.Code)
+
+		var response struct {
+			Registrations []struct {
+				EventID int64
+				Event   struct{ ID int64 }
+			} `json:"registrations"`
+		}
+		json.Unmarshal(w.Body.Bytes(), &response)
+		assert.Len(t, response.Registrations, 2)
+		assert.Equal(t, soonerId, response.Registrations[0].Event.ID)
+		assert.Equal(t, laterId, response.Registrations[1].Event.ID)
+	})
+
+	t.Run("my events", func(t *testing.T) {
+		w := getAs(router, "/me/events", organizerToken)
+		assert.Equal(t, http.StatusOK, w.Code)
+
+		var response struct {
+			Events []struct{ ID int64 } `json:"events"`
+		}
+		json.Unmarshal(w.Body.Bytes(), &response)
+		assert.Len(t, response.Events, 2)
+
+		w = getAs(router, "/me/events", strangerToken)
+		json.Unmarshal(w.Body.Bytes(), &response)
+		assert.Empty(t, response.Events)
+	})
+}
@@ -0,0 +1,70 @@
+package routes
+
+import (
+	"database/sql"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/salads-source/go_http_server/models"
+	"github.com/salads-source/go_http_server/utils"
+)
+
+// callerHas reports whether the caller's role grants permission. Anonymous
+// callers have no role.
+func callerHas(context *gin.Context, permission models.Permission) bool {
+	claims, ok := context.Get("tokenClaims")
+
+	if !ok {
+		return false
+	}
+
+	return models.HasPermission(claims.(*utils.TokenClaims).Role, permission)
+}
+
+// canManageEvent reports whether the caller organizes event or may manage
+// every event.
+func canManageEvent(context *gin.Context, event *models.Event) bool {
+	return event.UserID == context.GetInt64("userId") || callerHas(context, models.PermissionManageAnyEvent)
+}
+
+type roleInput struct {
+	Role string `binding:"required"`
+}
+
+func setUserRole(context *gin.Context) {
+	userId, err := strconv.ParseInt(context.Param("id"), 10, 64)
+
+	if err != nil {
+		context.JSON(http.StatusBadRequest, gin.H{"message": "Invalid user Id"})
+		return
+	}
+
+	var input roleInput
+	err = context.ShouldBindJSON(&input)
+
+	if err != nil {
+		context.JSON(http.StatusBadRequest, gin.H{"message": "Could not parse request data."})
+		return
+	}
+
+	err = models.SetUserRole(userId, input.Role)
+
+	if errors.Is(err, models.ErrInvalidRole) {
+		context.JSON(http.StatusBadRequest, gin.H{"message": err.Error()})
+		return
+	}
+
+	if errors.Is(err, sql.ErrNoRows) {
+		context.JSON(http.StatusNotFound, gin.H{"message": "User not found."})
+		return
+	}
+
+	if err != nil {
+		context.JSON(http.StatusInternalServerError, gin.H{"message": "Could not change role, try again later"})
+		return
+	}
+
+	context.JSON(http.StatusOK, gin.H{"message": "Role changed", "role": input.Role})
+}
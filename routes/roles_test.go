@@ -0,0 +1,114 @@
+package routes
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/salads-source/go_http_server/db"
+	"github.com/salads-source/go_http_server/models"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRoles(t *testing.T) {
+	_, router := setupTestRouter(t)
+	adminId := createTestUser(t, db.DB, "roleadmin@example.com", "password123")
+	organizerId := createTestUser(t, db.DB, "roleorganizer@example.com", "password123")
+	attendeeId := createTestUser(t, db.DB, "roleattendee@example.com", "password123")
+	db.DB.Exec("UPDATE users SET role = 'admin' WHERE id = ?", adminId)
+	db.DB.Exec("UPDATE users SET role = 'organizer' WHERE id = ?", organizerId)
+
+	adminToken := generateTestToken(t, "roleadmin@example.com", adminId)
+	organizerToken := generateTestToken(t, "roleorganizer@example.com", organizerId)
+	attendeeToken := generateTestToken(t, "roleattendee@example.com", attendeeId)
+
+	event := map[string]interface{}{
+		"name":        "Role Event",
+		"description": "Testing roles",
+		"location":    "Hall",
+		"dateTime":    time.Now().Add(24 * time.Hour).UTC().Format(time.RFC3339),
+	}
+
+	t.Run("anyone can create events unless creation is restricted", func(t *testing.T) {
+		w := postJSON(t, router, http.MethodPost, "/events", attendeeToken, event)
+		assert.Equal(t, http.StatusCreated, w.Code)
+
+		models.RestrictEventCreation = true
+		t.Cleanup(func() { models.RestrictEventCreation = false })
+
+		w = postJSON(t, router, http.MethodPost, "/events", attendeeToken, event)
+		assert.Equal(t, http.StatusForbidden, w.Code)
+
+		w = postJSON(t, router, http.MethodPost, "/events", organizerToken, event)
+		assert.Equal(t, http.StatusCreated, w.Code)
+
+		w = postJSON(t, router, http.MethodPost, "/events", adminToken, event)
+		assert.Equal(t, http.StatusCreated, w.Code)
+	})
+
+	t.Run("admins manage any event", func(t *testing.T) {
+		eventId := createTestEvent(t, attendeeId)
+		db.DB.Exec("UPDATE events SET visibility = 'private' WHERE id = ?", eventId)
+		path := "/events/" + strconv.FormatInt(eventId, 10)
+
+		assert.Equal(t, http.StatusNotFound, getAs(router, path, organizerToken).Code)
+		assert.Equal(t, http.StatusOK, getAs(router, path, adminToken).Code)
+
+		w := postJSON(t, router, http.MethodPut, path, organizerToken, event)
+		assert.Equal(t, http.StatusUnauthorized, w.Code)
+
+		w = postJSON(t, router, http.MethodPut, path, adminToken, event)
+		assert.Equal(t, http.StatusOK, w.Code)
+
+		assert.Equal(t, http.StatusOK, getAs(router, path+"/registrations", adminToken).Code)
+
+		var ownerId int64
+		db.DB.QueryRow("SELECT user_id FROM events WHERE id = ?", eventId).Scan(&ownerId)
+		assert.Equal(t, attendeeId, ownerId)
+
+		w = postJSON(t, router, http.MethodDelete, path, adminToken, nil)
+		assert.Equal(t, http.StatusOK, w.Code)
+	})
+
+	t.Run("only admins change roles", func(t *testing.T) {
+		rolePath := "/users/" + strconv.FormatInt(attendeeId, 10) + "/role"
+
+		w := postJSON(t, router, http.MethodPut, rolePath, organizerToken, map[string]interface{}{"role": "admin"})
+		assert.Equal(t, http.StatusForbidden, w.Code)
+
+		w = postJSON(t, router, http.MethodPut, rolePath, adminToken, map[string]interface{}{"role": "superuser"})
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+
+		w = postJSON(t, router, http.MethodPut, "/users/9999/role", adminToken, map[string]interface{}{"role": "organizer"})
+		assert.Equal(t, http.StatusNotFound, w.Code)
+
+		w = postJSON(t, router, http.MethodPut, rolePath, adminToken, map[string]interface{}{"role": "organizer"})
+		assert.Equal(t, http.StatusOK, w.Code)
+
+		// The old token still says attendee, so it stops working.
+		assert.Equal(t, http.StatusUnauthorized, getAs(router, "/me/sessions", attendeeToken).Code)
+
+		models.RestrictEventCreation = true
+		t.Cleanup(func() { models.RestrictEventCreation = false })
+
+		w = postJSON(t, router, http.MethodPost, "/login", "", map[string]interface{}{"email": "roleattendee@example.com", "password": "password123"})
+		assert.Equal(t, http.StatusCreated, w.Code)
+
+		var session sessionResponse
+		json.Unmarshal(w.Body.Bytes(), &session)
+
+		w = postJSON(t, router, http.MethodPost, "/events", session.Token, event)
+		assert.Equal(t, http.StatusCreated, w.Code)
+	})
+
+	t.Run("signing up cannot pick a role", func(t *testing.T) {
+		w := postJSON(t, router, http.MethodPost, "/signup", "", map[string]interface{}{"email": "rolesignup@example.com", "password": "password123", "role": "admin"})
+		assert.Equal(t, http.StatusCreated, w.Code)
+
+		var role string
+		db.DB.QueryRow("SELECT role FROM users WHERE email = 'rolesignup@example.com'").Scan(&role)
+		assert.Equal(t, "attendee", role)
+	})
+}
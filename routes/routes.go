@@ -1,24 +1,119 @@
 package routes
 
 import (
+	"sync"
+
 	"github.com/gin-gonic/gin"
 	"github.com/salads-source/go_http_server/middlewares"
+	"github.com/salads-source/go_http_server/models"
 )
 
 func RegisterRoutes(server *gin.Engine) {
-	server.GET("/events", getEvents)
-	server.GET("/events/:id", getEvent)
-	server.GET("/registrations", getRegistrations)
-	server.GET("/registrations/:id", getRegistration)
+	// Event reads are open to anonymous callers, but what they can see
+	// depends on who is asking.
+	optional := server.Group("/")
+	optional.Use(middlewares.OptionalAuthenticate)
+	optional.GET("/events", getEvents)
+	optional.GET("/events/:id", getEvent)
+	optional.GET("/events/:id/attachments", getAttachments)
+	optional.GET("/events/:id/comments", getComments)
+	optional.GET("/events/:id/questions", getQuestions)
+	optional.GET("/events/:id/history", getEventHistory)
+	optional.GET("/events/:id/history/diff", diffEventVersions)
+	optional.GET("/events/:id/history/:version", getEventVersion)
+	optional.GET("/attachments/:id", serveAttachment)
+	optional.GET("/attachments/:id/thumbnail", serveThumbnail)
+
+	server.GET("/venues", getVenues)
+	server.GET("/venues/:id", getVenue)
+	server.GET("/venues/:id/availability", getVenueAvailability)
 
 	authenticated := server.Group("/")
 	authenticated.Use(middlewares.Authenticate)
-	authenticated.POST("/events", createEvent)
+	authenticated.POST("/events", middlewares.RequirePermission(models.PermissionCreateEvents),
+		middlewares.RequireVerifiedEmail(models.VerifiedActionCreateEvents), createEvent)
+	authenticated.POST("/events/import", middlewares.RequirePermission(models.PermissionCreateEvents),
+		middlewares.RequireVerifiedEmail(models.VerifiedActionCreateEvents), importEvents)
+	authenticated.GET("/events/export", exportEvents)
+	authenticated.GET("/registrations", getRegistrations)
+	authenticated.GET("/registrations/export", exportRegistrations)
+	authenticated.GET("/registrations/:id", getRegistration)
+	authenticated.GET("/me", getMe)
+	authenticated.PATCH("/me", updateMe)
+	authenticated.DELETE("/me", deleteMe)
+	authenticated.GET("/me/export", exportMe)
+	authenticated.GET("/me/registrations", getMyRegistrations)
+	authenticated.GET("/me/events", getMyEvents)
+	authenticated.GET("/me/sessions", getMySessions)
+	authenticated.GET("/me/logins", getMyLogins)
+	authenticated.DELETE("/me/sessions/:id", revokeSession)
+	authenticated.PUT("/me/password", changePassword)
+	authenticated.POST("/logout", logout)
+	authenticated.PUT("/users/:id/role", middlewares.RequirePermission(models.PermissionManageUsers), setUserRole)
 	authenticated.PUT("/events/:id", updateEvent)
 	authenticated.DELETE("/events/:id", deleteEvent)
-	authenticated.POST("/events/:id/register", registerForEvent)
+	authenticated.GET("/events/:id/register", getOwnRegistration)
+	authenticated.POST("/events/:id/register", middlewares.RequireVerifiedEmail(models.VerifiedActionRegister), registerForEvent)
 	authenticated.DELETE("/events/:id/register", cancelRegistration)
+	authenticated.POST("/events/:id/register/guests", addGuest)
+	authenticated.DELETE("/events/:id/register/guests/:guestId", removeGuest)
+	authenticated.POST("/events/:id/register/transfer", offerTransfer)
+	authenticated.DELETE("/events/:id/register/transfer", cancelTransfer)
+	authenticated.GET("/events/:id/transfers", getEventTransfers)
+	authenticated.GET("/me/transfers", getMyTransfers)
+	authenticated.POST("/transfers/:id/accept", middlewares.RequireVerifiedEmail(models.VerifiedActionRegister), acceptTransfer)
+	authenticated.POST("/transfers/:id/decline", declineTransfer)
+	authenticated.GET("/events/:id/registrations", getEventRegistrations)
+	authenticated.POST("/events/:id/attendees", addAttendee)
+	authenticated.POST("/events/:id/attendees/import", importAttendees)
+	authenticated.DELETE("/events/:id/attendees/:email", removeAttendee)
+	authenticated.POST("/events/:id/registrations/:registrationId/approve", approveRegistration)
+	authenticated.POST("/events/:id/registrations/:registrationId/reject", rejectRegistration)
+	authenticated.POST("/events/:id/attachments", uploadAttachment)
+	authenticated.DELETE("/events/:id/attachments/:attachmentId", deleteAttachment)
+	authenticated.POST("/events/:id/comments", createComment)
+	authenticated.PUT("/events/:id/comments/:commentId", updateComment)
+	authenticated.DELETE("/events/:id/comments/:commentId", deleteComment)
+	authenticated.POST("/events/:id/check-in/:userId", checkInAttendee)
+	authenticated.POST("/events/:id/feedback", submitFeedback)
+	authenticated.GET("/events/:id/feedback", getEventFeedback)
+	authenticated.GET("/me/events/feedback", getOrganizerFeedback)
+	authenticated.GET("/events/:id/invitations", getInvitations)
+	authenticated.POST("/events/:id/invitations", createInvitations)
+	authenticated.DELETE("/events/:id/invitations/:invitationId", deleteInvitation)
+	authenticated.GET("/events/:id/invite-codes", getInviteCodes)
+	authenticated.POST("/events/:id/invite-codes", createInviteCode)
+	authenticated.DELETE("/events/:id/invite-codes/:codeId", revokeInviteCode)
+	authenticated.PUT("/events/:id/questions", setQuestions)
+	authenticated.GET("/events/:id/answers", getAnswers)
+	authenticated.GET("/events/:id/answers/export", exportAnswers)
+	authenticated.POST("/venues", createVenue)
+	authenticated.PUT("/venues/:id", updateVenue)
+	authenticated.DELETE("/venues/:id", deleteVenue)
+	authenticated.POST("/venues/:id/rooms", createRoom)
+	authenticated.PUT("/venues/:id/rooms/:roomId", updateRoom)
+	authenticated.DELETE("/venues/:id/rooms/:roomId", deleteRoom)
 
 	server.POST("/signup", signup)
 	server.POST("/login", login)
+	server.POST("/login/unlock", unlockAccount)
+	server.POST("/refresh", refresh)
+	server.POST("/password/forgot", forgotPassword)
+	server.POST("/password/reset", resetPassword)
+	server.POST("/verify-email", verifyEmail)
+	server.POST("/verify-email/resend", resendVerification)
+	server.GET("/.well-known/jwks.json", getJWKS)
+}
+
+// background tracks work handlers leave running after they respond, such as
+// sending emails, so it can be waited for.
+var background sync.WaitGroup
+
+func inBackground(work func()) {
+	background.Add(1)
+
+	go func() {
+		defer background.Done()
+		work()
+	}()
 }
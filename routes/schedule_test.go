@@ -0,0 +1,185 @@
+package routes
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/salads-source/go_http_server/db"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEventSchedule(t *testing.T) {
+	_, router := setupTestRouter(t)
+	organizerId := createTestUser(t, db.DB, "scheduleorganizer@example.com", "password123")
+	organizerToken := generateTestToken(t, "scheduleorganizer@example.com", organizerId)
+
+	start := time.Date(2030, 5, 10, 18, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name           string
+		fields         map[string]interface{}
+		expectedStatus int
+		expectedEnd    time.Time
+	}{
+		{
+			name:           "end time",
+			fields:         map[string]interface{}{"endDateTime": start.Add(2 * time.Hour)},
+			expectedStatus: http.StatusCreated,
+			expectedEnd:    start.Add(2 * time.Hour),
+		},
+		{
+			name:           "duration",
+			fields:         map[string]interface{}{"durationMinutes": 90},
+			expectedStatus: http.StatusCreated,
+			expectedEnd:    start.Add(90 * time.Minute),
+		},
+		{
+			name:           "all day",
+			fields:         map[string]interface{}{"allDay": true},
+			expectedStatus: http.StatusCreated,
+			expectedEnd:    time.Date(2030, 5, 11, 0, 0, 0, 0, time.UTC),
+		},
+		{
+			name:           "all day over a weekend",
+			fields:         map[string]interface{}{"allDay": true, "endDateTime": time.Date(2030, 5, 11, 9, 0, 0, 0, time.UTC)},
+			expectedStatus: http.StatusCreated,
+			expectedEnd:    time.Date(2030, 5, 12, 0, 0, 0, 0, time.UTC),
+		},
+		{
+			name:           "end before start",
+			fields:         map[string]interface{}{"endDateTime": start.Add(-time.Hour)},
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name:           "end and duration disagree",
+			fields:         map[string]interface{}{"endDateTime": start.Add(time.Hour), "durationMinutes": 30},
+			expectedStatus: http.StatusBadRequest,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			payload := map[string]interface{}{
+				"name":        "Scheduled",
+				"description": "Description",
+				"location":    "Location",
+				"dateTime":    start,
+			}
+			for key, value := range tt.fields {
+				payload[key] = value
+			}
+
+			w := postJSON(t, router, http.MethodPost, "/events", organizerToken, payload)
+			assert.Equal(t, tt.expectedStatus, w.Code)
+
+			if tt.expectedStatus != http.StatusCreated {
+				return
+			}
+
+			var response struct {
+				Event struct{ ID int64 } `json:"event"`
+			}
+			json.Unmarshal(w.Body.Bytes(), &response)
+
+			w = getAs(router, "/events/"+strconv.FormatInt(response.Event.ID, 10), "")
+			var event struct {
+				EndDateTime     time.Time
+				DurationMinutes int
+			}
+			json.Unmarshal(w.Body.Bytes(), &event)
+			assert.True(t, tt.expectedEnd.Equal(event.EndDateTime), "end was %v", event.EndDateTime)
+			assert.Positive(t, event.DurationMinutes)
+		})
+	}
+}
+
+func TestEventScheduleRoundTrip(t *testing.T) {
+	_, router := setupTestRouter(t)
+	organizerId := createTestUser(t, db.DB, "roundtriporganizer@example.com", "password123")
+	organizerToken := generateTestToken(t, "roundtriporganizer@example.com", organizerId)
+
+	// An end that isn't on a whole minute is reported with a truncated
+	// duration, and the two can be sent back unchanged.
+	start := time.Date(2030, 5, 10, 18, 0, 0, 0, time.UTC)
+	w := postJSON(t, router, http.MethodPost, "/events", organizerToken, map[string]interface{}{
+		"name":        "Scheduled",
+		"description": "Description",
+		"location":    "Location",
+		"dateTime":    start,
+		"endDateTime": start.Add(90*time.Minute + 30*time.Second),
+	})
+	assert.Equal(t, http.StatusCreated, w.Code)
+
+	var created struct {
+		Event struct{ ID int64 } `json:"event"`
+	}
+	json.Unmarshal(w.Body.Bytes(), &created)
+	path := "/events/" + strconv.FormatInt(created.Event.ID, 10)
+
+	w = getAs(router, path, organizerToken)
+	var event map[string]interface{}
+	json.Unmarshal(w.Body.Bytes(), &event)
+	assert.EqualValues(t, 90, event["DurationMinutes"])
+
+	w = postJSON(t, router, http.MethodPut, path, organizerToken, event)
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	w = postJSON(t, router, http.MethodPut, path, organizerToken, map[string]interface{}{
+		"name":            "Scheduled",
+		"description":     "Description",
+		"location":        "Location",
+		"dateTime":        start,
+		"endDateTime":     start.Add(90*time.Minute + 30*time.Second),
+		"durationMinutes": 91,
+	})
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+}
+
+func TestRegistrationConflicts(t *testing.T) {
+	_, router := setupTestRouter(t)
+	organizerId := createTestUser(t, db.DB, "conflictorganizer@example.com", "password123")
+	attendeeId := createTestUser(t, db.DB, "conflictattendee@example.com", "password123")
+	attendeeToken := generateTestToken(t, "conflictattendee@example.com", attendeeId)
+
+	start := time.Date(2030, 6, 1, 9, 0, 0, 0, time.UTC)
+	createScheduled := func(startsAt, endsAt time.Time) string {
+		eventId := createTestEvent(t, organizerId)
+		db.DB.Exec("UPDATE events SET dateTime = ?, end_dateTime = ? WHERE id = ?", startsAt, endsAt, eventId)
+		return "/events/" + strconv.FormatInt(eventId, 10) + "/register"
+	}
+
+	morning := createScheduled(start, start.Add(3*time.Hour))
+	overlapping := createScheduled(start.Add(2*time.Hour), start.Add(4*time.Hour))
+	afterwards := createScheduled(start.Add(3*time.Hour), start.Add(5*time.Hour))
+
+	w := postJSON(t, router, http.MethodPost, morning, attendeeToken, nil)
+	assert.Equal(t, http.StatusCreated, w.Code)
+
+	tests := []struct {
+		name           string
+		path           string
+		expectedStatus int
+		conflicts      int
+	}{
+		{"overlap is rejected", overlapping, http.StatusConflict, 1},
+		{"invalid option", overlapping + "?allow_conflicts=maybe", http.StatusBadRequest, 0},
+		{"back to back is not a conflict", afterwards, http.StatusCreated, 0},
+		{"overlap is allowed on request", overlapping + "?allow_conflicts=true", http.StatusCreated, 2},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := postJSON(t, router, http.MethodPost, tt.path, attendeeToken, nil)
+			assert.Equal(t, tt.expectedStatus, w.Code)
+
+			var response struct {
+				Conflicts []struct{ ID int64 } `json:"conflicts"`
+			}
+			json.Unmarshal(w.Body.Bytes(), &response)
+			assert.Len(t, response.Conflicts, tt.conflicts)
+		})
+	}
+}
@@ -0,0 +1,101 @@
+package routes
+
+import (
+	"database/sql"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/salads-source/go_http_server/models"
+	"github.com/salads-source/go_http_server/utils"
+)
+
+type refreshInput struct {
+	RefreshToken string `binding:"required"`
+}
+
+// refresh trades a refresh token for a new access token and a new refresh
+// token. The old refresh token stops working.
+func refresh(context *gin.Context) {
+	var input refreshInput
+	err := context.ShouldBindJSON(&input)
+
+	if err != nil {
+		context.JSON(http.StatusBadRequest, gin.H{"message": "Could not parse request"})
+		return
+	}
+
+	session, refreshToken, err := models.RotateRefreshToken(input.RefreshToken)
+
+	if errors.Is(err, models.ErrRefreshTokenReused) {
+		context.JSON(http.StatusUnauthorized, gin.H{"message": "Refresh token was already used, the session has been signed out."})
+		return
+	}
+
+	if errors.Is(err, models.ErrInvalidRefreshToken) {
+		context.JSON(http.StatusUnauthorized, gin.H{"message": "Invalid refresh token."})
+		return
+	}
+
+	if err != nil {
+		context.JSON(http.StatusInternalServerError, gin.H{"message": "Could not refresh session, try again later"})
+		return
+	}
+
+	user, err := models.GetUserByID(session.UserID)
+
+	if err != nil {
+		context.JSON(http.StatusInternalServerError, gin.H{"message": "Could not refresh session, try again later"})
+		return
+	}
+
+	token, err := utils.GenerateSessionToken(user.Email, user.ID, user.Role, session.ID)
+
+	if err != nil {
+		context.JSON(http.StatusInternalServerError, gin.H{"message": "Could not refresh session, try again later"})
+		return
+	}
+
+	context.JSON(http.StatusOK, gin.H{
+		"message":      "Session refreshed",
+		"token":        token,
+		"expiresIn":    int(utils.AccessTokenLifetime.Seconds()),
+		"refreshToken": refreshToken,
+		"sessionId":    session.ID,
+	})
+}
+
+func getMySessions(context *gin.Context) {
+	sessions, err := models.GetSessions(context.GetInt64("userId"))
+
+	if err != nil {
+		context.JSON(http.StatusInternalServerError, gin.H{"message": "Could not fetch sessions, try again later"})
+		return
+	}
+
+	context.JSON(http.StatusOK, gin.H{"message": "Sessions fetched successfully", "sessions": sessions})
+}
+
+func revokeSession(context *gin.Context) {
+	sessionId, err := strconv.ParseInt(context.Param("id"), 10, 64)
+
+	if err != nil {
+		context.JSON(http.StatusBadRequest, gin.H{"message": "Invalid session Id"})
+		return
+	}
+
+	err = models.RevokeSession(context.GetInt64("userId"), sessionId)
+
+	if errors.Is(err, sql.ErrNoRows) {
+		context.JSON(http.StatusNotFound, gin.H{"message": "Session not found."})
+		return
+	}
+
+	if err != nil {
+		context.JSON(http.StatusInternalServerError, gin.H{"message": "Could not sign out session, try again later"})
+		return
+	}
+
+	context.JSON(http.StatusOK, gin.H{"message": "Session signed out"})
+}
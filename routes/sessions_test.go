@@ -0,0 +1,112 @@
+package routes
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"testing"
+
+	"github.com/salads-source/go_http_server/db"
+	"github.com/stretchr/testify/assert"
+)
+
+type sessionResponse struct {
+	Token        string `json:"token"`
+	RefreshToken string `json:"refreshToken"`
+	SessionID    int64  `json:"sessionId"`
+}
+
+func TestSessions(t *testing.T) {
+	_, router := setupTestRouter(t)
+	createTestUser(t, db.DB, "sessions@example.com", "password123")
+	otherId := createTestUser(t, db.DB, "othersessions@example.com", "password123")
+	otherToken := generateTestToken(t, "othersessions@example.com", otherId)
+
+	login := func() sessionResponse {
+		w := postJSON(t, router, http.MethodPost, "/login", "", map[string]interface{}{"email": "sessions@example.com", "password": "password123"})
+		assert.Equal(t, http.StatusCreated, w.Code)
+
+		var response sessionResponse
+		json.Unmarshal(w.Body.Bytes(), &response)
+		assert.NotEmpty(t, response.Token)
+		assert.NotEmpty(t, response.RefreshToken)
+		return response
+	}
+
+	refresh := func(refreshToken string) (int, sessionResponse) {
+		w := postJSON(t, router, http.MethodPost, "/refresh", "", map[string]interface{}{"refreshToken": refreshToken})
+
+		var response sessionResponse
+		json.Unmarshal(w.Body.Bytes(), &response)
+		return w.Code, response
+	}
+
+	sessionIds := func(token string) []int64 {
+		w := getAs(router, "/me/sessions", token)
+		assert.Equal(t, http.StatusOK, w.Code)
+
+		var response struct {
+			Sessions []struct{ ID int64 } `json:"sessions"`
+		}
+		json.Unmarshal(w.Body.Bytes(), &response)
+
+		ids := []int64{}
+		for _, session := range response.Sessions {
+			ids = append(ids, session.ID)
+		}
+		return ids
+	}
+
+	t.Run("refresh tokens rotate", func(t *testing.T) {
+		first := login()
+
+		code, second := refresh(first.RefreshToken)
+		assert.Equal(t, http.StatusOK, code)
+		assert.NotEqual(t, first.RefreshToken, second.RefreshToken)
+		assert.Equal(t, first.SessionID, second.SessionID)
+		assert.Equal(t, http.StatusOK, getAs(router, "/me/sessions", second.Token).Code)
+
+		code, _ = refresh(second.RefreshToken)
+		assert.Equal(t, http.StatusOK, code)
+	})
+
+	t.Run("reusing a refresh token revokes the session", func(t *testing.T) {
+		first := login()
+
+		code, second := refresh(first.RefreshToken)
+		assert.Equal(t, http.StatusOK, code)
+
+		code, _ = refresh(first.RefreshToken)
+		assert.Equal(t, http.StatusUnauthorized, code)
+
+		code, _ = refresh(second.RefreshToken)
+		assert.Equal(t, http.StatusUnauthorized, code)
+		assert.Equal(t, http.StatusUnauthorized, getAs(router, "/me/sessions", second.Token).Code)
+		assert.NotContains(t, sessionIds(login().Token), first.SessionID)
+	})
+
+	t.Run("unknown refresh tokens are rejected", func(t *testing.T) {
+		code, _ := refresh("not-a-token")
+		assert.Equal(t, http.StatusUnauthorized, code)
+	})
+
+	t.Run("users sign out their own sessions", func(t *testing.T) {
+		current := login()
+		device := login()
+		assert.Contains(t, sessionIds(current.Token), device.SessionID)
+
+		devicePath := "/me/sessions/" + strconv.FormatInt(device.SessionID, 10)
+		w := postJSON(t, router, http.MethodDelete, devicePath, otherToken, nil)
+		assert.Equal(t, http.StatusNotFound, w.Code)
+
+		w = postJSON(t, router, http.MethodDelete, devicePath, current.Token, nil)
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.NotContains(t, sessionIds(current.Token), device.SessionID)
+
+		code, _ := refresh(device.RefreshToken)
+		assert.Equal(t, http.StatusUnauthorized, code)
+
+		w = postJSON(t, router, http.MethodDelete, devicePath, current.Token, nil)
+		assert.Equal(t, http.StatusNotFound, w.Code)
+	})
+}
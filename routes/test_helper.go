@@ -1,12 +1,20 @@
 package routes
 
 import (
+	"bytes"
 	"database/sql"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
 	"testing"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	_ "github.com/mattn/go-sqlite3"
 	"github.com/salads-source/go_http_server/db"
+	"github.com/salads-source/go_http_server/mail"
+	"github.com/salads-source/go_http_server/storage"
 	"github.com/salads-source/go_http_server/utils"
 )
 
@@ -17,12 +25,26 @@ func setupTestDB(t *testing.T) *sql.DB {
 		t.Fatalf("Failed to open test database: %v", err)
 	}
 
+	// Every connection to :memory: gets its own empty database, so keep
+	// transactions and plain queries on the same one.
+	testDB.SetMaxOpenConns(1)
+
 	// Create tables
 	createUsersTable := `
 	CREATE TABLE IF NOT EXISTS users (
 		id INTEGER PRIMARY KEY AUTOINCREMENT,
 		email TEXT NOT NULL UNIQUE,
-		password TEXT NOT NULL
+		password TEXT NOT NULL,
+		placeholder INTEGER NOT NULL DEFAULT 0,
+		tokens_valid_after DATETIME,
+		role TEXT NOT NULL DEFAULT 'attendee',
+		email_verified INTEGER NOT NULL DEFAULT 1,
+		display_name TEXT NOT NULL DEFAULT '',
+		bio TEXT NOT NULL DEFAULT '',
+		avatar_url TEXT NOT NULL DEFAULT '',
+		time_zone TEXT NOT NULL DEFAULT '',
+		locale TEXT NOT NULL DEFAULT '',
+		deleted_at DATETIME
 	);`
 
 	createEventsTable := `
@@ -32,7 +54,19 @@ func setupTestDB(t *testing.T) *sql.DB {
 		description TEXT NOT NULL,
 		location TEXT NOT NULL,
 		dateTime DATETIME NOT NULL,
+		end_dateTime DATETIME,
+		all_day INTEGER NOT NULL DEFAULT 0,
+		venue_id INTEGER REFERENCES venues(id),
+		room_id INTEGER REFERENCES venue_rooms(id),
+		capacity INTEGER NOT NULL DEFAULT 0,
+		max_guests INTEGER NOT NULL DEFAULT 0,
+		cancellation_cutoff_minutes INTEGER NOT NULL DEFAULT 0,
+		requires_approval INTEGER NOT NULL DEFAULT 0,
+		allow_transfers INTEGER NOT NULL DEFAULT 0,
 		user_id INTEGER,
+		visibility TEXT NOT NULL DEFAULT 'public',
+		comments_registered_only INTEGER NOT NULL DEFAULT 0,
+		feedback_checked_in_only INTEGER NOT NULL DEFAULT 0,
 		FOREIGN KEY(user_id) REFERENCES users(id)
 	);`
 
@@ -41,10 +75,219 @@ func setupTestDB(t *testing.T) *sql.DB {
 		id INTEGER PRIMARY KEY AUTOINCREMENT,
 		event_id INTEGER,
 		user_id INTEGER,
+		checked_in_at DATETIME,
+		answers TEXT NOT NULL DEFAULT '{}',
+		status TEXT NOT NULL DEFAULT 'approved',
+		status_reason TEXT NOT NULL DEFAULT '',
+		decided_at DATETIME,
+		FOREIGN KEY(event_id) REFERENCES events(id),
+		FOREIGN KEY(user_id) REFERENCES users(id)
+	);`
+
+	createAttachmentsTable := `
+	CREATE TABLE IF NOT EXISTS attachments (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		event_id INTEGER NOT NULL,
+		kind TEXT NOT NULL,
+		filename TEXT NOT NULL,
+		content_type TEXT NOT NULL,
+		size INTEGER NOT NULL,
+		storage_key TEXT NOT NULL,
+		thumbnail_key TEXT NOT NULL DEFAULT '',
+		thumbnail_content_type TEXT NOT NULL DEFAULT '',
+		user_id INTEGER,
+		created_at DATETIME NOT NULL,
+		FOREIGN KEY(event_id) REFERENCES events(id),
+		FOREIGN KEY(user_id) REFERENCES users(id)
+	);`
+
+	createCommentsTable := `
+	CREATE TABLE IF NOT EXISTS comments (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		event_id INTEGER NOT NULL,
+		user_id INTEGER NOT NULL,
+		parent_id INTEGER,
+		root_id INTEGER NOT NULL DEFAULT 0,
+		body TEXT NOT NULL,
+		status TEXT NOT NULL DEFAULT 'visible',
+		created_at DATETIME NOT NULL,
+		updated_at DATETIME,
+		FOREIGN KEY(event_id) REFERENCES events(id),
+		FOREIGN KEY(user_id) REFERENCES users(id),
+		FOREIGN KEY(parent_id) REFERENCES comments(id)
+	);`
+
+	createFeedbackTable := `
+	CREATE TABLE IF NOT EXISTS feedback (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		event_id INTEGER NOT NULL,
+		user_id INTEGER NOT NULL,
+		rating INTEGER NOT NULL CHECK (rating BETWEEN 1 AND 5),
+		review TEXT NOT NULL DEFAULT '',
+		created_at DATETIME NOT NULL,
+		UNIQUE(event_id, user_id),
 		FOREIGN KEY(event_id) REFERENCES events(id),
 		FOREIGN KEY(user_id) REFERENCES users(id)
 	);`
 
+	createInvitationTables := `
+	CREATE TABLE IF NOT EXISTS event_invitations (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		event_id INTEGER NOT NULL,
+		email TEXT NOT NULL,
+		created_at DATETIME NOT NULL,
+		UNIQUE(event_id, email),
+		FOREIGN KEY(event_id) REFERENCES events(id)
+	);
+	CREATE TABLE IF NOT EXISTS invite_codes (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		event_id INTEGER NOT NULL,
+		code_hash TEXT NOT NULL UNIQUE,
+		expires_at DATETIME,
+		revoked_at DATETIME,
+		created_at DATETIME NOT NULL,
+		FOREIGN KEY(event_id) REFERENCES events(id)
+	);`
+
+	createEventVersionsTable := `
+	CREATE TABLE IF NOT EXISTS event_versions (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		event_id INTEGER NOT NULL,
+		version INTEGER NOT NULL,
+		action TEXT NOT NULL,
+		actor_id INTEGER NOT NULL,
+		snapshot TEXT NOT NULL,
+		created_at DATETIME NOT NULL,
+		UNIQUE(event_id, version)
+	);`
+
+	createVenueTables := `
+	CREATE TABLE IF NOT EXISTS venues (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		name TEXT NOT NULL,
+		address TEXT NOT NULL,
+		capacity INTEGER NOT NULL DEFAULT 0,
+		user_id INTEGER NOT NULL,
+		created_at DATETIME NOT NULL,
+		FOREIGN KEY(user_id) REFERENCES users(id)
+	);
+	CREATE TABLE IF NOT EXISTS venue_rooms (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		venue_id INTEGER NOT NULL,
+		name TEXT NOT NULL,
+		capacity INTEGER NOT NULL DEFAULT 0,
+		FOREIGN KEY(venue_id) REFERENCES venues(id)
+	);`
+
+	createQuestionsTable := `
+	CREATE TABLE IF NOT EXISTS registration_questions (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		event_id INTEGER NOT NULL,
+		position INTEGER NOT NULL,
+		key TEXT NOT NULL,
+		label TEXT NOT NULL,
+		type TEXT NOT NULL,
+		required INTEGER NOT NULL DEFAULT 0,
+		options TEXT NOT NULL DEFAULT '[]',
+		min REAL,
+		max REAL,
+		show_if TEXT,
+		UNIQUE(event_id, key),
+		FOREIGN KEY(event_id) REFERENCES events(id)
+	);`
+
+	createGuestsTable := `
+	CREATE TABLE IF NOT EXISTS registration_guests (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		registration_id INTEGER NOT NULL,
+		name TEXT NOT NULL,
+		email TEXT NOT NULL DEFAULT '',
+		created_at DATETIME NOT NULL,
+		FOREIGN KEY(registration_id) REFERENCES registrations(id)
+	);`
+
+	createTransfersTable := `
+	CREATE TABLE IF NOT EXISTS registration_transfers (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		registration_id INTEGER NOT NULL,
+		event_id INTEGER NOT NULL,
+		from_user_id INTEGER NOT NULL,
+		to_email TEXT NOT NULL,
+		to_user_id INTEGER,
+		status TEXT NOT NULL,
+		created_at DATETIME NOT NULL,
+		responded_at DATETIME
+	);`
+
+	createSessionTables := `
+	CREATE TABLE IF NOT EXISTS sessions (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		user_id INTEGER NOT NULL,
+		user_agent TEXT NOT NULL DEFAULT '',
+		ip_address TEXT NOT NULL DEFAULT '',
+		created_at DATETIME NOT NULL,
+		last_used_at DATETIME NOT NULL,
+		revoked_at DATETIME,
+		FOREIGN KEY(user_id) REFERENCES users(id)
+	);
+
+	CREATE TABLE IF NOT EXISTS refresh_tokens (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		session_id INTEGER NOT NULL,
+		token_hash TEXT NOT NULL UNIQUE,
+		created_at DATETIME NOT NULL,
+		expires_at DATETIME NOT NULL,
+		used_at DATETIME,
+		FOREIGN KEY(session_id) REFERENCES sessions(id)
+	);
+
+	CREATE TABLE IF NOT EXISTS revoked_tokens (
+		jti TEXT PRIMARY KEY,
+		expires_at DATETIME NOT NULL
+	);`
+
+	createEmailVerificationsTable := `
+	CREATE TABLE IF NOT EXISTS email_verifications (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		user_id INTEGER NOT NULL,
+		token_hash TEXT NOT NULL UNIQUE,
+		created_at DATETIME NOT NULL,
+		expires_at DATETIME NOT NULL,
+		used_at DATETIME,
+		FOREIGN KEY(user_id) REFERENCES users(id)
+	);`
+
+	createPasswordResetsTable := `
+	CREATE TABLE IF NOT EXISTS password_resets (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		user_id INTEGER NOT NULL,
+		token_hash TEXT NOT NULL UNIQUE,
+		created_at DATETIME NOT NULL,
+		expires_at DATETIME NOT NULL,
+		used_at DATETIME,
+		FOREIGN KEY(user_id) REFERENCES users(id)
+	);`
+
+	createLoginAttemptsTable := `
+	CREATE TABLE IF NOT EXISTS login_attempts (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		user_id INTEGER,
+		email TEXT NOT NULL,
+		ip_address TEXT NOT NULL DEFAULT '',
+		user_agent TEXT NOT NULL DEFAULT '',
+		outcome TEXT NOT NULL,
+		created_at DATETIME NOT NULL
+	);
+	CREATE TABLE IF NOT EXISTS account_unlocks (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		user_id INTEGER NOT NULL,
+		token_hash TEXT NOT NULL UNIQUE,
+		created_at DATETIME NOT NULL,
+		expires_at DATETIME NOT NULL,
+		used_at DATETIME,
+		FOREIGN KEY(user_id) REFERENCES users(id)
+	);`
+
 	if _, err := testDB.Exec(createUsersTable); err != nil {
 		t.Fatalf("Failed to create users table: %v", err)
 	}
@@ -57,6 +300,58 @@ func setupTestDB(t *testing.T) *sql.DB {
 		t.Fatalf("Failed to create registrations table: %v", err)
 	}
 
+	if _, err := testDB.Exec(createAttachmentsTable); err != nil {
+		t.Fatalf("Failed to create attachments table: %v", err)
+	}
+
+	if _, err := testDB.Exec(createCommentsTable); err != nil {
+		t.Fatalf("Failed to create comments table: %v", err)
+	}
+
+	if _, err := testDB.Exec(createFeedbackTable); err != nil {
+		t.Fatalf("Failed to create feedback table: %v", err)
+	}
+
+	if _, err := testDB.Exec(createInvitationTables); err != nil {
+		t.Fatalf("Failed to create invitation tables: %v", err)
+	}
+
+	if _, err := testDB.Exec(createEventVersionsTable); err != nil {
+		t.Fatalf("Failed to create event versions table: %v", err)
+	}
+
+	if _, err := testDB.Exec(createVenueTables); err != nil {
+		t.Fatalf("Failed to create venue tables: %v", err)
+	}
+
+	if _, err := testDB.Exec(createQuestionsTable); err != nil {
+		t.Fatalf("Failed to create registration questions table: %v", err)
+	}
+
+	if _, err := testDB.Exec(createGuestsTable); err != nil {
+		t.Fatalf("Failed to create registration guests table: %v", err)
+	}
+
+	if _, err := testDB.Exec(createTransfersTable); err != nil {
+		t.Fatalf("Failed to create registration transfers table: %v", err)
+	}
+
+	if _, err := testDB.Exec(createSessionTables); err != nil {
+		t.Fatalf("Failed to create session tables: %v", err)
+	}
+
+	if _, err := testDB.Exec(createPasswordResetsTable); err != nil {
+		t.Fatalf("Failed to create password resets table: %v", err)
+	}
+
+	if _, err := testDB.Exec(createEmailVerificationsTable); err != nil {
+		t.Fatalf("Failed to create email verifications table: %v", err)
+	}
+
+	if _, err := testDB.Exec(createLoginAttemptsTable); err != nil {
+		t.Fatalf("Failed to create login attempts tables: %v", err)
+	}
+
 	return testDB
 }
 
@@ -68,10 +363,45 @@ func setupTestRouter(t *testing.T) (*sql.DB, *gin.Engine) {
 	originalDB := db.DB
 	db.DB = testDB
 	t.Cleanup(func() {
+		// Emails sent in the background still use the database.
+		background.Wait()
 		testDB.Close()
 		db.DB = originalDB
 	})
 
+	// Keep uploaded blobs in a per-test directory
+	store, err := storage.NewLocalStore(t.TempDir())
+	if err != nil {
+		t.Fatalf("Failed to create test blob store: %v", err)
+	}
+	originalStore := storage.Store
+	storage.Store = store
+	t.Cleanup(func() {
+		storage.Store = originalStore
+	})
+
+	// Sign tokens with a throwaway key
+	key, err := utils.NewEd25519Key("test")
+	if err != nil {
+		t.Fatalf("Failed to generate signing key: %v", err)
+	}
+	keys, err := utils.NewKeySet(key)
+	if err != nil {
+		t.Fatalf("Failed to create key set: %v", err)
+	}
+	originalKeys := utils.Keys
+	utils.Keys = keys
+	t.Cleanup(func() {
+		utils.Keys = originalKeys
+	})
+
+	// Drop outgoing email unless a test installs its own mailer
+	originalMailer := mail.Default
+	mail.Default = mail.NewWriterMailer(io.Discard)
+	t.Cleanup(func() {
+		mail.Default = originalMailer
+	})
+
 	gin.SetMode(gin.TestMode)
 	router := gin.New()
 	RegisterRoutes(router)
@@ -81,7 +411,11 @@ func setupTestRouter(t *testing.T) (*sql.DB, *gin.Engine) {
 
 // generateTestToken creates a JWT token for testing
 func generateTestToken(t *testing.T, email string, userId int64) string {
-	token, err := utils.GenerateToken(email, userId)
+	// Tokens carry the user's role; unknown users get the default one.
+	role := "attendee"
+	db.DB.QueryRow("SELECT role FROM users WHERE id = ?", userId).Scan(&role)
+
+	token, err := utils.GenerateToken(email, userId, role)
 	if err != nil {
 		t.Fatalf("Failed to generate test token: %v", err)
 	}
@@ -107,3 +441,29 @@ func createTestUser(t *testing.T, db *sql.DB, email, password string) int64 {
 
 	return userId
 }
+
+// postJSON sends a JSON request with an optional token and returns the response
+func postJSON(t *testing.T, router http.Handler, method, path, token string, payload interface{}) *httptest.ResponseRecorder {
+	jsonPayload, _ := json.Marshal(payload)
+	req, _ := http.NewRequest(method, path, bytes.NewBuffer(jsonPayload))
+	req.Header.Set("Content-Type", "application/json")
+	if token != "" {
+		req.Header.Set("Authorization", token)
+	}
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	return w
+}
+
+// createTestEvent creates an event owned by userId and returns its ID
+func createTestEvent(t *testing.T, userId int64) int64 {
+	result, err := db.DB.Exec(
+		"INSERT INTO events(name, description, location, dateTime, user_id) VALUES (?, ?, ?, ?, ?)",
+		"Test Event", "Test Description", "Test Location", time.Now(), userId,
+	)
+	if err != nil {
+		t.Fatalf("Failed to create test event: %v", err)
+	}
+	eventId, _ := result.LastInsertId()
+	return eventId
+}
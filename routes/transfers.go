@@ -0,0 +1,210 @@
+package routes
+
+import (
+	"database/sql"
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/salads-source/go_http_server/models"
+)
+
+type transferInput struct {
+	Email string `binding:"required,email"`
+}
+
+func offerTransfer(context *gin.Context) {
+	event, registration, ok := loadOwnRegistration(context)
+
+	if !ok {
+		return
+	}
+
+	var input transferInput
+	err := context.ShouldBindJSON(&input)
+
+	if err != nil {
+		context.JSON(http.StatusBadRequest, gin.H{"message": "Could not parse request data."})
+		return
+	}
+
+	if registration.Status != models.RegistrationStatusApproved {
+		context.JSON(http.StatusConflict, gin.H{"message": "Only approved registrations can be transferred."})
+		return
+	}
+
+	transfer, err := event.OfferTransfer(*registration, input.Email)
+
+	if errors.Is(err, models.ErrTransfersDisabled) {
+		context.JSON(http.StatusForbidden, gin.H{"message": "Transfers are not allowed for this event."})
+		return
+	}
+
+	if errors.Is(err, models.ErrTransferToSelf) {
+		context.JSON(http.StatusBadRequest, gin.H{"message": "You cannot transfer a registration to yourself."})
+		return
+	}
+
+	if err != nil {
+		context.JSON(http.StatusInternalServerError, gin.H{"message": "Could not offer transfer, try again later"})
+		return
+	}
+
+	context.JSON(http.StatusCreated, gin.H{"message": "Transfer offered", "transfer": transfer})
+}
+
+func cancelTransfer(context *gin.Context) {
+	_, registration, ok := loadOwnRegistration(context)
+
+	if !ok {
+		return
+	}
+
+	err := registration.CancelTransfer()
+
+	if errors.Is(err, sql.ErrNoRows) {
+		context.JSON(http.StatusNotFound, gin.H{"message": "There is no pending transfer."})
+		return
+	}
+
+	if err != nil {
+		context.JSON(http.StatusInternalServerError, gin.H{"message": "Could not cancel transfer, try again later"})
+		return
+	}
+
+	context.JSON(http.StatusOK, gin.H{"message": "Transfer cancelled"})
+}
+
+func getMyTransfers(context *gin.Context) {
+	transfers, err := models.GetPendingTransfers(context.GetInt64("userId"))
+
+	if err != nil {
+		context.JSON(http.StatusInternalServerError, gin.H{"message": "Could not fetch transfers, try again later"})
+		return
+	}
+
+	context.JSON(http.StatusOK, gin.H{"message": "Transfers fetched successfully", "transfers": transfers})
+}
+
+// getEventTransfers shows the organizer every transfer offered for the
+// event, including answered ones.
+func getEventTransfers(context *gin.Context) {
+	event, ok := loadOrganizedEvent(context)
+
+	if !ok {
+		return
+	}
+
+	transfers, err := models.GetEventTransfers(event.ID)
+
+	if err != nil {
+		context.JSON(http.StatusInternalServerError, gin.H{"message": "Could not fetch transfers, try again later"})
+		return
+	}
+
+	context.JSON(http.StatusOK, gin.H{"message": "Transfers fetched successfully", "transfers": transfers})
+}
+
+func acceptTransfer(context *gin.Context) {
+	transferId, err := strconv.ParseInt(context.Param("id"), 10, 64)
+
+	if err != nil {
+		context.JSON(http.StatusBadRequest, gin.H{"message": "Invalid transfer Id"})
+		return
+	}
+
+	userId := context.GetInt64("userId")
+	transfer, err := models.GetPendingTransferFor(transferId, userId)
+
+	if errors.Is(err, sql.ErrNoRows) {
+		context.JSON(http.StatusNotFound, gin.H{"message": "Transfer not found."})
+		return
+	}
+
+	if errors.Is(err, models.ErrTransferNotPending) {
+		context.JSON(http.StatusConflict, gin.H{"message": "Transfer is no longer available."})
+		return
+	}
+
+	if err != nil {
+		context.JSON(http.StatusInternalServerError, gin.H{"message": "Could not accept transfer, try again later"})
+		return
+	}
+
+	event, err := models.GetEventByID(transfer.EventID)
+
+	if errors.Is(err, sql.ErrNoRows) {
+		context.JSON(http.StatusNotFound, gin.H{"message": "Event not found."})
+		return
+	}
+
+	if err != nil {
+		context.JSON(http.StatusInternalServerError, gin.H{"message": "Could not fetch event, try again later"})
+		return
+	}
+
+	// The organizer may have turned transfers off after the offer was made.
+	if !event.AllowTransfers {
+		context.JSON(http.StatusForbidden, gin.H{"message": "Transfers are not allowed for this event."})
+		return
+	}
+
+	if event.RegistrationsLocked(time.Now()) {
+		context.JSON(http.StatusForbidden, gin.H{"message": "Registrations can no longer be transferred."})
+		return
+	}
+
+	registration, err := models.AcceptTransfer(transferId, userId)
+
+	if errors.Is(err, sql.ErrNoRows) {
+		context.JSON(http.StatusNotFound, gin.H{"message": "Transfer not found."})
+		return
+	}
+
+	if errors.Is(err, models.ErrTransferNotPending) {
+		context.JSON(http.StatusConflict, gin.H{"message": "Transfer is no longer available."})
+		return
+	}
+
+	if errors.Is(err, models.ErrAlreadyRegistered) {
+		context.JSON(http.StatusConflict, gin.H{"message": "You are already registered for this event."})
+		return
+	}
+
+	if err != nil {
+		context.JSON(http.StatusInternalServerError, gin.H{"message": "Could not accept transfer, try again later"})
+		return
+	}
+
+	context.JSON(http.StatusOK, gin.H{"message": "Transfer accepted", "registration": registration})
+}
+
+func declineTransfer(context *gin.Context) {
+	transferId, err := strconv.ParseInt(context.Param("id"), 10, 64)
+
+	if err != nil {
+		context.JSON(http.StatusBadRequest, gin.H{"message": "Invalid transfer Id"})
+		return
+	}
+
+	err = models.DeclineTransfer(transferId, context.GetInt64("userId"))
+
+	if errors.Is(err, sql.ErrNoRows) {
+		context.JSON(http.StatusNotFound, gin.H{"message": "Transfer not found."})
+		return
+	}
+
+	if errors.Is(err, models.ErrTransferNotPending) {
+		context.JSON(http.StatusConflict, gin.H{"message": "Transfer is no longer available."})
+		return
+	}
+
+	if err != nil {
+		context.JSON(http.StatusInternalServerError, gin.H{"message": "Could not decline transfer, try again later"})
+		return
+	}
+
+	context.JSON(http.StatusOK, gin.H{"message": "Transfer declined"})
+}
@@ -0,0 +1,205 @@
+package routes
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/salads-source/go_http_server/db"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRegistrationTransfers(t *testing.T) {
+	_, router := setupTestRouter(t)
+	organizerId := createTestUser(t, db.DB, "transferorganizer@example.com", "password123")
+	holderId := createTestUser(t, db.DB, "transferholder@example.com", "password123")
+	colleagueId := createTestUser(t, db.DB, "transfercolleague@example.com", "password123")
+	organizerToken := generateTestToken(t, "transferorganizer@example.com", organizerId)
+	holderToken := generateTestToken(t, "transferholder@example.com", holderId)
+	colleagueToken := generateTestToken(t, "transfercolleague@example.com", colleagueId)
+
+	eventId := createTestEvent(t, organizerId)
+	eventPath := "/events/" + strconv.FormatInt(eventId, 10)
+
+	w := postJSON(t, router, http.MethodPost, eventPath+"/register", holderToken, nil)
+	assert.Equal(t, http.StatusCreated, w.Code)
+
+	offer := func(email string) (int, int64) {
+		w := postJSON(t, router, http.MethodPost, eventPath+"/register/transfer", holderToken, map[string]interface{}{"email": email})
+		var response struct {
+			Transfer struct{ ID int64 } `json:"transfer"`
+		}
+		json.Unmarshal(w.Body.Bytes(), &response)
+		return w.Code, response.Transfer.ID
+	}
+
+	pendingFor := func(token string) []int64 {
+		w := getAs(router, "/me/transfers", token)
+		var response struct {
+			Transfers []struct{ ID int64 } `json:"transfers"`
+		}
+		json.Unmarshal(w.Body.Bytes(), &response)
+
+		ids := []int64{}
+		for _, transfer := range response.Transfers {
+			ids = append(ids, transfer.ID)
+		}
+		return ids
+	}
+
+	t.Run("transfers are off by default", func(t *testing.T) {
+		code, _ := offer("transfercolleague@example.com")
+		assert.Equal(t, http.StatusForbidden, code)
+
+		db.DB.Exec("UPDATE events SET allow_transfers = 1 WHERE id = ?", eventId)
+	})
+
+	t.Run("offers must go to someone else", func(t *testing.T) {
+		code, _ := offer("TransferHolder@example.com")
+		assert.Equal(t, http.StatusBadRequest, code)
+
+		code, _ = offer("not-an-email")
+		assert.Equal(t, http.StatusBadRequest, code)
+	})
+
+	t.Run("a new offer replaces the pending one", func(t *testing.T) {
+		code, first := offer("transfercolleague@example.com")
+		assert.Equal(t, http.StatusCreated, code)
+
+		code, second := offer("TransferColleague@example.com")
+		assert.Equal(t, http.StatusCreated, code)
+
+		assert.Equal(t, []int64{second}, pendingFor(colleagueToken))
+
+		w := postJSON(t, router, http.MethodPost, "/transfers/"+strconv.FormatInt(first, 10)+"/accept", colleagueToken, nil)
+		assert.Equal(t, http.StatusConflict, w.Code)
+	})
+
+	t.Run("only the recipient can accept", func(t *testing.T) {
+		transferId := pendingFor(colleagueToken)[0]
+		w := postJSON(t, router, http.MethodPost, "/transfers/"+strconv.FormatInt(transferId, 10)+"/accept", organizerToken, nil)
+		assert.Equal(t, http.StatusNotFound, w.Code)
+
+		// The event's settings are not revealed to anyone else either.
+		db.DB.Exec("UPDATE events SET allow_transfers = 0 WHERE id = ?", eventId)
+		w = postJSON(t, router, http.MethodPost, "/transfers/"+strconv.FormatInt(transferId, 10)+"/accept", organizerToken, nil)
+		assert.Equal(t, http.StatusNotFound, w.Code)
+		db.DB.Exec("UPDATE events SET allow_transfers = 1 WHERE id = ?", eventId)
+	})
+
+	t.Run("accepting moves the registration", func(t *testing.T) {
+		transferId := pendingFor(colleagueToken)[0]
+		w := postJSON(t, router, http.MethodPost, "/transfers/"+strconv.FormatInt(transferId, 10)+"/accept", colleagueToken, nil)
+		assert.Equal(t, http.StatusOK, w.Code)
+
+		assert.Equal(t, http.StatusNotFound, getAs(router, eventPath+"/register", holderToken).Code)
+		assert.Equal(t, http.StatusOK, getAs(router, eventPath+"/register", colleagueToken).Code)
+
+		w = postJSON(t, router, http.MethodPost, "/transfers/"+strconv.FormatInt(transferId, 10)+"/decline", colleagueToken, nil)
+		assert.Equal(t, http.StatusConflict, w.Code)
+	})
+
+	t.Run("organizer sees the audit trail", func(t *testing.T) {
+		assert.Equal(t, http.StatusUnauthorized, getAs(router, eventPath+"/transfers", colleagueToken).Code)
+
+		w := getAs(router, eventPath+"/transfers", organizerToken)
+		assert.Equal(t, http.StatusOK, w.Code)
+
+		var response struct {
+			Transfers []struct {
+				FromUserID int64
+				ToUserID   *int64
+				Status     string
+			} `json:"transfers"`
+		}
+		json.Unmarshal(w.Body.Bytes(), &response)
+		assert.Len(t, response.Transfers, 2)
+		assert.Equal(t, "cancelled", response.Transfers[0].Status)
+		assert.Equal(t, "accepted", response.Transfers[1].Status)
+		assert.Equal(t, holderId, response.Transfers[1].FromUserID)
+		assert.Equal(t, colleagueId, *response.Transfers[1].ToUserID)
+	})
+
+	t.Run("declining and cancelling offers", func(t *testing.T) {
+		w := postJSON(t, router, http.MethodPost, eventPath+"/register/transfer", colleagueToken, map[string]interface{}{"email": "transferholder@example.com"})
+		assert.Equal(t, http.StatusCreated, w.Code)
+
+		transferId := pendingFor(holderToken)[0]
+		w = postJSON(t, router, http.MethodPost, "/transfers/"+strconv.FormatInt(transferId, 10)+"/decline", holderToken, nil)
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Empty(t, pendingFor(holderToken))
+
+		w = postJSON(t, router, http.MethodDelete, eventPath+"/register/transfer", colleagueToken, nil)
+		assert.Equal(t, http.StatusNotFound, w.Code)
+
+		w = postJSON(t, router, http.MethodPost, eventPath+"/register/transfer", colleagueToken, map[string]interface{}{"email": "transferholder@example.com"})
+		assert.Equal(t, http.StatusCreated, w.Code)
+
+		w = postJSON(t, router, http.MethodDelete, eventPath+"/register/transfer", colleagueToken, nil)
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Empty(t, pendingFor(holderToken))
+	})
+
+	t.Run("offers wait for the recipient to verify their email", func(t *testing.T) {
+		newcomerId := createTestUser(t, db.DB, "transfernewcomer@example.com", "password123")
+		db.DB.Exec("UPDATE users SET email_verified = 0 WHERE id = ?", newcomerId)
+		newcomerToken := generateTestToken(t, "transfernewcomer@example.com", newcomerId)
+
+		w := postJSON(t, router, http.MethodPost, eventPath+"/register/transfer", colleagueToken, map[string]interface{}{"email": "transfernewcomer@example.com"})
+		assert.Equal(t, http.StatusCreated, w.Code)
+		var response struct {
+			Transfer struct{ ID int64 } `json:"transfer"`
+		}
+		json.Unmarshal(w.Body.Bytes(), &response)
+
+		assert.Empty(t, pendingFor(newcomerToken))
+		w = postJSON(t, router, http.MethodPost, "/transfers/"+strconv.FormatInt(response.Transfer.ID, 10)+"/accept", newcomerToken, nil)
+		assert.Equal(t, http.StatusNotFound, w.Code)
+
+		db.DB.Exec("UPDATE users SET email_verified = 1 WHERE id = ?", newcomerId)
+		assert.Equal(t, []int64{response.Transfer.ID}, pendingFor(newcomerToken))
+
+		w = postJSON(t, router, http.MethodDelete, eventPath+"/register/transfer", colleagueToken, nil)
+		assert.Equal(t, http.StatusOK, w.Code)
+	})
+
+	t.Run("recipients must be able to see a private event", func(t *testing.T) {
+		privateId := createTestEvent(t, organizerId)
+		db.DB.Exec("UPDATE events SET visibility = 'private', allow_transfers = 1 WHERE id = ?", privateId)
+		privatePath := "/events/" + strconv.FormatInt(privateId, 10)
+		db.DB.Exec("INSERT INTO registrations(event_id, user_id) VALUES (?, ?)", privateId, holderId)
+
+		w := postJSON(t, router, http.MethodPost, privatePath+"/register/transfer", holderToken, map[string]interface{}{"email": "transfercolleague@example.com"})
+		assert.Equal(t, http.StatusCreated, w.Code)
+		var response struct {
+			Transfer struct{ ID int64 } `json:"transfer"`
+		}
+		json.Unmarshal(w.Body.Bytes(), &response)
+		acceptPath := "/transfers/" + strconv.FormatInt(response.Transfer.ID, 10) + "/accept"
+
+		w = postJSON(t, router, http.MethodPost, acceptPath, colleagueToken, nil)
+		assert.Equal(t, http.StatusNotFound, w.Code)
+		assert.Equal(t, http.StatusOK, getAs(router, privatePath+"/register", holderToken).Code)
+
+		w = postJSON(t, router, http.MethodPost, privatePath+"/invitations", organizerToken, map[string]interface{}{"emails": []string{"transfercolleague@example.com"}})
+		assert.Equal(t, http.StatusCreated, w.Code)
+
+		w = postJSON(t, router, http.MethodPost, acceptPath, colleagueToken, nil)
+		assert.Equal(t, http.StatusOK, w.Code)
+	})
+
+	t.Run("offers for missing events", func(t *testing.T) {
+		result, err := db.DB.Exec(`INSERT INTO registration_transfers(registration_id, event_id, from_user_id, to_email, status, created_at)
+		VALUES (?, ?, ?, ?, 'pending', ?)`, 999, 999, organizerId, "transferholder@example.com", time.Now().UTC())
+		if err != nil {
+			t.Fatalf("Failed to create test transfer: %v", err)
+		}
+		transferId, _ := result.LastInsertId()
+
+		w := postJSON(t, router, http.MethodPost, "/transfers/"+strconv.FormatInt(transferId, 10)+"/accept", holderToken, nil)
+		assert.Equal(t, http.StatusNotFound, w.Code)
+		assert.Contains(t, w.Body.String(), "Event not found.")
+	})
+}
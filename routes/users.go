@@ -1,6 +1,8 @@
 package routes
 
 import (
+	"database/sql"
+	"errors"
 	"net/http"
 
 	"github.com/gin-gonic/gin"
@@ -8,49 +10,190 @@ import (
 	"github.com/salads-source/go_http_server/utils"
 )
 
+type credentialsInput struct {
+	Email    string `binding:"required"`
+	Password string `binding:"required"`
+}
+
 func signup(context *gin.Context) {
-	var user models.User
+	var input credentialsInput
 
-	err := context.ShouldBindJSON(&user)
+	err := context.ShouldBindJSON(&input)
 
 	if err != nil {
 		context.JSON(http.StatusBadRequest, gin.H{"message": "Could not parse request"})
 		return
 	}
 
+	user := models.User{Email: input.Email, Password: input.Password}
+
 	err = user.Save()
 
+	if errors.Is(err, models.ErrInvalidEmail) {
+		context.JSON(http.StatusBadRequest, gin.H{"message": "Invalid email address."})
+		return
+	}
+
+	if rejectWeakPassword(context, err) {
+		return
+	}
+
 	if err != nil {
 		context.JSON(http.StatusInternalServerError, gin.H{"message": "Could not save user, try again later"})
 		return
 	}
 
+	sendVerificationEmail(user.ID, user.Email)
+
 	context.JSON(http.StatusCreated, gin.H{"message": "User created successfully"})
 }
 
+// rejectWeakPassword answers with everything wrong with the password if err
+// is a password policy error, and reports whether it did.
+func rejectWeakPassword(context *gin.Context, err error) bool {
+	var policyErr *models.PasswordPolicyError
+
+	if !errors.As(err, &policyErr) {
+		return false
+	}
+
+	context.JSON(http.StatusBadRequest, gin.H{"message": "Password does not meet the password policy.", "violations": policyErr.Violations})
+	return true
+}
+
 func login(context *gin.Context) {
-	var user models.User
+	var input credentialsInput
 
-	err := context.ShouldBindJSON(&user)
+	err := context.ShouldBindJSON(&input)
 
 	if err != nil {
 		context.JSON(http.StatusBadRequest, gin.H{"message": "Could not parse request"})
 		return
 	}
 
+	attempt := models.LoginAttempt{Email: input.Email, IPAddress: context.ClientIP(), UserAgent: context.Request.UserAgent()}
+	throttle, err := models.StartLoginAttempt(&attempt)
+
+	if err != nil {
+		context.JSON(http.StatusInternalServerError, gin.H{"message": "Could not authenticate user"})
+		return
+	}
+
+	if throttle.RetryAfter > 0 {
+		rejectThrottledLogin(context, throttle)
+		return
+	}
+
+	user := models.User{Email: input.Email, Password: input.Password}
+
 	err = user.ValidateCredentials()
 
+	if errors.Is(err, models.ErrInvalidCredentials) {
+		err = recordFailedLogin(&attempt)
+
+		if err != nil {
+			context.JSON(http.StatusInternalServerError, gin.H{"message": "Could not authenticate user"})
+			return
+		}
+
+		context.JSON(http.StatusUnauthorized, gin.H{"message": models.ErrInvalidCredentials.Error()})
+		return
+	}
+
 	if err != nil {
-		context.JSON(http.StatusUnauthorized, gin.H{"message": err.Error()})
+		context.JSON(http.StatusInternalServerError, gin.H{"message": "Could not authenticate user"})
 		return
 	}
 
-	token, err := utils.GenerateToken(user.Email, user.ID)
+	_, err = models.FinishLoginAttempt(&attempt, models.LoginOutcomeSuccess)
 
 	if err != nil {
 		context.JSON(http.StatusInternalServerError, gin.H{"message": "Could not authenticate user"})
 		return
 	}
 
-	context.JSON(http.StatusCreated, gin.H{"message": "Login Successfully", "token": token})
+	if models.RequireVerifiedEmail[models.VerifiedActionLogin] && !user.EmailVerified {
+		context.JSON(http.StatusForbidden, gin.H{"message": "Please verify your email address before logging in."})
+		return
+	}
+
+	session, refreshToken, err := models.CreateSession(user.ID, context.Request.UserAgent(), context.ClientIP())
+
+	if err != nil {
+		context.JSON(http.StatusInternalServerError, gin.H{"message": "Could not authenticate user"})
+		return
+	}
+
+	token, err := utils.GenerateSessionToken(user.Email, user.ID, user.Role, session.ID)
+
+	if err != nil {
+		context.JSON(http.StatusInternalServerError, gin.H{"message": "Could not authenticate user"})
+		return
+	}
+
+	context.JSON(http.StatusCreated, gin.H{
+		"message":      "Login Successfully",
+		"token":        token,
+		"expiresIn":    int(utils.AccessTokenLifetime.Seconds()),
+		"refreshToken": refreshToken,
+		"sessionId":    session.ID,
+	})
+}
+
+// logout revokes the access token it was called with and signs out the
+// session it belongs to.
+func logout(context *gin.Context) {
+	claims := context.MustGet("tokenClaims").(*utils.TokenClaims)
+	err := models.RevokeToken(claims)
+
+	if err != nil {
+		context.JSON(http.StatusInternalServerError, gin.H{"message": "Could not log out, try again later"})
+		return
+	}
+
+	if claims.SessionID != 0 {
+		err = models.RevokeSession(claims.UserID, claims.SessionID)
+
+		if err != nil && !errors.Is(err, sql.ErrNoRows) {
+			context.JSON(http.StatusInternalServerError, gin.H{"message": "Could not log out, try again later"})
+			return
+		}
+	}
+
+	context.JSON(http.StatusOK, gin.H{"message": "Logged out"})
+}
+
+type passwordChangeInput struct {
+	CurrentPassword string `binding:"required"`
+	NewPassword     string `binding:"required"`
+}
+
+// changePassword logs the user out everywhere, including the session that
+// made the request.
+func changePassword(context *gin.Context) {
+	var input passwordChangeInput
+	err := context.ShouldBindJSON(&input)
+
+	if err != nil {
+		context.JSON(http.StatusBadRequest, gin.H{"message": "Could not parse request"})
+		return
+	}
+
+	err = models.ChangePassword(context.GetInt64("userId"), input.CurrentPassword, input.NewPassword)
+
+	if errors.Is(err, models.ErrWrongPassword) {
+		context.JSON(http.StatusUnauthorized, gin.H{"message": "Current password is incorrect."})
+		return
+	}
+
+	if rejectWeakPassword(context, err) {
+		return
+	}
+
+	if err != nil {
+		context.JSON(http.StatusInternalServerError, gin.H{"message": "Could not change password, try again later"})
+		return
+	}
+
+	context.JSON(http.StatusOK, gin.H{"message": "Password changed, please log in again."})
 }
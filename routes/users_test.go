@@ -187,3 +187,30 @@ func TestLogin(t *testing.T) {
 	}
 }
 
+
+func TestEmailCase(t *testing.T) {
+	_, router := setupTestRouter(t)
+	createTestUser(t, db.DB, "alice@example.com", "password123")
+
+	t.Run("signup cannot take an address in another case", func(t *testing.T) {
+		w := postJSON(t, router, http.MethodPost, "/signup", "", map[string]interface{}{"email": "Alice@Example.com", "password": "password123"})
+		assert.Equal(t, http.StatusInternalServerError, w.Code)
+
+		var count int
+		db.DB.QueryRow("SELECT COUNT(*) FROM users WHERE LOWER(email) = 'alice@example.com'").Scan(&count)
+		assert.Equal(t, 1, count)
+	})
+
+	t.Run("signup stores the email in lower case", func(t *testing.T) {
+		w := postJSON(t, router, http.MethodPost, "/signup", "", map[string]interface{}{"email": " Bob@Example.com", "password": "password123"})
+		assert.Equal(t, http.StatusCreated, w.Code)
+
+		var email string
+		db.DB.QueryRow("SELECT email FROM users WHERE LOWER(email) = 'bob@example.com'").Scan(&email)
+		assert.Equal(t, "bob@example.com", email)
+	})
+
+	t.Run("login matches the email in any case", func(t *testing.T) {
+		assert.Equal(t, http.StatusCreated, loginAs(t, router, "ALICE@example.com", "password123"))
+	})
+}
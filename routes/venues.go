@@ -0,0 +1,311 @@
+package routes
+
+import (
+	"database/sql"
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/salads-source/go_http_server/models"
+)
+
+// availabilityWindow is how far ahead a venue calendar looks when no end is
+// given.
+const availabilityWindow = 30 * 24 * time.Hour
+
+// bookingErrorStatus returns the response status for an error from booking
+// an event into a venue, or 0 if err is not one.
+func bookingErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, models.ErrRoomBooked):
+		return http.StatusConflict
+	case errors.Is(err, models.ErrInvalidVenue), errors.Is(err, models.ErrInvalidRoom):
+		return http.StatusBadRequest
+	}
+
+	return 0
+}
+
+// loadVenue fetches the venue named in the request. It writes the error
+// response and returns false on failure.
+func loadVenue(context *gin.Context) (*models.Venue, bool) {
+	venueId, err := strconv.ParseInt(context.Param("id"), 10, 64)
+
+	if err != nil {
+		context.JSON(http.StatusBadRequest, gin.H{"message": "Invalid venue Id"})
+		return nil, false
+	}
+
+	venue, err := models.GetVenueByID(venueId)
+
+	if errors.Is(err, sql.ErrNoRows) {
+		context.JSON(http.StatusNotFound, gin.H{"message": "Venue not found."})
+		return nil, false
+	}
+
+	if err != nil {
+		context.JSON(http.StatusInternalServerError, gin.H{"message": "Could not fetch venue, try again later"})
+		return nil, false
+	}
+
+	return venue, true
+}
+
+// loadOwnedVenue fetches a venue and checks the caller manages it, or may
+// manage every event. Admins can then look after venues whose manager has
+// left.
+func loadOwnedVenue(context *gin.Context) (*models.Venue, bool) {
+	venue, ok := loadVenue(context)
+
+	if !ok {
+		return nil, false
+	}
+
+	if venue.UserID != context.GetInt64("userId") && !callerHas(context, models.PermissionManageAnyEvent) {
+		context.JSON(http.StatusUnauthorized, gin.H{"message": "Not authorized to manage this venue."})
+		return nil, false
+	}
+
+	return venue, true
+}
+
+func getVenues(context *gin.Context) {
+	venues, err := models.GetAllVenues()
+
+	if err != nil {
+		context.JSON(http.StatusInternalServerError, gin.H{"message": "Could not fetch venues, try again later"})
+		return
+	}
+
+	context.JSON(http.StatusOK, venues)
+}
+
+func getVenue(context *gin.Context) {
+	venue, ok := loadVenue(context)
+
+	if !ok {
+		return
+	}
+
+	context.JSON(http.StatusOK, venue)
+}
+
+func createVenue(context *gin.Context) {
+	var venue models.Venue
+	err := context.ShouldBindJSON(&venue)
+
+	if err != nil {
+		context.JSON(http.StatusBadRequest, gin.H{"message": "Could not parse request data."})
+		return
+	}
+
+	venue.UserID = context.GetInt64("userId")
+	err = venue.Save()
+
+	if err != nil {
+		context.JSON(http.StatusInternalServerError, gin.H{"message": "Could not save venue, try again later"})
+		return
+	}
+
+	context.JSON(http.StatusCreated, gin.H{"message": "Venue created!", "venue": venue})
+}
+
+func updateVenue(context *gin.Context) {
+	venue, ok := loadOwnedVenue(context)
+
+	if !ok {
+		return
+	}
+
+	var updatedVenue models.Venue
+	err := context.ShouldBindJSON(&updatedVenue)
+
+	if err != nil {
+		context.JSON(http.StatusBadRequest, gin.H{"message": "Could not parse request data."})
+		return
+	}
+
+	updatedVenue.ID = venue.ID
+	err = updatedVenue.Update()
+
+	if err != nil {
+		context.JSON(http.StatusInternalServerError, gin.H{"message": "Could not update venue."})
+		return
+	}
+
+	context.JSON(http.StatusOK, gin.H{"message": "Venue updated successfully!"})
+}
+
+func deleteVenue(context *gin.Context) {
+	venue, ok := loadOwnedVenue(context)
+
+	if !ok {
+		return
+	}
+
+	err := venue.Delete()
+
+	if errors.Is(err, models.ErrVenueInUse) {
+		context.JSON(http.StatusConflict, gin.H{"message": "Venue has events booked and cannot be deleted."})
+		return
+	}
+
+	if err != nil {
+		context.JSON(http.StatusInternalServerError, gin.H{"message": "Could not delete venue, try again later"})
+		return
+	}
+
+	context.JSON(http.StatusOK, gin.H{"message": "Venue deleted successfully"})
+}
+
+func createRoom(context *gin.Context) {
+	venue, ok := loadOwnedVenue(context)
+
+	if !ok {
+		return
+	}
+
+	var room models.Room
+	err := context.ShouldBindJSON(&room)
+
+	if err != nil {
+		context.JSON(http.StatusBadRequest, gin.H{"message": "Could not parse request data."})
+		return
+	}
+
+	room.VenueID = venue.ID
+	err = room.Save()
+
+	if err != nil {
+		context.JSON(http.StatusInternalServerError, gin.H{"message": "Could not save room, try again later"})
+		return
+	}
+
+	context.JSON(http.StatusCreated, gin.H{"message": "Room created!", "room": room})
+}
+
+// loadOwnedRoom fetches a room of a venue the caller manages.
+func loadOwnedRoom(context *gin.Context) (*models.Room, bool) {
+	venue, ok := loadOwnedVenue(context)
+
+	if !ok {
+		return nil, false
+	}
+
+	roomId, err := strconv.ParseInt(context.Param("roomId"), 10, 64)
+
+	if err != nil {
+		context.JSON(http.StatusBadRequest, gin.H{"message": "Invalid room Id"})
+		return nil, false
+	}
+
+	room, err := models.GetRoom(venue.ID, roomId)
+
+	if errors.Is(err, sql.ErrNoRows) {
+		context.JSON(http.StatusNotFound, gin.H{"message": "Room not found."})
+		return nil, false
+	}
+
+	if err != nil {
+		context.JSON(http.StatusInternalServerError, gin.H{"message": "Could not fetch room, try again later"})
+		return nil, false
+	}
+
+	return room, true
+}
+
+func updateRoom(context *gin.Context) {
+	room, ok := loadOwnedRoom(context)
+
+	if !ok {
+		return
+	}
+
+	var updatedRoom models.Room
+	err := context.ShouldBindJSON(&updatedRoom)
+
+	if err != nil {
+		context.JSON(http.StatusBadRequest, gin.H{"message": "Could not parse request data."})
+		return
+	}
+
+	updatedRoom.ID = room.ID
+	err = updatedRoom.Update()
+
+	if err != nil {
+		context.JSON(http.StatusInternalServerError, gin.H{"message": "Could not update room."})
+		return
+	}
+
+	context.JSON(http.StatusOK, gin.H{"message": "Room updated successfully!"})
+}
+
+func deleteRoom(context *gin.Context) {
+	room, ok := loadOwnedRoom(context)
+
+	if !ok {
+		return
+	}
+
+	err := room.Delete()
+
+	if errors.Is(err, models.ErrVenueInUse) {
+		context.JSON(http.StatusConflict, gin.H{"message": "Room has events booked and cannot be deleted."})
+		return
+	}
+
+	if err != nil {
+		context.JSON(http.StatusInternalServerError, gin.H{"message": "Could not delete room, try again later"})
+		return
+	}
+
+	context.JSON(http.StatusOK, gin.H{"message": "Room deleted successfully"})
+}
+
+// getVenueAvailability lists the bookings at a venue between the from and
+// to query parameters, which default to now and 30 days after from.
+func getVenueAvailability(context *gin.Context) {
+	from, err := parseTimeQuery(context, "from")
+
+	if err != nil {
+		context.JSON(http.StatusBadRequest, gin.H{"message": err.Error()})
+		return
+	}
+
+	to, err := parseTimeQuery(context, "to")
+
+	if err != nil {
+		context.JSON(http.StatusBadRequest, gin.H{"message": err.Error()})
+		return
+	}
+
+	if from.IsZero() {
+		from = time.Now()
+	}
+
+	if to.IsZero() {
+		to = from.Add(availabilityWindow)
+	}
+
+	if !to.After(from) {
+		context.JSON(http.StatusBadRequest, gin.H{"message": "to must be after from"})
+		return
+	}
+
+	venue, ok := loadVenue(context)
+
+	if !ok {
+		return
+	}
+
+	bookings, err := models.GetVenueBookings(venue.ID, from, to)
+
+	if err != nil {
+		context.JSON(http.StatusInternalServerError, gin.H{"message": "Could not fetch venue availability, try again later"})
+		return
+	}
+
+	context.JSON(http.StatusOK, gin.H{"venue": venue, "from": from, "to": to, "bookings": bookings})
+}
@@ -0,0 +1,167 @@
+package routes
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/salads-source/go_http_server/db"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestVenues(t *testing.T) {
+	_, router := setupTestRouter(t)
+	managerId := createTestUser(t, db.DB, "venuemanager@example.com", "password123")
+	organizerId := createTestUser(t, db.DB, "venueorganizer@example.com", "password123")
+	managerToken := generateTestToken(t, "venuemanager@example.com", managerId)
+	organizerToken := generateTestToken(t, "venueorganizer@example.com", organizerId)
+
+	w := postJSON(t, router, http.MethodPost, "/venues", managerToken, map[string]interface{}{
+		"name":     "Community Centre",
+		"address":  "1 High Street",
+		"capacity": 200,
+		"rooms":    []map[string]interface{}{{"name": "Hall"}, {"name": "Studio", "capacity": 2}},
+	})
+	assert.Equal(t, http.StatusCreated, w.Code)
+
+	var created struct {
+		Venue struct {
+			ID    int64
+			Rooms []struct{ ID int64 }
+		} `json:"venue"`
+	}
+	json.Unmarshal(w.Body.Bytes(), &created)
+	assert.Len(t, created.Venue.Rooms, 2)
+	venuePath := "/venues/" + strconv.FormatInt(created.Venue.ID, 10)
+	hallId := created.Venue.Rooms[0].ID
+	studioId := created.Venue.Rooms[1].ID
+
+	start := time.Date(2030, 7, 1, 18, 0, 0, 0, time.UTC)
+	book := func(roomId int64, startsAt time.Time) *bookingResult {
+		payload := map[string]interface{}{
+			"name":            "Booked",
+			"description":     "Description",
+			"venueId":         created.Venue.ID,
+			"roomId":          roomId,
+			"dateTime":        startsAt,
+			"durationMinutes": 120,
+		}
+		w := postJSON(t, router, http.MethodPost, "/events", organizerToken, payload)
+		var response struct {
+			Event struct {
+				ID       int64
+				Location string
+				Capacity int
+			} `json:"event"`
+		}
+		json.Unmarshal(w.Body.Bytes(), &response)
+		return &bookingResult{w.Code, response.Event.ID, response.Event.Location, response.Event.Capacity}
+	}
+
+	hallBooking := book(hallId, start)
+
+	t.Run("booking defaults location and capacity", func(t *testing.T) {
+		assert.Equal(t, http.StatusCreated, hallBooking.code)
+		assert.Equal(t, "Community Centre, 1 High Street", hallBooking.location)
+		assert.Equal(t, 200, hallBooking.capacity)
+
+		studio := book(studioId, start)
+		assert.Equal(t, http.StatusCreated, studio.code)
+		assert.Equal(t, 2, studio.capacity)
+	})
+
+	t.Run("double booking is rejected", func(t *testing.T) {
+		assert.Equal(t, http.StatusConflict, book(hallId, start.Add(time.Hour)).code)
+		assert.Equal(t, http.StatusCreated, book(hallId, start.Add(2*time.Hour)).code)
+	})
+
+	t.Run("room must belong to the venue", func(t *testing.T) {
+		assert.Equal(t, http.StatusBadRequest, book(999, start.Add(24*time.Hour)).code)
+	})
+
+	t.Run("updates are checked too", func(t *testing.T) {
+		w := postJSON(t, router, http.MethodPut, "/events/"+strconv.FormatInt(hallBooking.id, 10), organizerToken, map[string]interface{}{
+			"name":        "Booked",
+			"description": "Description",
+			"venueId":     created.Venue.ID,
+			"roomId":      hallId,
+			"dateTime":    start.Add(3 * time.Hour),
+		})
+		assert.Equal(t, http.StatusConflict, w.Code)
+	})
+
+	t.Run("availability calendar", func(t *testing.T) {
+		w := getAs(router, venuePath+"/availability?from=2030-07-01T00:00:00Z&to=2030-07-02T00:00:00Z", "")
+		assert.Equal(t, http.StatusOK, w.Code)
+
+		var response struct {
+			Bookings []struct {
+				RoomID   *int64
+				DateTime time.Time
+			} `json:"bookings"`
+		}
+		json.Unmarshal(w.Body.Bytes(), &response)
+		assert.Len(t, response.Bookings, 3)
+
+		w = getAs(router, venuePath+"/availability?from=2030-07-02T00:00:00Z&to=2030-07-01T00:00:00Z", "")
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+	})
+
+	t.Run("only the manager edits the venue", func(t *testing.T) {
+		payload := map[string]interface{}{"name": "Renamed", "address": "1 High Street", "capacity": 150}
+
+		w := postJSON(t, router, http.MethodPut, venuePath, organizerToken, payload)
+		assert.Equal(t, http.StatusUnauthorized, w.Code)
+
+		w = postJSON(t, router, http.MethodPut, venuePath, managerToken, payload)
+		assert.Equal(t, http.StatusOK, w.Code)
+	})
+
+	t.Run("admins can edit any venue", func(t *testing.T) {
+		adminId := createTestUser(t, db.DB, "venueadmin@example.com", "password123")
+		db.DB.Exec("UPDATE users SET role = 'admin' WHERE id = ?", adminId)
+		adminToken := generateTestToken(t, "venueadmin@example.com", adminId)
+
+		w := postJSON(t, router, http.MethodPut, venuePath, adminToken, map[string]interface{}{"name": "Renamed", "address": "1 High Street", "capacity": 150})
+		assert.Equal(t, http.StatusOK, w.Code)
+	})
+
+	t.Run("venues in use cannot be deleted", func(t *testing.T) {
+		w := postJSON(t, router, http.MethodDelete, venuePath+"/rooms/"+strconv.FormatInt(hallId, 10), managerToken, nil)
+		assert.Equal(t, http.StatusConflict, w.Code)
+
+		w = postJSON(t, router, http.MethodDelete, venuePath, managerToken, nil)
+		assert.Equal(t, http.StatusConflict, w.Code)
+
+		w = postJSON(t, router, http.MethodPost, venuePath+"/rooms", managerToken, map[string]interface{}{"name": "Annex"})
+		assert.Equal(t, http.StatusCreated, w.Code)
+		var room struct {
+			Room struct{ ID int64 } `json:"room"`
+		}
+		json.Unmarshal(w.Body.Bytes(), &room)
+
+		w = postJSON(t, router, http.MethodDelete, venuePath+"/rooms/"+strconv.FormatInt(room.Room.ID, 10), managerToken, nil)
+		assert.Equal(t, http.StatusOK, w.Code)
+	})
+
+	t.Run("capacity is enforced on registration", func(t *testing.T) {
+		studio := book(studioId, start.Add(48*time.Hour))
+		path := "/events/" + strconv.FormatInt(studio.id, 10) + "/register"
+
+		for i, expected := range []int{http.StatusCreated, http.StatusCreated, http.StatusConflict} {
+			userId := createTestUser(t, db.DB, "venueattendee"+strconv.Itoa(i)+"@example.com", "password123")
+			token := generateTestToken(t, "venueattendee"+strconv.Itoa(i)+"@example.com", userId)
+			w := postJSON(t, router, http.MethodPost, path, token, nil)
+			assert.Equal(t, expected, w.Code)
+		}
+	})
+}
+
+type bookingResult struct {
+	code     int
+	id       int64
+	location string
+	capacity int
+}
@@ -0,0 +1,97 @@
+package routes
+
+import (
+	"errors"
+	"log"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/salads-source/go_http_server/mail"
+	"github.com/salads-source/go_http_server/models"
+)
+
+type verifyEmailInput struct {
+	Token string `binding:"required"`
+}
+
+type resendVerificationInput struct {
+	Email string `binding:"required"`
+}
+
+// sendVerificationEmail issues a verification token for the user and
+// emails it in the background.
+func sendVerificationEmail(userId int64, email string) {
+	mailer := mail.Default
+
+	inBackground(func() {
+		token, err := models.CreateEmailVerification(userId)
+
+		if err != nil {
+			return
+		}
+
+		deliverVerificationEmail(mailer, userId, email, token)
+	})
+}
+
+func deliverVerificationEmail(mailer mail.Mailer, userId int64, email, token string) {
+	err := mailer.Send(mail.Message{
+		To:      email,
+		Subject: "Verify your email address",
+		Body:    "Use this token to verify your email address:\n\n" + token + "\n\nIt expires in 24 hours.",
+	})
+
+	if err != nil {
+		log.Printf("could not send verification email to user %d: %v", userId, err)
+	}
+}
+
+func verifyEmail(context *gin.Context) {
+	var input verifyEmailInput
+	err := context.ShouldBindJSON(&input)
+
+	if err != nil {
+		context.JSON(http.StatusBadRequest, gin.H{"message": "Could not parse request"})
+		return
+	}
+
+	err = models.VerifyEmail(input.Token)
+
+	if errors.Is(err, models.ErrInvalidVerificationToken) {
+		context.JSON(http.StatusBadRequest, gin.H{"message": "The verification token is invalid or has expired."})
+		return
+	}
+
+	if err != nil {
+		context.JSON(http.StatusInternalServerError, gin.H{"message": "Could not verify email, try again later"})
+		return
+	}
+
+	context.JSON(http.StatusOK, gin.H{"message": "Email verified"})
+}
+
+// resendVerification answers the same way whether or not the account
+// exists, is already verified or is throttled, like forgotPassword.
+func resendVerification(context *gin.Context) {
+	var input resendVerificationInput
+	err := context.ShouldBindJSON(&input)
+
+	if err != nil {
+		context.JSON(http.StatusBadRequest, gin.H{"message": "Could not parse request"})
+		return
+	}
+
+	mailer := mail.Default
+
+	inBackground(func() {
+		user, token, err := models.ResendEmailVerification(input.Email)
+
+		if err != nil {
+			return
+		}
+
+		deliverVerificationEmail(mailer, user.ID, user.Email, token)
+	})
+
+	context.JSON(http.StatusAccepted, gin.H{"message": "If that account still needs verifying, a new verification token has been sent to it."})
+}
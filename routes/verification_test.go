@@ -0,0 +1,122 @@
+package routes
+
+import (
+	"net/http"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/salads-source/go_http_server/db"
+	"github.com/salads-source/go_http_server/mail"
+	"github.com/salads-source/go_http_server/models"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEmailVerification(t *testing.T) {
+	_, router := setupTestRouter(t)
+	mailPath := filepath.Join(t.TempDir(), "mail.txt")
+	mail.Default = mail.NewFileMailer(mailPath)
+	tokenPattern := regexp.MustCompile(`(?m)^[0-9a-f]{64}\r?$`)
+
+	t.Cleanup(func() { models.RequireVerifiedEmail = map[models.VerifiedAction]bool{} })
+
+	// waitForTokens waits until count verification tokens have been emailed.
+	waitForTokens := func(t *testing.T, count int) []string {
+		var tokens []string
+		assert.Eventually(t, func() bool {
+			tokens = tokenPattern.FindAllString(readMail(mailPath), -1)
+			return len(tokens) >= count
+		}, 5*time.Second, 10*time.Millisecond)
+		for i := range tokens {
+			tokens[i] = tokens[i][:64]
+		}
+		return tokens
+	}
+
+	login := func() int {
+		w := postJSON(t, router, http.MethodPost, "/login", "", map[string]interface{}{"email": "verify@example.com", "password": "password123"})
+		return w.Code
+	}
+
+	t.Run("signup rejects invalid emails", func(t *testing.T) {
+		w := postJSON(t, router, http.MethodPost, "/signup", "", map[string]interface{}{"email": "not an email", "password": "password123"})
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+	})
+
+	t.Run("signup emails a token that verifies the account", func(t *testing.T) {
+		w := postJSON(t, router, http.MethodPost, "/signup", "", map[string]interface{}{"email": "verify@example.com", "password": "password123"})
+		assert.Equal(t, http.StatusCreated, w.Code)
+		token := waitForTokens(t, 1)[0]
+
+		assert.Equal(t, http.StatusCreated, login())
+
+		models.RequireVerifiedEmail = map[models.VerifiedAction]bool{models.VerifiedActionLogin: true}
+		assert.Equal(t, http.StatusForbidden, login())
+
+		w = postJSON(t, router, http.MethodPost, "/verify-email", "", map[string]interface{}{"token": "not-a-token"})
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+
+		w = postJSON(t, router, http.MethodPost, "/verify-email", "", map[string]interface{}{"token": token})
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, http.StatusCreated, login())
+
+		w = postJSON(t, router, http.MethodPost, "/verify-email", "", map[string]interface{}{"token": token})
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+	})
+
+	t.Run("resending is throttled and does not reveal accounts", func(t *testing.T) {
+		w := postJSON(t, router, http.MethodPost, "/signup", "", map[string]interface{}{"email": "resend@example.com", "password": "password123"})
+		assert.Equal(t, http.StatusCreated, w.Code)
+		sent := len(waitForTokens(t, 2))
+
+		resend := func(email string) string {
+			w := postJSON(t, router, http.MethodPost, "/verify-email/resend", "", map[string]interface{}{"email": email})
+			assert.Equal(t, http.StatusAccepted, w.Code)
+			return w.Body.String()
+		}
+
+		assert.Equal(t, resend("nobody@example.com"), resend("resend@example.com"))
+		time.Sleep(100 * time.Millisecond)
+		assert.Len(t, tokenPattern.FindAllString(readMail(mailPath), -1), sent)
+
+		db.DB.Exec("UPDATE email_verifications SET created_at = ?", time.Now().Add(-2*time.Minute).UTC())
+		resend("resend@example.com")
+		waitForTokens(t, sent+1)
+	})
+
+	t.Run("unverified users can be kept from creating and joining events", func(t *testing.T) {
+		userId := createTestUser(t, db.DB, "unverified@example.com", "password123")
+		db.DB.Exec("UPDATE users SET email_verified = 0 WHERE id = ?", userId)
+		token := generateTestToken(t, "unverified@example.com", userId)
+
+		organizerId := createTestUser(t, db.DB, "verifiedorganizer@example.com", "password123")
+		eventPath := "/events/" + strconv.FormatInt(createTestEvent(t, organizerId), 10)
+		event := map[string]interface{}{
+			"name":        "Verified Event",
+			"description": "Only for verified users",
+			"location":    "Hall",
+			"dateTime":    time.Now().Add(24 * time.Hour).UTC().Format(time.RFC3339),
+		}
+
+		models.RequireVerifiedEmail = map[models.VerifiedAction]bool{
+			models.VerifiedActionCreateEvents: true,
+			models.VerifiedActionRegister:     true,
+		}
+
+		w := postJSON(t, router, http.MethodPost, "/events", token, event)
+		assert.Equal(t, http.StatusForbidden, w.Code)
+
+		w = postJSON(t, router, http.MethodPost, eventPath+"/register", token, nil)
+		assert.Equal(t, http.StatusForbidden, w.Code)
+
+		models.RequireVerifiedEmail = map[models.VerifiedAction]bool{}
+
+		w = postJSON(t, router, http.MethodPost, "/events", token, event)
+		assert.Equal(t, http.StatusCreated, w.Code)
+
+		w = postJSON(t, router, http.MethodPost, eventPath+"/register", token, nil)
+		assert.Equal(t, http.StatusCreated, w.Code)
+	})
+}
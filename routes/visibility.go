@@ -0,0 +1,251 @@
+package routes
+
+import (
+	"database/sql"
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/salads-source/go_http_server/models"
+)
+
+// inviteCode returns the invite code presented with the request, either as
+// the invite query parameter of a shared link or in the X-Invite-Code header.
+func inviteCode(context *gin.Context) string {
+	if code := context.Query("invite"); code != "" {
+		return code
+	}
+
+	return context.GetHeader("X-Invite-Code")
+}
+
+// loadVisibleEvent fetches an event the caller may see. Private events the
+// caller has no access to are reported as not found so their existence does
+// not leak. It writes the error response and returns false on failure.
+func loadVisibleEvent(context *gin.Context, eventId int64) (*models.Event, bool) {
+	event, err := models.GetEventByID(eventId)
+
+	if errors.Is(err, sql.ErrNoRows) {
+		context.JSON(http.StatusNotFound, gin.H{"message": "Event not found."})
+		return nil, false
+	}
+
+	if err != nil {
+		context.JSON(http.StatusInternalServerError, gin.H{"message": "Could not fetch event, try again later"})
+		return nil, false
+	}
+
+	visible, err := event.CanView(context.GetInt64("userId"), inviteCode(context))
+
+	if err != nil {
+		context.JSON(http.StatusInternalServerError, gin.H{"message": "Could not fetch event, try again later"})
+		return nil, false
+	}
+
+	if !visible && !callerHas(context, models.PermissionManageAnyEvent) {
+		context.JSON(http.StatusNotFound, gin.H{"message": "Event not found."})
+		return nil, false
+	}
+
+	return event, true
+}
+
+// loadOrganizedEvent fetches an event and checks the caller organizes it, or
+// is an admin.
+func loadOrganizedEvent(context *gin.Context) (*models.Event, bool) {
+	eventId, err := strconv.ParseInt(context.Param("id"), 10, 64)
+
+	if err != nil {
+		context.JSON(http.StatusBadRequest, gin.H{"message": "Invalid event Id"})
+		return nil, false
+	}
+
+	event, err := models.GetEventByID(eventId)
+
+	if errors.Is(err, sql.ErrNoRows) {
+		context.JSON(http.StatusNotFound, gin.H{"message": "Event not found."})
+		return nil, false
+	}
+
+	if err != nil {
+		context.JSON(http.StatusInternalServerError, gin.H{"message": "Could not fetch event, try again later"})
+		return nil, false
+	}
+
+	if !canManageEvent(context, event) {
+		context.JSON(http.StatusUnauthorized, gin.H{"message": "Not authorized to manage this event."})
+		return nil, false
+	}
+
+	return event, true
+}
+
+func getInvitations(context *gin.Context) {
+	event, ok := loadOrganizedEvent(context)
+
+	if !ok {
+		return
+	}
+
+	invitations, err := models.GetInvitations(event.ID)
+
+	if err != nil {
+		context.JSON(http.StatusInternalServerError, gin.H{"message": "Could not fetch invitations, try again later"})
+		return
+	}
+
+	context.JSON(http.StatusOK, gin.H{"invitations": invitations})
+}
+
+func createInvitations(context *gin.Context) {
+	event, ok := loadOrganizedEvent(context)
+
+	if !ok {
+		return
+	}
+
+	var input struct {
+		Emails []string `binding:"required,min=1,dive,required,email"`
+	}
+
+	err := context.ShouldBindJSON(&input)
+
+	if err != nil {
+		context.JSON(http.StatusBadRequest, gin.H{"message": "Could not parse request data."})
+		return
+	}
+
+	invitations := []models.Invitation{}
+
+	for _, email := range input.Emails {
+		invitation, err := event.Invite(email)
+
+		if errors.Is(err, models.ErrInvitationExists) {
+			continue
+		}
+
+		if err != nil {
+			context.JSON(http.StatusInternalServerError, gin.H{"message": "Could not save invitations, try again later"})
+			return
+		}
+
+		invitations = append(invitations, *invitation)
+	}
+
+	context.JSON(http.StatusCreated, gin.H{"message": "Invitations created!", "invitations": invitations})
+}
+
+func deleteInvitation(context *gin.Context) {
+	event, ok := loadOrganizedEvent(context)
+
+	if !ok {
+		return
+	}
+
+	invitationId, err := strconv.ParseInt(context.Param("invitationId"), 10, 64)
+
+	if err != nil {
+		context.JSON(http.StatusBadRequest, gin.H{"message": "Invalid invitation Id"})
+		return
+	}
+
+	err = event.DeleteInvitation(invitationId)
+
+	if errors.Is(err, sql.ErrNoRows) {
+		context.JSON(http.StatusNotFound, gin.H{"message": "Invitation not found."})
+		return
+	}
+
+	if err != nil {
+		context.JSON(http.StatusInternalServerError, gin.H{"message": "Could not delete invitation, try again later"})
+		return
+	}
+
+	context.JSON(http.StatusOK, gin.H{"message": "Invitation deleted successfully"})
+}
+
+func getInviteCodes(context *gin.Context) {
+	event, ok := loadOrganizedEvent(context)
+
+	if !ok {
+		return
+	}
+
+	codes, err := models.GetInviteCodes(event.ID)
+
+	if err != nil {
+		context.JSON(http.StatusInternalServerError, gin.H{"message": "Could not fetch invite codes, try again later"})
+		return
+	}
+
+	context.JSON(http.StatusOK, gin.H{"inviteCodes": codes})
+}
+
+// createInviteCode issues a code and the shareable link that carries it. The
+// plain code is only ever shown in this response.
+func createInviteCode(context *gin.Context) {
+	event, ok := loadOrganizedEvent(context)
+
+	if !ok {
+		return
+	}
+
+	var input struct {
+		ExpiresAt *time.Time
+	}
+
+	if context.Request.ContentLength != 0 {
+		err := context.ShouldBindJSON(&input)
+
+		if err != nil {
+			context.JSON(http.StatusBadRequest, gin.H{"message": "Could not parse request data."})
+			return
+		}
+	}
+
+	if input.ExpiresAt != nil && !input.ExpiresAt.After(time.Now()) {
+		context.JSON(http.StatusBadRequest, gin.H{"message": "Expiry must be in the future."})
+		return
+	}
+
+	code, err := event.CreateInviteCode(input.ExpiresAt)
+
+	if err != nil {
+		context.JSON(http.StatusInternalServerError, gin.H{"message": "Could not create invite code, try again later"})
+		return
+	}
+
+	link := "/events/" + strconv.FormatInt(event.ID, 10) + "?invite=" + code.Code
+	context.JSON(http.StatusCreated, gin.H{"message": "Invite code created!", "inviteCode": code, "link": link})
+}
+
+func revokeInviteCode(context *gin.Context) {
+	event, ok := loadOrganizedEvent(context)
+
+	if !ok {
+		return
+	}
+
+	codeId, err := strconv.ParseInt(context.Param("codeId"), 10, 64)
+
+	if err != nil {
+		context.JSON(http.StatusBadRequest, gin.H{"message": "Invalid invite code Id"})
+		return
+	}
+
+	err = event.RevokeInviteCode(codeId)
+
+	if errors.Is(err, sql.ErrNoRows) {
+		context.JSON(http.StatusNotFound, gin.H{"message": "Invite code not found."})
+		return
+	}
+
+	if err != nil {
+		context.JSON(http.StatusInternalServerError, gin.H{"message": "Could not revoke invite code, try again later"})
+		return
+	}
+
+	context.JSON(http.StatusOK, gin.H{"message": "Invite code revoked"})
+}
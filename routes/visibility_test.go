@@ -0,0 +1,175 @@
+package routes
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/salads-source/go_http_server/db"
+	"github.com/stretchr/testify/assert"
+)
+
+func getAs(router http.Handler, path, token string) *httptest.ResponseRecorder {
+	req, _ := http.NewRequest(http.MethodGet, path, nil)
+	if token != "" {
+		req.Header.Set("Authorization", token)
+	}
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	return w
+}
+
+func TestEventVisibility(t *testing.T) {
+	_, router := setupTestRouter(t)
+	organizerId := createTestUser(t, db.DB, "visorganizer@example.com", "password123")
+	invitedId := createTestUser(t, db.DB, "Invited@Example.com", "password123")
+	strangerId := createTestUser(t, db.DB, "visstranger@example.com", "password123")
+	organizerToken := generateTestToken(t, "visorganizer@example.com", organizerId)
+	invitedToken := generateTestToken(t, "Invited@Example.com", invitedId)
+	strangerToken := generateTestToken(t, "visstranger@example.com", strangerId)
+	unverifiedId := createTestUser(t, db.DB, "unverified-invited@example.com", "password123")
+	db.DB.Exec("UPDATE users SET email_verified = 0 WHERE id = ?", unverifiedId)
+	unverifiedToken := generateTestToken(t, "unverified-invited@example.com", unverifiedId)
+
+	publicId := createTestEvent(t, organizerId)
+	unlistedId := createTestEvent(t, organizerId)
+	privateId := createTestEvent(t, organizerId)
+	db.DB.Exec("UPDATE events SET visibility = 'unlisted' WHERE id = ?", unlistedId)
+	db.DB.Exec("UPDATE events SET visibility = 'private' WHERE id = ?", privateId)
+
+	privatePath := "/events/" + strconv.FormatInt(privateId, 10)
+
+	w := postJSON(t, router, http.MethodPost, privatePath+"/invitations", organizerToken, map[string]interface{}{"emails": []string{"invited@example.com", "unverified-invited@example.com"}})
+	assert.Equal(t, http.StatusCreated, w.Code)
+
+	listIds := func(token string) []int64 {
+		w := getAs(router, "/events", token)
+		var events []struct{ ID int64 }
+		json.Unmarshal(w.Body.Bytes(), &events)
+		ids := []int64{}
+		for _, event := range events {
+			ids = append(ids, event.ID)
+		}
+		return ids
+	}
+
+	t.Run("listings", func(t *testing.T) {
+		assert.Equal(t, []int64{publicId}, listIds(""))
+		assert.Equal(t, []int64{publicId}, listIds(strangerToken))
+		assert.Equal(t, []int64{publicId}, listIds(unverifiedToken))
+		assert.Equal(t, []int64{publicId, privateId}, listIds(invitedToken))
+		assert.Equal(t, []int64{publicId, unlistedId, privateId}, listIds(organizerToken))
+	})
+
+	tests := []struct {
+		name           string
+		path           string
+		authToken      string
+		expectedStatus int
+	}{
+		{"anonymous reads unlisted", "/events/" + strconv.FormatInt(unlistedId, 10), "", http.StatusOK},
+		{"anonymous cannot read private", privatePath, "", http.StatusNotFound},
+		{"stranger cannot read private", privatePath, strangerToken, http.StatusNotFound},
+		{"invited user reads private", privatePath, invitedToken, http.StatusOK},
+		{"unverified invited user cannot read private", privatePath, unverifiedToken, http.StatusNotFound},
+		{"organizer reads private", privatePath, organizerToken, http.StatusOK},
+		{"stranger cannot read private comments", privatePath + "/comments", strangerToken, http.StatusNotFound},
+		{"invalid invite code", privatePath + "?invite=nope", "", http.StatusNotFound},
+		{"missing event", "/events/999999", organizerToken, http.StatusNotFound},
+		{"missing organized event", "/events/999999/invitations", organizerToken, http.StatusNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := getAs(router, tt.path, tt.authToken)
+			assert.Equal(t, tt.expectedStatus, w.Code)
+		})
+	}
+
+	t.Run("stranger cannot register for private event", func(t *testing.T) {
+		w := postJSON(t, router, http.MethodPost, privatePath+"/register", strangerToken, nil)
+		assert.Equal(t, http.StatusNotFound, w.Code)
+	})
+
+	t.Run("invite codes grant access until revoked", func(t *testing.T) {
+		w := postJSON(t, router, http.MethodPost, privatePath+"/invite-codes", strangerToken, nil)
+		assert.Equal(t, http.StatusUnauthorized, w.Code)
+
+		w = postJSON(t, router, http.MethodPost, privatePath+"/invite-codes", organizerToken, nil)
+		assert.Equal(t, http.StatusCreated, w.Code)
+
+		var response struct {
+			InviteCode struct {
+				ID   int64
+				Code string
+			} `json:"inviteCode"`
+			Link string `json:"link"`
+		}
+		json.Unmarshal(w.Body.Bytes(), &response)
+		assert.NotEmpty(t, response.InviteCode.Code)
+
+		assert.Equal(t, http.StatusOK, getAs(router, response.Link, "").Code)
+
+		req, _ := http.NewRequest(http.MethodPost, privatePath+"/register", nil)
+		req.Header.Set("Authorization", strangerToken)
+		req.Header.Set("X-Invite-Code", response.InviteCode.Code)
+		w = httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusCreated, w.Code)
+
+		// Registered users keep access without the code.
+		assert.Equal(t, http.StatusOK, getAs(router, privatePath, strangerToken).Code)
+
+		w = getAs(router, privatePath+"/invite-codes", organizerToken)
+		assert.NotContains(t, w.Body.String(), response.InviteCode.Code)
+
+		w = postJSON(t, router, http.MethodDelete, privatePath+"/invite-codes/"+strconv.FormatInt(response.InviteCode.ID, 10), organizerToken, nil)
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, http.StatusNotFound, getAs(router, response.Link, "").Code)
+	})
+
+	t.Run("expired invite codes", func(t *testing.T) {
+		w := postJSON(t, router, http.MethodPost, privatePath+"/invite-codes", organizerToken, map[string]interface{}{"expiresAt": time.Now().Add(-time.Hour)})
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+
+		w = postJSON(t, router, http.MethodPost, privatePath+"/invite-codes", organizerToken, map[string]interface{}{"expiresAt": time.Now().Add(time.Hour)})
+		assert.Equal(t, http.StatusCreated, w.Code)
+		var response struct {
+			InviteCode struct{ ID int64 } `json:"inviteCode"`
+			Link       string             `json:"link"`
+		}
+		json.Unmarshal(w.Body.Bytes(), &response)
+		assert.Equal(t, http.StatusOK, getAs(router, response.Link, "").Code)
+
+		db.DB.Exec("UPDATE invite_codes SET expires_at = ? WHERE id = ?", time.Now().UTC().Add(-time.Minute), response.InviteCode.ID)
+		assert.Equal(t, http.StatusNotFound, getAs(router, response.Link, "").Code)
+	})
+
+	t.Run("only approved registrations grant access", func(t *testing.T) {
+		for _, status := range []string{"pending", "rejected"} {
+			userId := createTestUser(t, db.DB, status+"-visitor@example.com", "password123")
+			token := generateTestToken(t, status+"-visitor@example.com", userId)
+			db.DB.Exec("INSERT INTO registrations(event_id, user_id, status) VALUES (?, ?, ?)", privateId, userId, status)
+
+			assert.Equal(t, http.StatusNotFound, getAs(router, privatePath, token).Code, status)
+			assert.NotContains(t, listIds(token), privateId, status)
+		}
+	})
+
+	t.Run("update keeps visibility when omitted", func(t *testing.T) {
+		w := postJSON(t, router, http.MethodPut, privatePath, organizerToken, map[string]interface{}{
+			"name":        "Renamed",
+			"description": "Description",
+			"location":    "Location",
+			"dateTime":    time.Now().Format(time.RFC3339),
+		})
+		assert.Equal(t, http.StatusOK, w.Code)
+
+		var visibility string
+		db.DB.QueryRow("SELECT visibility FROM events WHERE id = ?", privateId).Scan(&visibility)
+		assert.Equal(t, "private", visibility)
+	})
+}
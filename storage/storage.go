@@ -0,0 +1,42 @@
+package storage
+
+import (
+	"errors"
+	"io"
+	"os"
+)
+
+// ErrNotFound is returned by a BlobStore when a key does not exist.
+var ErrNotFound = errors.New("blob not found")
+
+// BlobStore stores uploaded files under opaque keys. Keys are written once and
+// never modified, which lets callers cache served blobs indefinitely.
+type BlobStore interface {
+	Put(key string, r io.Reader) error
+	Open(key string) (Blob, error)
+	Delete(key string) error
+}
+
+// Blob is an open stored file. It can be passed to http.ServeContent.
+type Blob interface {
+	io.ReadSeekCloser
+	Size() int64
+}
+
+var Store BlobStore
+
+func InitStore() {
+	root := os.Getenv("UPLOAD_DIR")
+
+	if root == "" {
+		root = "uploads"
+	}
+
+	store, err := NewLocalStore(root)
+
+	if err != nil {
+		panic("Could not initialize upload storage.")
+	}
+
+	Store = store
+}
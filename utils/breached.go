@@ -0,0 +1,106 @@
+package utils
+
+import (
+	"bufio"
+	"crypto/sha1"
+	"encoding/hex"
+	"errors"
+	"io"
+	"os"
+	"strings"
+)
+
+// BreachedPasswords looks passwords up in a file of SHA-1 hashes of known
+// breached passwords, such as the one published by Have I Been Pwned: one
+// upper case hex hash per line, sorted, optionally followed by ":count".
+// The file is searched in place, so lists of any size can be used without
+// loading them into memory or asking a remote service.
+type BreachedPasswords struct {
+	file *os.File
+	size int64
+}
+
+func OpenBreachedPasswords(path string) (*BreachedPasswords, error) {
+	file, err := os.Open(path)
+
+	if err != nil {
+		return nil, err
+	}
+
+	info, err := file.Stat()
+
+	if err != nil {
+		file.Close()
+		return nil, err
+	}
+
+	return &BreachedPasswords{file: file, size: info.Size()}, nil
+}
+
+func (list *BreachedPasswords) Close() error {
+	return list.file.Close()
+}
+
+// Contains reports whether password is on the list. It is safe for
+// concurrent use.
+func (list *BreachedPasswords) Contains(password string) (bool, error) {
+	sum := sha1.Sum([]byte(password))
+	target := strings.ToUpper(hex.EncodeToString(sum[:]))
+
+	// Binary search over byte offsets for the first line at or after the
+	// offset whose hash is not less than the target.
+	low, high := int64(0), list.size
+
+	for low < high {
+		middle := low + (high-low)/2
+		hash, err := list.hashAfter(middle)
+
+		if err != nil {
+			return false, err
+		}
+
+		if hash == "" || hash >= target {
+			high = middle
+		} else {
+			low = middle + 1
+		}
+	}
+
+	hash, err := list.hashAfter(low)
+	return hash == target, err
+}
+
+// hashAfter returns the hash on the first line that starts at or after
+// offset, or "" at the end of the file.
+func (list *BreachedPasswords) hashAfter(offset int64) (string, error) {
+	start := offset
+
+	// Unless offset is the start of the file, back up one byte to tell
+	// whether it is the start of a line.
+	if start > 0 {
+		start--
+	}
+
+	reader := bufio.NewReaderSize(io.NewSectionReader(list.file, start, list.size-start), 128)
+
+	if offset > 0 {
+		_, err := reader.ReadString('\n')
+
+		if errors.Is(err, io.EOF) {
+			return "", nil
+		}
+
+		if err != nil {
+			return "", err
+		}
+	}
+
+	line, err := reader.ReadString('\n')
+
+	if err != nil && !errors.Is(err, io.EOF) {
+		return "", err
+	}
+
+	hash, _, _ := strings.Cut(strings.TrimSpace(line), ":")
+	return strings.ToUpper(hash), nil
+}
@@ -0,0 +1,146 @@
+package utils
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/parquet-go/parquet-go"
+)
+
+type ExportFormat string
+
+const (
+	ExportFormatCSV     ExportFormat = "csv"
+	ExportFormatNDJSON  ExportFormat = "ndjson"
+	ExportFormatParquet ExportFormat = "parquet"
+)
+
+// parquetRowGroupSize bounds how many rows the parquet writer buffers before
+// flushing a row group, which keeps exports at constant memory.
+const parquetRowGroupSize = 1000
+
+// CSVRecord is implemented by rows that can be exported as CSV.
+type CSVRecord interface {
+	CSVHeader() []string
+	CSVRecord() []string
+}
+
+// RecordWriter writes rows of one type in a single export format. Close must
+// be called to flush buffered rows and write any trailer.
+type RecordWriter[T CSVRecord] interface {
+	Write(row T) error
+	Close() error
+}
+
+func ParseExportFormat(value string) (ExportFormat, error) {
+	switch ExportFormat(strings.ToLower(strings.TrimSpace(value))) {
+	case "", ExportFormatCSV:
+		return ExportFormatCSV, nil
+	case ExportFormatNDJSON, "jsonl":
+		return ExportFormatNDJSON, nil
+	case ExportFormatParquet:
+		return ExportFormatParquet, nil
+	}
+
+	return "", fmt.Errorf("unsupported export format %q", value)
+}
+
+func (format ExportFormat) ContentType() string {
+	switch format {
+	case ExportFormatNDJSON:
+		return "application/x-ndjson"
+	case ExportFormatParquet:
+		return "application/vnd.apache.parquet"
+	}
+
+	return "text/csv"
+}
+
+// WriteHeader starts the output of writer with header, for exports whose
+// columns are known before their rows are, so that they are there even when
+// no rows follow. Formats without a header row ignore it.
+func WriteHeader[T CSVRecord](writer RecordWriter[T], header []string) error {
+	headerWriter, ok := writer.(interface{ WriteHeader([]string) error })
+
+	if !ok {
+		return nil
+	}
+
+	return headerWriter.WriteHeader(header)
+}
+
+func NewRecordWriter[T CSVRecord](w io.Writer, format ExportFormat) (RecordWriter[T], error) {
+	switch format {
+	case ExportFormatCSV:
+		return &csvRecordWriter[T]{writer: csv.NewWriter(w)}, nil
+	case ExportFormatNDJSON:
+		return &ndjsonRecordWriter[T]{encoder: json.NewEncoder(w)}, nil
+	case ExportFormatParquet:
+		return &parquetRecordWriter[T]{writer: parquet.NewGenericWriter[T](w, parquet.MaxRowsPerRowGroup(parquetRowGroupSize))}, nil
+	}
+
+	return nil, fmt.Errorf("unsupported export format %q", format)
+}
+
+type csvRecordWriter[T CSVRecord] struct {
+	writer      *csv.Writer
+	wroteHeader bool
+}
+
+func (w *csvRecordWriter[T]) Write(row T) error {
+	if !w.wroteHeader {
+		w.wroteHeader = true
+
+		err := w.writer.Write(row.CSVHeader())
+
+		if err != nil {
+			return err
+		}
+	}
+
+	return w.writer.Write(row.CSVRecord())
+}
+
+// WriteHeader writes header ahead of the rows, which then leave theirs out.
+func (w *csvRecordWriter[T]) WriteHeader(header []string) error {
+	w.wroteHeader = true
+	return w.writer.Write(header)
+}
+
+func (w *csvRecordWriter[T]) Close() error {
+	if !w.wroteHeader {
+		var row T
+		w.writer.Write(row.CSVHeader())
+	}
+
+	w.writer.Flush()
+	return w.writer.Error()
+}
+
+type ndjsonRecordWriter[T CSVRecord] struct {
+	encoder *json.Encoder
+}
+
+func (w *ndjsonRecordWriter[T]) Write(row T) error {
+	return w.encoder.Encode(row)
+}
+
+func (w *ndjsonRecordWriter[T]) Close() error {
+	return nil
+}
+
+type parquetRecordWriter[T CSVRecord] struct {
+	writer *parquet.GenericWriter[T]
+}
+
+func (w *parquetRecordWriter[T]) Write(row T) error {
+	_, err := w.writer.Write([]T{row})
+	return err
+}
+
+func (w *parquetRecordWriter[T]) Close() error {
+	return w.writer.Close()
+}
@@ -1,9 +1,15 @@
 package utils
 
-import "golang.org/x/crypto/bcrypt"
+import (
+	"sync"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+const passwordHashCost = 14
 
 func HashPassWord(password string) (string, error) {
-	bytes, err := bcrypt.GenerateFromPassword([]byte(password), 14)
+	bytes, err := bcrypt.GenerateFromPassword([]byte(password), passwordHashCost)
 	return string(bytes), err
 }
 
@@ -11,3 +17,17 @@ func CheckPasswordHash(password, hashedPassword string) bool {
 	err := bcrypt.CompareHashAndPassword([]byte(hashedPassword), []byte(password))
 	return err == nil
 }
+
+// dummyPasswordHash is compared against when there is no real hash, so that
+// it takes as long as checking a real one.
+var dummyPasswordHash = sync.OnceValue(func() []byte {
+	hash, _ := bcrypt.GenerateFromPassword([]byte("not a real password"), passwordHashCost)
+	return hash
+})
+
+// SimulatePasswordCheck spends the time CheckPasswordHash would, for
+// accounts that don't exist or have no password, so response times don't
+// tell which accounts exist.
+func SimulatePasswordCheck(password string) {
+	bcrypt.CompareHashAndPassword(dummyPasswordHash(), []byte(password))
+}
@@ -0,0 +1,114 @@
+package utils
+
+import (
+	"bytes"
+	"errors"
+	"image"
+	_ "image/gif"
+	"image/jpeg"
+	"image/png"
+	"io"
+)
+
+// MaxImagePixels bounds the images Thumbnail decodes. Image headers declare
+// their size, so a small file can otherwise ask for gigabytes of memory.
+const MaxImagePixels = 50_000_000
+
+var ErrImageTooLarge = errors.New("image is too large")
+
+// Thumbnail decodes a JPEG, PNG or GIF image and scales it down so neither
+// side exceeds maxSide. JPEG input produces a JPEG thumbnail, everything else
+// a PNG so transparency survives. It returns the encoded bytes and their
+// content type. Images of more than MaxImagePixels are rejected with
+// ErrImageTooLarge before they are decoded.
+func Thumbnail(r io.ReadSeeker, maxSide int) ([]byte, string, error) {
+	config, _, err := image.DecodeConfig(r)
+
+	if err != nil {
+		return nil, "", err
+	}
+
+	if int64(config.Width)*int64(config.Height) > MaxImagePixels {
+		return nil, "", ErrImageTooLarge
+	}
+
+	_, err = r.Seek(0, io.SeekStart)
+
+	if err != nil {
+		return nil, "", err
+	}
+
+	src, format, err := image.Decode(r)
+
+	if err != nil {
+		return nil, "", err
+	}
+
+	thumb := resize(src, maxSide)
+
+	var buf bytes.Buffer
+
+	if format == "jpeg" {
+		err = jpeg.Encode(&buf, thumb, &jpeg.Options{Quality: 85})
+		return buf.Bytes(), "image/jpeg", err
+	}
+
+	err = png.Encode(&buf, thumb)
+	return buf.Bytes(), "image/png", err
+}
+
+// resize scales src down with a box filter, averaging every source pixel that
+// falls inside each destination pixel. Images already small enough are only
+// converted to NRGBA.
+func resize(src image.Image, maxSide int) *image.NRGBA {
+	bounds := src.Bounds()
+	srcW, srcH := bounds.Dx(), bounds.Dy()
+	dstW, dstH := srcW, srcH
+
+	if srcW > maxSide || srcH > maxSide {
+		if srcW >= srcH {
+			dstW, dstH = maxSide, max(1, srcH*maxSide/srcW)
+		} else {
+			dstW, dstH = max(1, srcW*maxSide/srcH), maxSide
+		}
+	}
+
+	dst := image.NewNRGBA(image.Rect(0, 0, dstW, dstH))
+
+	for y := 0; y < dstH; y++ {
+		y0 := bounds.Min.Y + y*srcH/dstH
+		y1 := max(y0+1, bounds.Min.Y+(y+1)*srcH/dstH)
+
+		for x := 0; x < dstW; x++ {
+			x0 := bounds.Min.X + x*srcW/dstW
+			x1 := max(x0+1, bounds.Min.X+(x+1)*srcW/dstW)
+
+			var r, g, b, a, n uint64
+
+			for sy := y0; sy < y1; sy++ {
+				for sx := x0; sx < x1; sx++ {
+					pr, pg, pb, pa := src.At(sx, sy).RGBA()
+					r += uint64(pr)
+					g += uint64(pg)
+					b += uint64(pb)
+					a += uint64(pa)
+					n++
+				}
+			}
+
+			offset := dst.PixOffset(x, y)
+
+			if a == 0 {
+				continue
+			}
+
+			// RGBA() is alpha-premultiplied; NRGBA stores straight alpha.
+			dst.Pix[offset+0] = uint8(r * 0xffff / a >> 8)
+			dst.Pix[offset+1] = uint8(g * 0xffff / a >> 8)
+			dst.Pix[offset+2] = uint8(b * 0xffff / a >> 8)
+			dst.Pix[offset+3] = uint8(a / n >> 8)
+		}
+	}
+
+	return dst
+}
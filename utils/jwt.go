@@ -2,50 +2,140 @@ package utils
 
 import (
 	"errors"
+	"math"
 	"time"
 
 	"github.com/golang-jwt/jwt/v5"
 )
 
-const secretKey = "supersecret"
+// TokenIssuer and TokenAudience identify our access tokens, so tokens
+// minted by other services trusting the same keys are not accepted here.
+const (
+	TokenIssuer   = "go_http_server"
+	TokenAudience = "go_http_server"
+)
+
+// tokenLeeway allows for clock skew between us and services verifying our
+// tokens.
+const tokenLeeway = 30 * time.Second
+
+// AccessTokenLifetime is kept short because services verifying our tokens
+// with the JWKS cannot see revocations; clients use their refresh token to
+// get a new one.
+const AccessTokenLifetime = 15 * time.Minute
+
+// TokenClaims is what the server needs from a verified access token.
+type TokenClaims struct {
+	ID        string
+	UserID    int64
+	SessionID int64
+	Role      string
+	IssuedAt  time.Time
+	ExpiresAt time.Time
+}
+
+func GenerateToken(email string, userId int64, role string) (string, error) {
+	return GenerateSessionToken(email, userId, role, 0)
+}
 
-func GenerateToken(email string, userId int64) (string, error) {
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
+// GenerateSessionToken issues an access token tied to a login session, so
+// that signing the session out also stops its access tokens.
+func GenerateSessionToken(email string, userId int64, role string, sessionId int64) (string, error) {
+	if Keys == nil {
+		return "", errors.New("Token signing keys are not loaded")
+	}
+
+	jti, err := RandomToken(16)
+
+	if err != nil {
+		return "", err
+	}
+
+	now := time.Now()
+	claims := jwt.MapClaims{
+		"jti":    jti,
+		"iss":    TokenIssuer,
+		"aud":    TokenAudience,
 		"email":  email,
 		"userId": userId,
-		"exp":    time.Now().Add(time.Hour * 2).Unix(),
-	})
+		"role":   role,
+		"nbf":    now.Unix(),
+		// iat keeps milliseconds so a password change invalidates tokens
+		// issued moments before it.
+		"iat": float64(now.UnixMilli()) / 1000,
+		"exp": now.Add(AccessTokenLifetime).Unix(),
+	}
 
-	return token.SignedString([]byte(secretKey))
+	if sessionId != 0 {
+		claims["sid"] = sessionId
+	}
+
+	token := jwt.NewWithClaims(Keys.signing.Method, claims)
+	token.Header["kid"] = Keys.signing.ID
+	return token.SignedString(Keys.signing.Private)
 }
 
 func VerifyToken(token string) (int64, error) {
-	parsedToken, err := jwt.Parse(token, func(token *jwt.Token) (interface{}, error) {
-		_, ok := token.Method.(*jwt.SigningMethodHMAC)
+	claims, err := ParseToken(token)
 
-		if !ok {
-			return nil, errors.New("Unexpected signing method")
-		}
-		return []byte(secretKey), nil
-	})
+	if err != nil {
+		return 0, err
+	}
+
+	return claims.UserID, nil
+}
+
+// ParseToken checks the signature and expiry of token and returns its
+// claims. It does not know about revoked tokens.
+func ParseToken(token string) (*TokenClaims, error) {
+	if Keys == nil {
+		return nil, errors.New("Token signing keys are not loaded")
+	}
+
+	parsedToken, err := jwt.Parse(token, Keys.verificationKey,
+		jwt.WithValidMethods([]string{jwt.SigningMethodRS256.Alg(), jwt.SigningMethodEdDSA.Alg()}),
+		jwt.WithIssuer(TokenIssuer),
+		jwt.WithAudience(TokenAudience),
+		jwt.WithExpirationRequired(),
+		jwt.WithLeeway(tokenLeeway),
+	)
 
 	if err != nil {
-		return 0, errors.New("Could not parse token")
+		return nil, errors.New("Could not parse token")
 	}
 
 	tokenIsValid := parsedToken.Valid
 
 	if !tokenIsValid {
-		return 0, errors.New("Invalid token!")
+		return nil, errors.New("Invalid token!")
 	}
 
 	claims, ok := parsedToken.Claims.(jwt.MapClaims)
 
 	if !ok {
-		return 0, errors.New("Invalid token claims.")
+		return nil, errors.New("Invalid token claims.")
+	}
+
+	jti, _ := claims["jti"].(string)
+	userId, userOk := claims["userId"].(float64)
+	issuedAt, iatOk := claims["iat"].(float64)
+	expiresAt, expOk := claims["exp"].(float64)
+	_, nbfOk := claims["nbf"].(float64)
+
+	// Tokens without an id cannot be revoked, so they are not accepted.
+	if jti == "" || !userOk || !iatOk || !expOk || !nbfOk {
+		return nil, errors.New("Invalid token claims.")
 	}
 
-	// email, _ := claims["email"].(string)
-	userId := int64(claims["userId"].(float64))
-	return userId, nil
+	sessionId, _ := claims["sid"].(float64)
+	role, _ := claims["role"].(string)
+
+	return &TokenClaims{
+		ID:        jti,
+		UserID:    int64(userId),
+		SessionID: int64(sessionId),
+		Role:      role,
+		IssuedAt:  time.UnixMilli(int64(math.Round(issuedAt * 1000))),
+		ExpiresAt: time.Unix(int64(expiresAt), 0),
+	}, nil
 }
@@ -0,0 +1,274 @@
+package utils
+
+import (
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"math/big"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// SigningKey is one key of the key set. Keys loaded from a public key file
+// have no private half and are only used to verify tokens.
+type SigningKey struct {
+	ID      string
+	Method  jwt.SigningMethod
+	Private crypto.Signer
+	Public  crypto.PublicKey
+}
+
+// KeySet holds every key tokens may be signed with, so keys can be rotated
+// without invalidating the tokens signed by the previous one.
+type KeySet struct {
+	signing *SigningKey
+	keys    map[string]*SigningKey
+}
+
+// Keys is the key set used to sign and verify access tokens.
+var Keys *KeySet
+
+// InitKeys loads the key set from the PEM files in JWT_KEYS_DIR (default
+// "keys"). Each file is a key whose id is the file name without ".pem".
+// Tokens are signed with the key named by JWT_SIGNING_KEY, or with the last
+// private key by file name, so naming keys by date rotates to the newest.
+// When the directory has no keys an Ed25519 key is generated in it.
+func InitKeys() {
+	dir := os.Getenv("JWT_KEYS_DIR")
+
+	if dir == "" {
+		dir = "keys"
+	}
+
+	keys, err := LoadKeySet(dir, os.Getenv("JWT_SIGNING_KEY"))
+
+	if errors.Is(err, errNoKeys) {
+		keys, err = generateKeyFile(dir)
+	}
+
+	if err != nil {
+		panic("Could not load token signing keys: " + err.Error())
+	}
+
+	Keys = keys
+}
+
+var errNoKeys = errors.New("no keys found")
+
+// NewKeySet returns a key set that signs with the first key and verifies
+// with all of them.
+func NewKeySet(signing *SigningKey, others ...*SigningKey) (*KeySet, error) {
+	if signing == nil || signing.Private == nil {
+		return nil, errors.New("the signing key needs a private key")
+	}
+
+	keys := map[string]*SigningKey{signing.ID: signing}
+
+	for _, key := range others {
+		if _, ok := keys[key.ID]; ok {
+			return nil, errors.New("duplicate key id " + key.ID)
+		}
+
+		keys[key.ID] = key
+	}
+
+	return &KeySet{signing: signing, keys: keys}, nil
+}
+
+// LoadKeySet reads every .pem file in dir. signingId picks the signing key;
+// when empty the last private key by file name is used.
+func LoadKeySet(dir, signingId string) (*KeySet, error) {
+	paths, err := filepath.Glob(filepath.Join(dir, "*.pem"))
+
+	if err != nil {
+		return nil, err
+	}
+
+	if len(paths) == 0 {
+		return nil, errNoKeys
+	}
+
+	sort.Strings(paths)
+
+	var signing *SigningKey
+	var others []*SigningKey
+
+	for _, path := range paths {
+		data, err := os.ReadFile(path)
+
+		if err != nil {
+			return nil, err
+		}
+
+		key, err := ParseKey(strings.TrimSuffix(filepath.Base(path), ".pem"), data)
+
+		if err != nil {
+			return nil, errors.New(filepath.Base(path) + ": " + err.Error())
+		}
+
+		others = append(others, key)
+
+		if key.Private != nil && (signingId == "" || key.ID == signingId) {
+			signing = key
+		}
+	}
+
+	if signing == nil {
+		return nil, errors.New("no private key to sign tokens with")
+	}
+
+	for i, key := range others {
+		if key == signing {
+			others = append(others[:i], others[i+1:]...)
+			break
+		}
+	}
+
+	return NewKeySet(signing, others...)
+}
+
+// ParseKey reads an RSA or Ed25519 key, private or public, from PEM data.
+func ParseKey(id string, data []byte) (*SigningKey, error) {
+	block, _ := pem.Decode(data)
+
+	if block == nil {
+		return nil, errors.New("not a PEM file")
+	}
+
+	var parsed any
+	var err error
+
+	switch block.Type {
+	case "PRIVATE KEY":
+		parsed, err = x509.ParsePKCS8PrivateKey(block.Bytes)
+	case "RSA PRIVATE KEY":
+		parsed, err = x509.ParsePKCS1PrivateKey(block.Bytes)
+	case "PUBLIC KEY":
+		parsed, err = x509.ParsePKIXPublicKey(block.Bytes)
+	default:
+		return nil, errors.New("unsupported PEM block " + block.Type)
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	key := SigningKey{ID: id}
+
+	switch k := parsed.(type) {
+	case *rsa.PrivateKey:
+		key.Method, key.Private, key.Public = jwt.SigningMethodRS256, k, &k.PublicKey
+	case *rsa.PublicKey:
+		key.Method, key.Public = jwt.SigningMethodRS256, k
+	case ed25519.PrivateKey:
+		key.Method, key.Private, key.Public = jwt.SigningMethodEdDSA, k, k.Public()
+	case ed25519.PublicKey:
+		key.Method, key.Public = jwt.SigningMethodEdDSA, k
+	default:
+		return nil, errors.New("only RSA and Ed25519 keys are supported")
+	}
+
+	return &key, nil
+}
+
+// NewEd25519Key generates a signing key that only lives in memory.
+func NewEd25519Key(id string) (*SigningKey, error) {
+	public, private, err := ed25519.GenerateKey(rand.Reader)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &SigningKey{ID: id, Method: jwt.SigningMethodEdDSA, Private: private, Public: public}, nil
+}
+
+func generateKeyFile(dir string) (*KeySet, error) {
+	key, err := NewEd25519Key("default")
+
+	if err != nil {
+		return nil, err
+	}
+
+	data, err := x509.MarshalPKCS8PrivateKey(key.Private)
+
+	if err != nil {
+		return nil, err
+	}
+
+	err = os.MkdirAll(dir, 0o700)
+
+	if err != nil {
+		return nil, err
+	}
+
+	err = os.WriteFile(filepath.Join(dir, key.ID+".pem"), pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: data}), 0o600)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return NewKeySet(key)
+}
+
+// verificationKey finds the key a token was signed with by its kid header.
+func (s *KeySet) verificationKey(token *jwt.Token) (interface{}, error) {
+	kid, _ := token.Header["kid"].(string)
+	key, ok := s.keys[kid]
+
+	if !ok {
+		return nil, errors.New("Unknown signing key")
+	}
+
+	if token.Method.Alg() != key.Method.Alg() {
+		return nil, errors.New("Unexpected signing method")
+	}
+
+	return key.Public, nil
+}
+
+// JWK is a public key in JSON Web Key format.
+type JWK struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	Alg string `json:"alg"`
+	Crv string `json:"crv,omitempty"`
+	X   string `json:"x,omitempty"`
+	N   string `json:"n,omitempty"`
+	E   string `json:"e,omitempty"`
+}
+
+// JWKS returns the public half of every key, sorted by id, for other
+// services to verify our tokens with.
+func (s *KeySet) JWKS() []JWK {
+	jwks := []JWK{}
+
+	for _, key := range s.keys {
+		jwk := JWK{Kid: key.ID, Use: "sig", Alg: key.Method.Alg()}
+
+		switch public := key.Public.(type) {
+		case *rsa.PublicKey:
+			jwk.Kty = "RSA"
+			jwk.N = base64.RawURLEncoding.EncodeToString(public.N.Bytes())
+			jwk.E = base64.RawURLEncoding.EncodeToString(big.NewInt(int64(public.E)).Bytes())
+		case ed25519.PublicKey:
+			jwk.Kty = "OKP"
+			jwk.Crv = "Ed25519"
+			jwk.X = base64.RawURLEncoding.EncodeToString(public)
+		}
+
+		jwks = append(jwks, jwk)
+	}
+
+	sort.Slice(jwks, func(i, j int) bool { return jwks[i].Kid < jwks[j].Kid })
+	return jwks
+}